@@ -9,6 +9,7 @@ import (
 // 设置自带的store
 var store = base64Captcha.DefaultMemStore
 
+// CaptMake 固定 200x60 的 4 位字符验证码，答案保存在内存中，多实例部署或需要其它类型时使用 NewService
 func CaptMake() (id, b64s string, err error) {
 	// 生成验证码
 	var driver base64Captcha.Driver
@@ -0,0 +1,53 @@
+package captcha
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AbnerEarl/goutils/gins"
+)
+
+type verifyReq struct {
+	Id     string `args:"captcha_id" valid:"required"`
+	Answer string `args:"captcha_answer" valid:"required"`
+}
+
+// IssueHandler 生成验证码，返回 captcha_id、type、data 和 expires_in
+//
+//	server.GET("/api/v1/captcha", svc.IssueHandler())
+func (s *Service) IssueHandler() gins.HandlerFunc {
+	return func(c *gins.Context) {
+		challenge, err := s.Generate()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gins.Response{Errno: gins.NewErr(gins.InternalError, err)})
+			return
+		}
+		gins.SendResponse(c, nil, challenge)
+	}
+}
+
+// VerifyHandler 校验 captcha_id 和 captcha_answer 参数，需要放在 gins.Args 之后、需要验证码的接口之前，
+// 校验失败时中止请求
+//
+//	server.POST("/api/v1/account/login", gins.Args(), svc.VerifyHandler(), loginHandler)
+func (s *Service) VerifyHandler() gins.HandlerFunc {
+	return func(c *gins.Context) {
+		var req verifyReq
+		if err := c.BindArgs(&req); err != nil {
+			c.Abort()
+			gins.SendResponse(c, err, nil)
+			return
+		}
+		if err := s.Verify(req.Id, req.Answer); err != nil {
+			status := http.StatusInternalServerError
+			errno := gins.NewErr(gins.InternalError, err)
+			if errors.Is(err, ErrCaptchaWrong) || errors.Is(err, ErrCaptchaNotFound) || errors.Is(err, ErrCaptchaAttempts) {
+				status = http.StatusBadRequest
+				errno = gins.NewErr(gins.ParamError, err)
+			}
+			c.AbortWithStatusJSON(status, gins.Response{Errno: errno})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,241 @@
+package captcha
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+
+	"github.com/mojocn/base64Captcha"
+)
+
+// Type 验证码类型
+type Type string
+
+const (
+	TypeString Type = "string"
+	TypeDigit  Type = "digit"
+	TypeMath   Type = "math"
+	TypeAudio  Type = "audio"
+)
+
+var (
+	ErrCaptchaType = errors.New("unsupported captcha type")
+	ErrCaptchaFont = errors.New("unknown captcha font")
+)
+
+// embeddedFonts base64Captcha 内置的字体，未知的字体名称会使 base64Captcha panic
+var embeddedFonts = map[string]bool{
+	"3Dumb.ttf":             true,
+	"ApothecaryFont.ttf":    true,
+	"Comismsh.ttf":          true,
+	"DENNEthree-dee.ttf":    true,
+	"DeborahFancyDress.ttf": true,
+	"Flim-Flam.ttf":         true,
+	"RitaSmith.ttf":         true,
+	"actionj.ttf":           true,
+	"chromohv.ttf":          true,
+	"wqy-microhei.ttc":      true,
+}
+
+type options struct {
+	typ         Type
+	width       int
+	height      int
+	length      int
+	noise       int
+	lines       int
+	source      string
+	fonts       []string
+	bgColor     *color.RGBA
+	language    string
+	ttl         time.Duration
+	maxAttempts int
+	ignoreCase  bool
+}
+
+type Option func(o *options)
+
+// WithType 验证码类型，默认 TypeString
+func WithType(typ Type) Option {
+	return func(o *options) {
+		o.typ = typ
+	}
+}
+
+// WithSize 图片宽高，默认 200x60，音频验证码忽略
+func WithSize(width, height int) Option {
+	return func(o *options) {
+		o.width = width
+		o.height = height
+	}
+}
+
+// WithLength 字符或数字个数，默认 4，算术验证码忽略
+func WithLength(length int) Option {
+	return func(o *options) {
+		o.length = length
+	}
+}
+
+// WithNoise noise 为干扰字符数（数字验证码为干扰圆点数），lines 为干扰线选项，
+// 取值为 base64Captcha.OptionShowHollowLine、OptionShowSlimeLine、OptionShowSineLine 的组合
+func WithNoise(noise, lines int) Option {
+	return func(o *options) {
+		o.noise = noise
+		o.lines = lines
+	}
+}
+
+// WithSource 字符验证码使用的字符集
+func WithSource(source string) Option {
+	return func(o *options) {
+		o.source = source
+	}
+}
+
+// WithFonts 字体名称，见 base64Captcha 的 fonts 目录（如 3Dumb.ttf），为空时随机使用全部字体，
+// 未知的字体名称 NewService 返回 ErrCaptchaFont
+func WithFonts(fonts ...string) Option {
+	return func(o *options) {
+		o.fonts = fonts
+	}
+}
+
+// WithBgColor 背景颜色，默认白色
+func WithBgColor(c color.RGBA) Option {
+	return func(o *options) {
+		o.bgColor = &c
+	}
+}
+
+// WithLanguage 音频验证码的语言，取值为 en、ja、ru、zh，默认 en
+func WithLanguage(language string) Option {
+	return func(o *options) {
+		o.language = language
+	}
+}
+
+// WithTTL 验证码有效期，默认 5 分钟
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithMaxAttempts 每个验证码最多允许回答错误的次数，达到后验证码失效，默认 5，0 表示不限制
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithIgnoreCase 字符验证码是否忽略大小写，默认忽略
+func WithIgnoreCase(ignore bool) Option {
+	return func(o *options) {
+		o.ignoreCase = ignore
+	}
+}
+
+// Challenge 返回给前端的验证码，Data 为 data URI，图片为 image/png，音频为 audio/wav
+type Challenge struct {
+	Id        string `json:"captcha_id"`
+	Type      Type   `json:"type"`
+	Data      string `json:"data"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// Service 生成和校验验证码，答案保存在 Store 中，多实例部署时使用 RedisStore
+type Service struct {
+	store  Store
+	opts   *options
+	driver base64Captcha.Driver
+}
+
+func NewService(store Store, opts ...Option) (*Service, error) {
+	//use example:
+	//svc, err := captcha.NewService(captcha.NewRedisStore(rdb, ""), captcha.WithType(captcha.TypeMath), captcha.WithTTL(2*time.Minute))
+	//challenge, err := svc.Generate()
+	//err = svc.Verify(challenge.Id, answer)
+	o := &options{
+		typ:         TypeString,
+		width:       200,
+		height:      60,
+		length:      4,
+		lines:       base64Captcha.OptionShowHollowLine | base64Captcha.OptionShowSlimeLine,
+		source:      "1234567890qwertyuioplkjhgfdsazxcvbnm",
+		bgColor:     &color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		language:    "en",
+		ttl:         5 * time.Minute,
+		maxAttempts: 5,
+		ignoreCase:  true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.width <= 0 || o.height <= 0 || o.length <= 0 || o.ttl <= 0 {
+		return nil, errors.New("the captcha size, length and ttl must be greater than 0")
+	}
+	for _, font := range o.fonts {
+		if !embeddedFonts[font] {
+			return nil, fmt.Errorf("%w: %s", ErrCaptchaFont, font)
+		}
+	}
+	var driver base64Captcha.Driver
+	switch o.typ {
+	case TypeString:
+		driver = base64Captcha.NewDriverString(o.height, o.width, o.noise, o.lines, o.length, o.source, o.bgColor, nil, o.fonts)
+	case TypeDigit:
+		driver = base64Captcha.NewDriverDigit(o.height, o.width, o.length, 0.7, o.noise)
+	case TypeMath:
+		driver = base64Captcha.NewDriverMath(o.height, o.width, o.noise, o.lines, o.bgColor, nil, o.fonts)
+	case TypeAudio:
+		driver = base64Captcha.NewDriverAudio(o.length, o.language)
+	default:
+		return nil, ErrCaptchaType
+	}
+	return &Service{store: store, opts: o, driver: driver}, nil
+}
+
+// Generate 生成新的验证码并保存答案
+func (s *Service) Generate() (*Challenge, error) {
+	_, question, answer := s.driver.GenerateIdQuestionAnswer()
+	item, err := s.driver.DrawCaptcha(question)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 16)
+	if _, err = rand.Read(buf); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(buf)
+	if err = s.store.Set(id, s.normalize(answer), s.opts.ttl); err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		Id:        id,
+		Type:      s.opts.typ,
+		Data:      item.EncodeB64string(),
+		ExpiresIn: int64(s.opts.ttl / time.Second),
+	}, nil
+}
+
+// Verify 校验答案，成功后验证码失效，错误次数过多时返回 ErrCaptchaAttempts
+func (s *Service) Verify(id, answer string) error {
+	answer = s.normalize(answer)
+	if id == "" || answer == "" {
+		return ErrCaptchaWrong
+	}
+	return s.store.Verify(id, answer, s.opts.maxAttempts)
+}
+
+func (s *Service) normalize(answer string) string {
+	answer = strings.TrimSpace(answer)
+	if s.opts.ignoreCase {
+		answer = strings.ToLower(answer)
+	}
+	return answer
+}
@@ -0,0 +1,135 @@
+package captcha
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+var (
+	ErrCaptchaNotFound = errors.New("the captcha does not exist or has expired")
+	ErrCaptchaWrong    = errors.New("the captcha answer is wrong")
+	ErrCaptchaAttempts = errors.New("too many wrong answers, please get a new captcha")
+)
+
+// Store 保存验证码答案，需要保证并发时同一个验证码只能验证成功一次
+type Store interface {
+	// Set 保存答案，ttl 后过期
+	Set(id, answer string, ttl time.Duration) error
+	// Verify 答案正确时删除并返回 nil，错误时累计次数并返回 ErrCaptchaWrong，
+	// 错误次数达到 maxAttempts（大于 0 时）后删除并返回 ErrCaptchaAttempts，不存在时返回 ErrCaptchaNotFound
+	Verify(id, answer string, maxAttempts int) error
+}
+
+type memoryItem struct {
+	answer    string
+	attempts  int
+	expiresAt time.Time
+}
+
+// MemoryStore 单机使用的 Store
+type MemoryStore struct {
+	lock  sync.Mutex
+	items map[string]*memoryItem
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]*memoryItem)}
+}
+
+func (s *MemoryStore) Set(id, answer string, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for k, v := range s.items {
+		if now.After(v.expiresAt) {
+			delete(s.items, k)
+		}
+	}
+	s.items[id] = &memoryItem{answer: answer, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Verify(id, answer string, maxAttempts int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[id]
+	if !ok || time.Now().After(item.expiresAt) {
+		delete(s.items, id)
+		return ErrCaptchaNotFound
+	}
+	if item.answer == answer {
+		delete(s.items, id)
+		return nil
+	}
+	item.attempts++
+	if maxAttempts > 0 && item.attempts >= maxAttempts {
+		delete(s.items, id)
+		return ErrCaptchaAttempts
+	}
+	return ErrCaptchaWrong
+}
+
+var captchaSetScript = redisc.NewScript(`
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'answer', ARGV[1], 'attempts', 0)
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+var captchaVerifyScript = redisc.NewScript(`
+local answer = redis.call('HGET', KEYS[1], 'answer')
+if not answer then
+	return -1
+end
+if answer == ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+local n = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if tonumber(ARGV[2]) > 0 and n >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+	return -2
+end
+return 0
+`)
+
+// RedisStore 多实例共享的 Store，每个验证码保存为一个带过期时间的 hash
+type RedisStore struct {
+	cli    redisc.ScriptRunner
+	prefix string
+}
+
+// NewRedisStore cli 可以是 *redisc.RedisCli、*redisc.RedisClusterCli 或 *redisc.UniversalClient，prefix 为空时使用 "captcha:"
+func NewRedisStore(cli redisc.ScriptRunner, prefix string) *RedisStore {
+	//use example:
+	//store := captcha.NewRedisStore(rdb, "captcha:")
+	if prefix == "" {
+		prefix = "captcha:"
+	}
+	return &RedisStore{cli: cli, prefix: prefix}
+}
+
+func (s *RedisStore) Set(id, answer string, ttl time.Duration) error {
+	_, err := s.cli.RdbEvalScript(captchaSetScript, []string{s.prefix + id}, answer, ttl.Milliseconds())
+	return err
+}
+
+func (s *RedisStore) Verify(id, answer string, maxAttempts int) error {
+	reply, err := s.cli.RdbEvalScript(captchaVerifyScript, []string{s.prefix + id}, answer, maxAttempts)
+	if err != nil {
+		return err
+	}
+	n, _ := reply.(int64)
+	switch n {
+	case 1:
+		return nil
+	case -1:
+		return ErrCaptchaNotFound
+	case -2:
+		return ErrCaptchaAttempts
+	}
+	return ErrCaptchaWrong
+}
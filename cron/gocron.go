@@ -45,6 +45,7 @@ type Locker interface {
 type timeUnit int
 
 // MAXJOBNUM max number of jobs, hack it if you need.
+// Deprecated: the scheduler keeps its jobs in a slice and has no limit any more.
 const MAXJOBNUM = 10000
 
 //go:generate stringer -type=timeUnit
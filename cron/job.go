@@ -1,10 +1,13 @@
 package cron
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"reflect"
+	"sync"
 	"time"
 )
 
@@ -16,6 +19,23 @@ var (
 	ErrParameterCannotBeNil = errors.New("nil paramaters cannot be used with reflection")
 )
 
+// OverlapPolicy decides what happens when a job is due while its previous run is still going
+type OverlapPolicy int
+
+const (
+	// OverlapAllow starts the new run concurrently, the default
+	OverlapAllow OverlapPolicy = iota
+	// OverlapSkip drops the new run
+	OverlapSkip
+	// OverlapQueue starts the new run after the previous one finishes, runs are queued in order
+	OverlapQueue
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 // Job struct keeping information about job
 type Job struct {
 	interval uint64                   // pause interval * unit between runs
@@ -31,6 +51,23 @@ type Job struct {
 	fparams  map[string][]interface{} // Map for function and  params of function
 	lock     bool                     // lock the job from running at same time form multiple instances
 	tags     []string                 // allow the user to tag jobs with certain labels
+	schedule Schedule                 // optional cron schedule, overrides interval and unit
+	spec     string                   // the cron expression of schedule
+	name     string                   // optional name, named jobs are persisted to the scheduler's store
+	misfire  MisfirePolicy            // what to do with the runs missed while no scheduler was running
+	missed   int                      // extra runs to start at the next run with MisfireFireAll
+	timeout  time.Duration            // optional deadline of the context passed to the job
+	jitter   time.Duration            // optional random delay before each run
+	overlap  OverlapPolicy            // what to do when the previous run is still going
+
+	scheduler *Scheduler  // the scheduler the job belongs to, runs use its pool and hooks
+	mu        sync.Mutex  // guards the fields below
+	running   int         // number of runs in progress
+	queued    int         // number of runs waiting with OverlapQueue
+	catchUp   int         // number of missed runs waiting to run one after another, whatever the overlap policy
+	paused    bool        // paused jobs are not run on schedule
+	history   []RunRecord // latest run records
+	metrics   JobMetrics  // run counters
 }
 
 // NewJob creates a new job with the time interval.
@@ -52,22 +89,253 @@ func (j *Job) shouldRun() bool {
 	return time.Now().Unix() >= j.nextRun.Unix()
 }
 
+// RunRecord is the outcome of one run of a job
+type RunRecord struct {
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	Result   []interface{} // the values returned by the job function
+}
+
+// JobMetrics counts the runs of a job
+type JobMetrics struct {
+	Runs          int64 // finished runs, including failed ones
+	Failures      int64 // runs that returned a non-nil error or panicked
+	Skipped       int64 // runs dropped by the overlap policy, the locker or a full pool
+	Running       int   // runs in progress
+	LastRun       time.Time
+	LastDuration  time.Duration
+	LastErr       error
+	MaxDuration   time.Duration
+	TotalDuration time.Duration
+}
+
+// errJobLocked is returned by run when another instance holds the lock
+var errJobLocked = errors.New("the job is locked by another instance")
+
+// startMissed starts a run followed by n runs missed while no scheduler was running,
+// the missed runs are queued behind it whatever the overlap policy, so a long downtime
+// does not start them all at once
+func (j *Job) startMissed(n int) {
+	j.mu.Lock()
+	j.catchUp += n
+	j.mu.Unlock()
+	j.start()
+}
+
+// start runs the job in the background following its overlap policy, jitter and timeout
+func (j *Job) start() {
+	j.mu.Lock()
+	if j.running > 0 {
+		switch j.overlap {
+		case OverlapSkip:
+			j.metrics.Skipped++
+			j.mu.Unlock()
+			return
+		case OverlapQueue:
+			j.queued++
+			j.mu.Unlock()
+			return
+		}
+	}
+	j.running++
+	j.mu.Unlock()
+
+	ctx := context.Background()
+	if j.scheduler != nil {
+		ctx = j.scheduler.context()
+	}
+	task := func() {
+		for {
+			j.execute(ctx)
+			j.mu.Lock()
+			if j.catchUp > 0 && ctx.Err() == nil {
+				j.catchUp--
+				j.mu.Unlock()
+				continue
+			}
+			if j.overlap == OverlapQueue && j.queued > 0 && ctx.Err() == nil {
+				j.queued--
+				j.mu.Unlock()
+				continue
+			}
+			j.queued = 0
+			j.catchUp = 0
+			j.running--
+			j.mu.Unlock()
+			return
+		}
+	}
+	if j.scheduler == nil {
+		go task()
+		return
+	}
+	if err := j.scheduler.submit(task); err != nil {
+		j.mu.Lock()
+		j.running--
+		j.metrics.Skipped++
+		j.mu.Unlock()
+		if j.scheduler.onError != nil {
+			j.scheduler.onError(j, err)
+		}
+	}
+}
+
+func (j *Job) execute(ctx context.Context) {
+	if j.jitter > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(rand.Int63n(int64(j.jitter)))):
+		}
+	}
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	s := j.scheduler
+	if s != nil && s.before != nil {
+		s.before(j)
+	}
+	record := RunRecord{Start: time.Now()}
+	values, err := j.run(ctx)
+	if err == errJobLocked {
+		j.mu.Lock()
+		j.metrics.Skipped++
+		j.mu.Unlock()
+		return
+	}
+	record.Duration = time.Since(record.Start)
+	record.Err = err
+	for _, v := range values {
+		record.Result = append(record.Result, v.Interface())
+	}
+	j.record(record)
+	if s != nil {
+		if err != nil && s.onError != nil {
+			s.onError(j, err)
+		}
+		if err := j.saveState(); err != nil && s.onError != nil {
+			s.onError(j, err)
+		}
+		if s.after != nil {
+			s.after(j, record)
+		}
+	}
+}
+
+func (j *Job) record(r RunRecord) {
+	size := 10
+	if j.scheduler != nil {
+		size = j.scheduler.historySize
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.metrics.Runs++
+	if r.Err != nil {
+		j.metrics.Failures++
+	}
+	j.metrics.LastRun = r.Start
+	j.metrics.LastDuration = r.Duration
+	j.metrics.LastErr = r.Err
+	j.metrics.TotalDuration += r.Duration
+	if r.Duration > j.metrics.MaxDuration {
+		j.metrics.MaxDuration = r.Duration
+	}
+	if size <= 0 {
+		return
+	}
+	j.history = append(j.history, r)
+	if len(j.history) > size {
+		j.history = append(j.history[:0], j.history[len(j.history)-size:]...)
+	}
+}
+
+// History returns the latest run records of the job, oldest first
+func (j *Job) History() []RunRecord {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]RunRecord(nil), j.history...)
+}
+
+// Metrics returns the run counters of the job
+func (j *Job) Metrics() JobMetrics {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	m := j.metrics
+	m.Running = j.running
+	return m
+}
+
+// Pause stops the job from running on schedule until Resume, runs in progress are not affected
+func (j *Job) Pause() error {
+	j.mu.Lock()
+	j.paused = true
+	j.mu.Unlock()
+	return j.saveState()
+}
+
+// Resume lets a paused job run on schedule again
+func (j *Job) Resume() error {
+	j.mu.Lock()
+	j.paused = false
+	j.mu.Unlock()
+	return j.saveState()
+}
+
+// IsPaused reports whether the job is paused
+func (j *Job) IsPaused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.paused
+}
+
 // Run the job and immediately reschedule it
-func (j *Job) run() ([]reflect.Value, error) {
+func (j *Job) run(ctx context.Context) ([]reflect.Value, error) {
 	if j.lock {
 		if locker == nil {
 			return nil, fmt.Errorf("trying to lock %s with nil locker", j.jobFunc)
 		}
 		key := getFunctionKey(j.jobFunc)
+		if j.name != "" {
+			key = getFunctionKey(j.name)
+		}
 
-		locker.Lock(key)
+		// skip this run if another instance holds the lock
+		locked, err := locker.Lock(key)
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			return nil, errJobLocked
+		}
 		defer locker.Unlock(key)
 	}
-	result, err := callJobFuncWithParams(j.funcs[j.jobFunc], j.fparams[j.jobFunc])
+	params := j.fparams[j.jobFunc]
+	// a job function taking a context.Context as its first parameter receives the run context
+	if typ := reflect.TypeOf(j.funcs[j.jobFunc]); typ.NumIn() == len(params)+1 && typ.In(0) == contextType {
+		params = append([]interface{}{ctx}, params...)
+	}
+	return callJobFuncSafely(j.funcs[j.jobFunc], params)
+}
+
+// callJobFuncSafely recovers panics, and returns the last result as the error if its type is error
+func callJobFuncSafely(jobFunc interface{}, params []interface{}) (result []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	result, err = callJobFuncWithParams(jobFunc, params)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
+	typ := reflect.TypeOf(jobFunc)
+	if n := typ.NumOut(); n > 0 && typ.Out(n-1) == errorType && !result[n-1].IsNil() {
+		err = result[n-1].Interface().(error)
+	}
+	return result, err
 }
 
 // Err should be checked to ensure an error didn't occur creating the job
@@ -90,6 +358,17 @@ func (j *Job) Do(jobFun interface{}, params ...interface{}) error {
 	j.fparams[fname] = params
 	j.jobFunc = fname
 
+	if store := j.store(); store != nil {
+		if err := j.scheduler.Register(j.name, jobFun, params...); err != nil {
+			return err
+		}
+		if err := j.restore(store); err != nil {
+			j.err = err
+			return err
+		}
+		return nil
+	}
+
 	now := time.Now().In(j.loc)
 	if !j.nextRun.After(now) {
 		j.scheduleNextRun()
@@ -203,6 +482,18 @@ func (j *Job) scheduleNextRun() error {
 		j.lastRun = now
 	}
 
+	if j.schedule != nil {
+		from := now
+		if j.nextRun.After(from) {
+			from = j.nextRun
+		}
+		j.nextRun = j.schedule.Next(from.In(j.loc))
+		if j.nextRun.IsZero() {
+			j.nextRun = neverRun
+		}
+		return nil
+	}
+
 	periodDuration, err := j.periodDuration()
 	if err != nil {
 		return err
@@ -361,6 +652,27 @@ func (j *Job) Sunday() *Job {
 	return j.Weekday(time.Sunday)
 }
 
+// Timeout sets the deadline of the context passed to the job function,
+// the function must take a context.Context as its first parameter to observe it
+//
+//	s.Cron("0 * * * *").Timeout(time.Minute).Do(func(ctx context.Context) { ... })
+func (j *Job) Timeout(d time.Duration) *Job {
+	j.timeout = d
+	return j
+}
+
+// Jitter delays each run by a random duration in [0, d), to spread the load of many instances
+func (j *Job) Jitter(d time.Duration) *Job {
+	j.jitter = d
+	return j
+}
+
+// Overlap sets what happens when the job is due while its previous run is still going
+func (j *Job) Overlap(policy OverlapPolicy) *Job {
+	j.overlap = policy
+	return j
+}
+
 // Lock prevents job to run from multiple instances of cron
 func (j *Job) Lock() *Job {
 	j.lock = true
@@ -1,16 +1,40 @@
 package cron
 
 import (
+	"context"
 	"sort"
+	"sync"
 	"time"
+
+	"github.com/AbnerEarl/goutils/pool"
 )
 
-// Scheduler struct, the only data member is the list of jobs.
+// Scheduler struct, keeps the list of jobs and runs them on a worker pool.
 // - implements the sort.Interface{} for sorting jobs, by the time nextRun
 type Scheduler struct {
-	jobs [MAXJOBNUM]*Job // Array store jobs
-	size int             // Size of jobs which jobs holding.
-	loc  *time.Location  // Location to use when scheduling jobs with specified times
+	jobs []*Job         // Slice store jobs
+	loc  *time.Location // Location to use when scheduling jobs with specified times
+
+	mu          sync.Mutex                // guards jobs and ctx
+	ctx         context.Context           // parent context of all runs, set by Start
+	pool        *pool.Pool                // optional worker pool, nil runs each job in a new goroutine
+	wg          sync.WaitGroup            // runs in progress
+	historySize int                       // max number of run records kept per job
+	before      func(*Job)                // called before each run
+	after       func(*Job, RunRecord)     // called after each run
+	onError     func(*Job, error)         // called when a run fails or cannot be submitted
+	store       JobStore                  // optional store of named jobs
+	registry    map[string]registeredFunc // functions of named jobs, used by Restore
+}
+
+// SchedulerMetrics sums up the metrics of all jobs in a scheduler
+type SchedulerMetrics struct {
+	Jobs     int
+	Paused   int
+	Running  int
+	Runs     int64
+	Failures int64
+	Skipped  int64
 }
 
 var (
@@ -20,19 +44,21 @@ var (
 // NewScheduler creates a new scheduler
 func NewScheduler() *Scheduler {
 	return &Scheduler{
-		jobs: [MAXJOBNUM]*Job{},
-		size: 0,
-		loc:  loc,
+		loc:         loc,
+		ctx:         context.Background(),
+		historySize: 10,
 	}
 }
 
 // Jobs returns the list of Jobs from the Scheduler
 func (s *Scheduler) Jobs() []*Job {
-	return s.jobs[:s.size]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Job(nil), s.jobs...)
 }
 
 func (s *Scheduler) Len() int {
-	return s.size
+	return len(s.jobs)
 }
 
 func (s *Scheduler) Swap(i, j int) {
@@ -48,25 +74,51 @@ func (s *Scheduler) ChangeLoc(newLocation *time.Location) {
 	s.loc = newLocation
 }
 
+// SetPool runs the jobs on the worker pool p, the pool size bounds the number of concurrent runs.
+// With a blocking pool RunPending waits for a free worker, with pool.WithNonblocking(true)
+// a run that finds no free worker is skipped and reported to OnError
+func (s *Scheduler) SetPool(p *pool.Pool) {
+	s.pool = p
+}
+
+// SetHistorySize sets how many run records are kept per job, 10 by default
+func (s *Scheduler) SetHistorySize(n int) {
+	s.historySize = n
+}
+
+// OnBefore sets a hook called before each run
+func (s *Scheduler) OnBefore(f func(j *Job)) {
+	s.before = f
+}
+
+// OnAfter sets a hook called after each run, including failed ones
+func (s *Scheduler) OnAfter(f func(j *Job, record RunRecord)) {
+	s.after = f
+}
+
+// OnError sets a hook called when a run returns an error, panics, or cannot be submitted to the pool
+func (s *Scheduler) OnError(f func(j *Job, err error)) {
+	s.onError = f
+}
+
 // Get the current runnable jobs, which shouldRun is True
-func (s *Scheduler) getRunnableJobs() (runningJobs [MAXJOBNUM]*Job, n int) {
-	runnableJobs := [MAXJOBNUM]*Job{}
-	n = 0
+func (s *Scheduler) getRunnableJobs() []*Job {
+	var runnableJobs []*Job
 	sort.Sort(s)
-	for i := 0; i < s.size; i++ {
-		if s.jobs[i].shouldRun() {
-			runnableJobs[n] = s.jobs[i]
-			n++
-		} else {
+	for _, job := range s.jobs {
+		if !job.shouldRun() {
 			break
 		}
+		runnableJobs = append(runnableJobs, job)
 	}
-	return runnableJobs, n
+	return runnableJobs
 }
 
 // NextRun datetime when the next job should run.
 func (s *Scheduler) NextRun() (*Job, time.Time) {
-	if s.size <= 0 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.jobs) == 0 {
 		return nil, time.Now()
 	}
 	sort.Sort(s)
@@ -75,25 +127,59 @@ func (s *Scheduler) NextRun() (*Job, time.Time) {
 
 // Every schedule a new periodic job with interval
 func (s *Scheduler) Every(interval uint64) *Job {
-	job := NewJob(interval).Loc(s.loc)
-	s.jobs[s.size] = job
-	s.size++
+	return s.add(NewJob(interval).Loc(s.loc))
+}
+
+// Cron schedule a new job with a crontab expression, see ParseCron for the syntax.
+// The expression is evaluated in the job's location, set it with Loc before Do
+//
+//	s.Cron("*/5 9-18 * * MON-FRI").Do(task)
+//	s.Cron("@daily").Loc(time.UTC).Do(task)
+func (s *Scheduler) Cron(spec string) *Job {
+	job := NewJob(1).Loc(s.loc)
+	job.schedule, job.err = ParseCron(spec)
+	job.spec = spec
+	return s.add(job)
+}
+
+func (s *Scheduler) add(job *Job) *Job {
+	job.scheduler = s
+	s.mu.Lock()
+	s.jobs = append(s.jobs, job)
+	s.mu.Unlock()
 	return job
 }
 
-// RunPending runs all the jobs that are scheduled to run.
+// RunPending runs all the jobs that are scheduled to run, paused jobs are rescheduled without running.
 func (s *Scheduler) RunPending() {
-	runnableJobs, n := s.getRunnableJobs()
+	s.mu.Lock()
+	runnableJobs := s.getRunnableJobs()
+	missed := make([]int, len(runnableJobs))
+	for i, job := range runnableJobs {
+		job.lastRun = time.Now()
+		job.scheduleNextRun()
+		missed[i] = job.missed
+		job.missed = 0
+	}
+	s.mu.Unlock()
 
-	if n != 0 {
-		for i := 0; i < n; i++ {
-			go runnableJobs[i].run()
-			runnableJobs[i].lastRun = time.Now()
-			runnableJobs[i].scheduleNextRun()
+	for i, job := range runnableJobs {
+		// save the next run before running, so a crash during the run does not run it again
+		if err := job.saveState(); err != nil && s.onError != nil {
+			s.onError(job, err)
 		}
+		if job.IsPaused() {
+			continue
+		}
+		job.startMissed(missed[i])
 	}
 }
 
+// Trigger runs the job j immediately, even if it is paused, without changing its schedule
+func (s *Scheduler) Trigger(j *Job) {
+	j.start()
+}
+
 // RunAll run all jobs regardless if they are scheduled to run or not
 func (s *Scheduler) RunAll() {
 	s.RunAllwithDelay(0)
@@ -101,8 +187,8 @@ func (s *Scheduler) RunAll() {
 
 // RunAllwithDelay runs all jobs with delay seconds
 func (s *Scheduler) RunAllwithDelay(d int) {
-	for i := 0; i < s.size; i++ {
-		go s.jobs[i].run()
+	for _, job := range s.Jobs() {
+		job.start()
 		if 0 != d {
 			time.Sleep(time.Duration(d))
 		}
@@ -136,34 +222,24 @@ func (s *Scheduler) RemoveByTag(t string) {
 }
 
 func (s *Scheduler) removeByCondition(shouldRemove func(*Job) bool) {
-	i := 0
-
-	// keep deleting until no more jobs match the criteria
-	for {
-		found := false
-
-		for ; i < s.size; i++ {
-			if shouldRemove(s.jobs[i]) {
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			return
-		}
-
-		for j := (i + 1); j < s.size; j++ {
-			s.jobs[i] = s.jobs[j]
-			i++
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.jobs[:0]
+	for _, job := range s.jobs {
+		if !shouldRemove(job) {
+			kept = append(kept, job)
 		}
-		s.size--
-		s.jobs[s.size] = nil
 	}
+	for i := len(kept); i < len(s.jobs); i++ {
+		s.jobs[i] = nil
+	}
+	s.jobs = kept
 }
 
 // Scheduled checks if specific job j was already added
 func (s *Scheduler) Scheduled(j interface{}) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, job := range s.jobs {
 		if job.jobFunc == getFunctionName(j) {
 			return true
@@ -174,31 +250,76 @@ func (s *Scheduler) Scheduled(j interface{}) bool {
 
 // Clear delete all scheduled jobs
 func (s *Scheduler) Clear() {
-	for i := 0; i < s.size; i++ {
-		s.jobs[i] = nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = nil
+}
+
+// Metrics sums up the metrics of all jobs
+func (s *Scheduler) Metrics() SchedulerMetrics {
+	var m SchedulerMetrics
+	for _, job := range s.Jobs() {
+		jm := job.Metrics()
+		m.Jobs++
+		if job.IsPaused() {
+			m.Paused++
+		}
+		m.Running += jm.Running
+		m.Runs += jm.Runs
+		m.Failures += jm.Failures
+		m.Skipped += jm.Skipped
 	}
-	s.size = 0
+	return m
 }
 
-// Start all the pending jobs
-// Add seconds ticker
-func (s *Scheduler) Start() chan bool {
-	stopped := make(chan bool, 1)
-	ticker := time.NewTicker(1 * time.Second)
+// Start runs the pending jobs every second until ctx is done.
+// ctx is also the parent of the context passed to the jobs, so cancelling it cancels the runs in progress.
+// The returned channel is closed once the ticker has stopped and all runs in progress have returned
+func (s *Scheduler) Start(ctx context.Context) <-chan struct{} {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
 				s.RunPending()
-			case <-stopped:
-				ticker.Stop()
+			case <-ctx.Done():
+				s.wg.Wait()
 				return
 			}
 		}
 	}()
+	return done
+}
+
+func (s *Scheduler) context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
 
-	return stopped
+// submit runs task on the pool, or in a new goroutine without a pool
+func (s *Scheduler) submit(task func()) error {
+	s.wg.Add(1)
+	run := func() {
+		defer s.wg.Done()
+		task()
+	}
+	if s.pool == nil {
+		go run()
+		return nil
+	}
+	if err := s.pool.Submit(run); err != nil {
+		s.wg.Done()
+		return err
+	}
+	return nil
 }
 
 // The following methods are shortcuts for not having to
@@ -209,6 +330,11 @@ func Every(interval uint64) *Job {
 	return defaultScheduler.Every(interval)
 }
 
+// Cron schedules a new job with a crontab expression
+func Cron(spec string) *Job {
+	return defaultScheduler.Cron(spec)
+}
+
 // RunPending run all jobs that are scheduled to run
 //
 // Please note that it is *intended behavior that run_pending()
@@ -234,9 +360,14 @@ func RunAllwithDelay(d int) {
 	defaultScheduler.RunAllwithDelay(d)
 }
 
-// Start run all jobs that are scheduled to run
-func Start() chan bool {
-	return defaultScheduler.Start()
+// Start run all jobs that are scheduled to run until ctx is done
+func Start(ctx context.Context) <-chan struct{} {
+	return defaultScheduler.Start(ctx)
+}
+
+// Trigger runs the job j of the default scheduler immediately
+func Trigger(j *Job) {
+	defaultScheduler.Trigger(j)
 }
 
 // Clear all scheduled jobs
@@ -251,12 +382,7 @@ func Remove(j interface{}) {
 
 // Scheduled checks if specific job j was already added
 func Scheduled(j interface{}) bool {
-	for _, job := range defaultScheduler.jobs {
-		if job.jobFunc == getFunctionName(j) {
-			return true
-		}
-	}
-	return false
+	return defaultScheduler.Scheduled(j)
 }
 
 // NextRun gets the next running time
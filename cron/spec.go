@@ -0,0 +1,413 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCronSpec is wrapped by all errors returned from ParseCron
+var ErrCronSpec = errors.New("invalid cron spec")
+
+// Schedule describes a job's duty cycle.
+type Schedule interface {
+	// Next returns the next activation time, later than the given time.
+	// A zero time means the schedule will never fire again.
+	Next(t time.Time) time.Time
+}
+
+// EverySchedule fires at a fixed interval, as produced by "@every <duration>".
+type EverySchedule struct {
+	Interval time.Duration
+}
+
+// Next returns the time one interval after t
+func (s EverySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.Interval)
+}
+
+// SpecSchedule is a parsed crontab expression.
+// All times are evaluated as wall clock times in the location of the time passed to Next,
+// so a job keeps its local time across DST transitions: a time skipped by the transition is
+// shifted forward by the length of the gap, and a repeated time fires only once, at its first occurrence.
+type SpecSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+
+	domStar, dowStar bool
+	lastDom          bool      // L in day-of-month
+	lastWeekdayDom   bool      // LW in day-of-month
+	nearestWeekday   uint64    // nW in day-of-month, the weekday nearest to day n
+	lastDow          uint64    // nL in day-of-week, the last weekday n of the month
+	nthDow           [5]uint64 // n#k in day-of-week, the k-th weekday n of the month
+}
+
+type fieldBounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	secondBounds = fieldBounds{0, 59, nil}
+	minuteBounds = fieldBounds{0, 59, nil}
+	hourBounds   = fieldBounds{0, 23, nil}
+	domBounds    = fieldBounds{1, 31, nil}
+	monthBounds  = fieldBounds{1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted as Sunday and folded into 0
+	dowBounds = fieldBounds{0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseCron parses a crontab expression, it accepts
+//
+//   - 5 fields: minute hour day-of-month month day-of-week
+//   - 6 fields: second minute hour day-of-month month day-of-week
+//   - descriptors: @yearly, @annually, @monthly, @weekly, @daily, @midnight, @hourly, @every <duration>
+//
+// Fields support *, ?, lists, ranges, steps and the names JAN-DEC and SUN-SAT.
+// Day-of-month also supports L (last day), LW (last weekday) and nW (weekday nearest to day n),
+// so "15 2 1W * *" runs at 02:15 on the first business day of each month.
+// Day-of-week also supports nL (last weekday n of the month) and n#k (the k-th weekday n).
+// As in standard cron, when both day fields are restricted a day matching either of them fires.
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %v", ErrCronSpec, spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("%w %q: interval must be at least 1s", ErrCronSpec, spec)
+		}
+		return EverySchedule{Interval: d}, nil
+	}
+	expr := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expr, ok = descriptors[strings.ToLower(spec)]; !ok {
+			return nil, fmt.Errorf("%w %q: unknown descriptor", ErrCronSpec, spec)
+		}
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%w %q: expected 5 or 6 fields, found %d", ErrCronSpec, spec, len(fields))
+	}
+
+	s := &SpecSchedule{}
+	var err error
+	if s.second, _, err = parseField(fields[0], secondBounds); err != nil {
+		return nil, fmt.Errorf("%w %q: second: %v", ErrCronSpec, spec, err)
+	}
+	if s.minute, _, err = parseField(fields[1], minuteBounds); err != nil {
+		return nil, fmt.Errorf("%w %q: minute: %v", ErrCronSpec, spec, err)
+	}
+	if s.hour, _, err = parseField(fields[2], hourBounds); err != nil {
+		return nil, fmt.Errorf("%w %q: hour: %v", ErrCronSpec, spec, err)
+	}
+	if err = s.parseDom(fields[3]); err != nil {
+		return nil, fmt.Errorf("%w %q: day-of-month: %v", ErrCronSpec, spec, err)
+	}
+	if s.month, _, err = parseField(fields[4], monthBounds); err != nil {
+		return nil, fmt.Errorf("%w %q: month: %v", ErrCronSpec, spec, err)
+	}
+	if err = s.parseDow(fields[5]); err != nil {
+		return nil, fmt.Errorf("%w %q: day-of-week: %v", ErrCronSpec, spec, err)
+	}
+	if !s.anyDay() {
+		return nil, fmt.Errorf("%w %q: no day matches the day-of-month, month and day-of-week", ErrCronSpec, spec)
+	}
+	return s, nil
+}
+
+// MustParseCron is like ParseCron but panics if the spec cannot be parsed
+func MustParseCron(spec string) Schedule {
+	s, err := ParseCron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *SpecSchedule) parseDom(field string) error {
+	var plain []string
+	for _, part := range strings.Split(field, ",") {
+		upper := strings.ToUpper(part)
+		switch {
+		case upper == "L":
+			s.lastDom = true
+		case upper == "LW":
+			s.lastWeekdayDom = true
+		case strings.HasSuffix(upper, "W"):
+			n, err := parseValue(upper[:len(upper)-1], domBounds)
+			if err != nil {
+				return err
+			}
+			s.nearestWeekday |= 1 << n
+		default:
+			plain = append(plain, part)
+		}
+	}
+	if len(plain) > 0 {
+		var err error
+		if s.dom, s.domStar, err = parseField(strings.Join(plain, ","), domBounds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SpecSchedule) parseDow(field string) error {
+	var plain []string
+	for _, part := range strings.Split(field, ",") {
+		upper := strings.ToUpper(part)
+		if i := strings.Index(upper, "#"); i > 0 {
+			n, err := parseValue(upper[:i], dowBounds)
+			if err != nil {
+				return err
+			}
+			k, err := strconv.Atoi(upper[i+1:])
+			if err != nil || k < 1 || k > 5 {
+				return fmt.Errorf("invalid occurrence in %q", part)
+			}
+			s.nthDow[k-1] |= 1 << (n % 7)
+		} else if len(upper) > 1 && strings.HasSuffix(upper, "L") {
+			n, err := parseValue(upper[:len(upper)-1], dowBounds)
+			if err != nil {
+				return err
+			}
+			s.lastDow |= 1 << (n % 7)
+		} else {
+			plain = append(plain, part)
+		}
+	}
+	if len(plain) > 0 {
+		var err error
+		if s.dow, s.dowStar, err = parseField(strings.Join(plain, ","), dowBounds); err != nil {
+			return err
+		}
+		if s.dow&(1<<7) != 0 {
+			s.dow = s.dow&^(1<<7) | 1
+		}
+	}
+	return nil
+}
+
+// parseField returns the bits of a comma separated list of *, ?, values, ranges and steps
+func parseField(field string, b fieldBounds) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, false, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], uint(n)
+		}
+		var lo, hi uint
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			lo, hi = b.min, b.max
+			if step == 1 {
+				star = true
+			}
+		case strings.Contains(rangePart, "-"):
+			i := strings.Index(rangePart, "-")
+			if lo, err = parseValue(rangePart[:i], b); err != nil {
+				return 0, false, err
+			}
+			if hi, err = parseValue(rangePart[i+1:], b); err != nil {
+				return 0, false, err
+			}
+			if lo > hi {
+				return 0, false, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			if lo, err = parseValue(rangePart, b); err != nil {
+				return 0, false, err
+			}
+			// "5/15" means from 5 to the max every 15
+			hi = lo
+			if strings.Contains(part, "/") {
+				hi = b.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, star, nil
+}
+
+func parseValue(s string, b fieldBounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if n < int(b.min) || n > int(b.max) {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", n, b.min, b.max)
+	}
+	return uint(n), nil
+}
+
+// Next returns the next wall clock time matching the spec in t's location, later than t
+func (s *SpecSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+	y, mo, d, h, mi := year, int(month), day, hour, min
+	sec++
+
+	// give up when nothing matches within 5 years, e.g. "0 0 30 2 *"
+	for y <= year+5 {
+		if sec > 59 {
+			sec, mi = 0, mi+1
+		}
+		if mi > 59 {
+			mi, h = 0, h+1
+		}
+		if h > 23 {
+			h, d = 0, d+1
+		}
+		if mo <= 12 && d > daysIn(y, mo) {
+			d, mo = 1, mo+1
+		}
+		if mo > 12 {
+			y, mo, d = y+1, 1, 1
+			continue
+		}
+		if s.month&(1<<uint(mo)) == 0 {
+			mo, d, h, mi, sec = mo+1, 1, 0, 0, 0
+			continue
+		}
+		if !s.dayMatches(y, mo, d) {
+			d, h, mi, sec = d+1, 0, 0, 0
+			continue
+		}
+		if s.hour&(1<<uint(h)) == 0 {
+			h, mi, sec = h+1, 0, 0
+			continue
+		}
+		if s.minute&(1<<uint(mi)) == 0 {
+			mi, sec = mi+1, 0
+			continue
+		}
+		if s.second&(1<<uint(sec)) == 0 {
+			sec++
+			continue
+		}
+		next := time.Date(y, time.Month(mo), d, h, mi, sec, 0, loc)
+		_, before := next.Add(-12 * time.Hour).Zone()
+		_, after := next.Add(12 * time.Hour).Zone()
+		if !sameClock(next, h, mi, sec) {
+			// the time is inside a DST gap, shift it forward by the length of the gap
+			if after < before {
+				before = after
+			}
+			next = time.Date(y, time.Month(mo), d, h, mi, sec, 0, time.UTC).Add(-time.Duration(before) * time.Second).In(loc)
+		} else if after < before {
+			// the time may be repeated when the clocks fall back, take the earlier instant that is
+			// after t, so the first pass fires and the second pass is skipped by the next call
+			shift := time.Duration(before-after) * time.Second
+			for _, c := range []time.Time{next.Add(-shift), next, next.Add(shift)} {
+				if sameClock(c, h, mi, sec) && c.After(t) {
+					return c
+				}
+			}
+		}
+		if next.After(t) {
+			return next
+		}
+		sec++
+	}
+	return time.Time{}
+}
+
+// anyDay reports whether some day in a 4 year window, which includes a leap year, matches the spec,
+// so specs such as "0 0 30 2 *" are rejected instead of never firing
+func (s *SpecSchedule) anyDay() bool {
+	for y := 2024; y < 2028; y++ {
+		for mo := 1; mo <= 12; mo++ {
+			if s.month&(1<<uint(mo)) == 0 {
+				continue
+			}
+			for d := 1; d <= daysIn(y, mo); d++ {
+				if s.dayMatches(y, mo, d) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func sameClock(t time.Time, h, mi, sec int) bool {
+	return t.Hour() == h && t.Minute() == mi && t.Second() == sec
+}
+
+func (s *SpecSchedule) dayMatches(y, mo, d int) bool {
+	last := daysIn(y, mo)
+	wd := uint(time.Date(y, time.Month(mo), d, 12, 0, 0, 0, time.UTC).Weekday())
+
+	domMatch := s.dom&(1<<uint(d)) != 0 || (s.lastDom && d == last)
+	if s.lastWeekdayDom && d == nearestWeekday(y, mo, last) {
+		domMatch = true
+	}
+	for n := 1; n <= last && s.nearestWeekday != 0 && !domMatch; n++ {
+		if s.nearestWeekday&(1<<uint(n)) != 0 && nearestWeekday(y, mo, n) == d {
+			domMatch = true
+		}
+	}
+
+	dowMatch := s.dow&(1<<wd) != 0 ||
+		(s.lastDow&(1<<wd) != 0 && d+7 > last) ||
+		s.nthDow[(d-1)/7]&(1<<wd) != 0
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// nearestWeekday returns the weekday nearest to day n without leaving the month
+func nearestWeekday(y, mo, n int) int {
+	last := daysIn(y, mo)
+	switch time.Date(y, time.Month(mo), n, 12, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if n == 1 {
+			return 3
+		}
+		return n - 1
+	case time.Sunday:
+		if n == last {
+			return n - 2
+		}
+		return n + 1
+	}
+	return n
+}
+
+func daysIn(y, mo int) int {
+	return time.Date(y, time.Month(mo)+1, 0, 12, 0, 0, 0, time.UTC).Day()
+}
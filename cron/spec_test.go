@@ -0,0 +1,98 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseCronNeverMatches(t *testing.T) {
+	for _, spec := range []string{"0 0 30 2 *", "0 0 31 4 *", "0 0 31 2,4,6,9,11 *", "0 0 0 31 4 ?"} {
+		if _, err := ParseCron(spec); !errors.Is(err, ErrCronSpec) {
+			t.Errorf("%q: got %v, want %v", spec, err, ErrCronSpec)
+		}
+	}
+	for _, spec := range []string{"0 0 29 2 *", "0 0 31 * *", "0 0 30 2 1", "0 0 L 2 *"} {
+		if _, err := ParseCron(spec); err != nil {
+			t.Errorf("%q: %v", spec, err)
+		}
+	}
+
+	s := NewScheduler()
+	if err := s.Cron("0 0 30 2 *").Do(func() {}); !errors.Is(err, ErrCronSpec) {
+		t.Errorf("Do: got %v, want %v", err, ErrCronSpec)
+	}
+}
+
+func TestSpecScheduleDST(t *testing.T) {
+	const layout = "2006-01-02 15:04:05 -0700"
+	tests := []struct {
+		name string
+		spec string
+		zone string
+		from string
+		want []string
+	}{
+		{
+			name: "new york fall back fires the repeated 01:30 once",
+			spec: "30 1 * * *",
+			zone: "America/New_York",
+			from: "2024-11-03 00:00:00 -0400",
+			want: []string{"2024-11-03 01:30:00 -0400", "2024-11-04 01:30:00 -0500"},
+		},
+		{
+			name: "new york spring forward shifts 02:30 to 03:30",
+			spec: "30 2 * * *",
+			zone: "America/New_York",
+			from: "2024-03-10 00:00:00 -0500",
+			want: []string{"2024-03-10 03:30:00 -0400", "2024-03-11 02:30:00 -0400"},
+		},
+		{
+			name: "berlin fall back runs the repeated half hours once",
+			spec: "*/30 * * * *",
+			zone: "Europe/Berlin",
+			from: "2024-10-27 01:00:00 +0200",
+			want: []string{
+				"2024-10-27 01:30:00 +0200",
+				"2024-10-27 02:00:00 +0200",
+				"2024-10-27 02:30:00 +0200",
+				"2024-10-27 03:00:00 +0100",
+				"2024-10-27 03:30:00 +0100",
+			},
+		},
+		{
+			name: "berlin fall back skips the second pass of 02:30",
+			spec: "30 2 * * *",
+			zone: "Europe/Berlin",
+			from: "2024-10-27 02:30:00 +0200",
+			want: []string{"2024-10-28 02:30:00 +0100"},
+		},
+		{
+			name: "berlin spring forward shifts 02:30 to 03:30",
+			spec: "30 2 * * *",
+			zone: "Europe/Berlin",
+			from: "2024-03-31 00:00:00 +0100",
+			want: []string{"2024-03-31 03:30:00 +0200", "2024-04-01 02:30:00 +0200"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Skip(err)
+			}
+			from, err := time.Parse(layout, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := MustParseCron(tt.spec)
+			next := from.In(loc)
+			for _, want := range tt.want {
+				next = s.Next(next)
+				if got := next.Format(layout); got != want {
+					t.Fatalf("got %s, want %s", got, want)
+				}
+			}
+		})
+	}
+}
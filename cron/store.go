@@ -0,0 +1,250 @@
+package cron
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrJobNotFound is returned by a JobStore when no state is saved under the name
+var ErrJobNotFound = errors.New("the job is not found in the store")
+
+// neverRun is the next run of a job whose schedule never fires again,
+// it is saved as a nil next run with Never set since it cannot be encoded as json or a DATETIME
+var neverRun = time.Unix(1<<62, 0)
+
+// maxMisfireRuns bounds the runs started by MisfireFireAll after a long downtime
+const maxMisfireRuns = 1000
+
+// MisfirePolicy decides what happens to the runs a named job missed while no scheduler was running
+type MisfirePolicy int
+
+const (
+	// MisfireFireOnce runs the job once as soon as possible for all the missed runs, the default
+	MisfireFireOnce MisfirePolicy = iota
+	// MisfireFireAll runs the job once per missed run, the missed runs run one after another
+	// whatever the overlap policy
+	MisfireFireAll
+	// MisfireSkip drops the missed runs and waits for the next scheduled time
+	MisfireSkip
+)
+
+// JobState is the persisted definition and run state of a named job
+type JobState struct {
+	Name         string        `json:"name"`
+	Spec         string        `json:"spec"` // the cron expression, empty for jobs built with Every
+	Misfire      MisfirePolicy `json:"misfire"`
+	Paused       bool          `json:"paused"`
+	NextRun      time.Time     `json:"next_run"`
+	Never        bool          `json:"never"` // the schedule never fires again, NextRun is zero
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// JobStore persists the state of named jobs, so the schedule survives restarts
+type JobStore interface {
+	// Load returns ErrJobNotFound when nothing is saved under the name
+	Load(name string) (*JobState, error)
+	Save(state *JobState) error
+	List() ([]*JobState, error)
+	Delete(name string) error
+}
+
+type registeredFunc struct {
+	fn     interface{}
+	params []interface{}
+}
+
+// Name names the job, a named job saves its state to the scheduler's store and
+// restores it in Do, so it keeps its next run time across restarts
+//
+//	s.Cron("0 2 * * *").Name("daily-report").Misfire(cron.MisfireFireOnce).Do(report)
+func (j *Job) Name(name string) *Job {
+	j.name = name
+	return j
+}
+
+// GetName returns the name of the job
+func (j *Job) GetName() string {
+	return j.name
+}
+
+// Misfire sets what happens to the runs missed while no scheduler was running, only for named jobs
+func (j *Job) Misfire(policy MisfirePolicy) *Job {
+	j.misfire = policy
+	return j
+}
+
+func (j *Job) store() JobStore {
+	if j.name == "" || j.scheduler == nil {
+		return nil
+	}
+	return j.scheduler.store
+}
+
+// restore schedules the job from its saved state and applies the misfire policy
+func (j *Job) restore(store JobStore) error {
+	state, err := store.Load(j.name)
+	if err != nil && err != ErrJobNotFound {
+		return err
+	}
+	now := time.Now()
+	j.nextRun = time.Unix(0, 0)
+	if state != nil {
+		j.paused = state.Paused
+		if !state.LastRun.IsZero() {
+			j.lastRun = state.LastRun
+		}
+		j.metrics.LastRun = state.LastRun
+		j.metrics.LastDuration = state.LastDuration
+		if state.LastError != "" {
+			j.metrics.LastErr = errors.New(state.LastError)
+		}
+	}
+	switch {
+	case state != nil && state.Never && state.Spec == j.spec:
+		j.nextRun = neverRun
+	case state == nil || state.NextRun.IsZero() || state.Spec != j.spec:
+		// a new job, or the schedule has changed
+		err = j.scheduleNextRun()
+	case state.NextRun.After(now):
+		j.nextRun = state.NextRun
+	case j.misfire == MisfireSkip:
+		err = j.scheduleNextRun()
+	default:
+		j.nextRun = now
+		if j.misfire == MisfireFireAll {
+			j.missed = j.countRuns(state.NextRun, now) - 1
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return j.saveState()
+}
+
+// countRuns counts the scheduled times from from to now, both included
+func (j *Job) countRuns(from, now time.Time) int {
+	n := 0
+	for t := from; !t.After(now) && n < maxMisfireRuns; n++ {
+		if j.schedule != nil {
+			t = j.schedule.Next(t.In(j.loc))
+			if t.IsZero() {
+				return n + 1
+			}
+			continue
+		}
+		period, err := j.periodDuration()
+		if err != nil || period <= 0 {
+			return n + 1
+		}
+		t = t.Add(period)
+	}
+	return n
+}
+
+func (j *Job) saveState() error {
+	store := j.store()
+	if store == nil {
+		return nil
+	}
+	j.scheduler.mu.Lock()
+	next := j.nextRun
+	j.scheduler.mu.Unlock()
+	j.mu.Lock()
+	state := &JobState{
+		Name:         j.name,
+		Spec:         j.spec,
+		Misfire:      j.misfire,
+		Paused:       j.paused,
+		NextRun:      next,
+		LastRun:      j.metrics.LastRun,
+		LastDuration: j.metrics.LastDuration,
+		UpdatedAt:    time.Now(),
+	}
+	if next.Equal(neverRun) {
+		state.NextRun, state.Never = time.Time{}, true
+	}
+	if j.metrics.LastErr != nil {
+		state.LastError = j.metrics.LastErr.Error()
+	}
+	j.mu.Unlock()
+	return store.Save(state)
+}
+
+// SetStore sets the store of named jobs, call it before scheduling them
+func (s *Scheduler) SetStore(store JobStore) {
+	s.store = store
+}
+
+// Register binds a function to a job name, so Restore can schedule the jobs saved in the store.
+// Named jobs scheduled with Do are registered automatically
+func (s *Scheduler) Register(name string, jobFun interface{}, params ...interface{}) error {
+	if typ := reflect.TypeOf(jobFun); typ == nil || typ.Kind() != reflect.Func {
+		return ErrNotAFunction
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.registry == nil {
+		s.registry = make(map[string]registeredFunc)
+	}
+	s.registry[name] = registeredFunc{fn: jobFun, params: params}
+	return nil
+}
+
+// Restore schedules the cron jobs saved in the store whose functions are registered.
+// Jobs already in the scheduler, jobs built with Every and jobs without a registered function are left alone
+//
+//	s.SetStore(cron.NewDBJobStore(db))
+//	s.Register("daily-report", report)
+//	s.Restore()
+//	s.Start(ctx)
+func (s *Scheduler) Restore() error {
+	if s.store == nil {
+		return nil
+	}
+	states, err := s.store.List()
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		s.mu.Lock()
+		f, ok := s.registry[state.Name]
+		s.mu.Unlock()
+		if !ok || state.Spec == "" || s.JobByName(state.Name) != nil {
+			continue
+		}
+		if err = s.Cron(state.Spec).Name(state.Name).Misfire(state.Misfire).Do(f.fn, f.params...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JobByName returns the job with the name, nil if not found
+func (s *Scheduler) JobByName(name string) *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// Delete removes the named job from the scheduler and its state from the store
+func (s *Scheduler) Delete(name string) error {
+	s.removeByCondition(func(someJob *Job) bool {
+		return someJob.name == name
+	})
+	if s.store == nil {
+		return nil
+	}
+	if err := s.store.Delete(name); err != nil && err != ErrJobNotFound {
+		return err
+	}
+	return nil
+}
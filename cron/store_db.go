@@ -0,0 +1,112 @@
+package cron
+
+import (
+	"time"
+
+	"github.com/AbnerEarl/goutils/dbs"
+)
+
+// CronJobModel is the table of DBJobStore
+type CronJobModel struct {
+	Name         string     `json:"name" gorm:"primaryKey;column:name;type:varchar(128);comment:'任务名称'"`
+	Spec         string     `json:"spec" gorm:"column:spec;type:varchar(255);comment:'cron 表达式'"`
+	Misfire      int        `json:"misfire" gorm:"column:misfire;comment:'错过执行的处理策略'"`
+	Paused       bool       `json:"paused" gorm:"column:paused;comment:'是否暂停'"`
+	NextRun      *time.Time `json:"next_run" gorm:"column:next_run;null;comment:'下次执行时间'"`
+	Never        bool       `json:"never" gorm:"column:never;comment:'是否不再执行'"`
+	LastRun      *time.Time `json:"last_run" gorm:"column:last_run;null;comment:'上次执行时间'"`
+	LastDuration int64      `json:"last_duration" gorm:"column:last_duration;comment:'上次执行耗时（毫秒）'"`
+	LastError    string     `json:"last_error" gorm:"column:last_error;type:text;null;comment:'上次执行的错误'"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"column:updated_at;comment:'更新时间'"`
+}
+
+func (m *CronJobModel) TableName() string {
+	return "cron_job"
+}
+
+// DBJobStore saves the jobs in the cron_job table
+type DBJobStore struct {
+	db *dbs.DB
+}
+
+func NewDBJobStore(db *dbs.DB) *DBJobStore {
+	//use example:
+	//db.Migration([]interface{}{&cron.CronJobModel{}})
+	//s.SetStore(cron.NewDBJobStore(db))
+	return &DBJobStore{db: db}
+}
+
+func (s *DBJobStore) Load(name string) (*JobState, error) {
+	var models []*CronJobModel
+	if err := s.db.DB.Where("name = ?", name).Limit(1).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, ErrJobNotFound
+	}
+	return models[0].state(), nil
+}
+
+func (s *DBJobStore) Save(state *JobState) error {
+	model := &CronJobModel{
+		Name:         state.Name,
+		Spec:         state.Spec,
+		Misfire:      int(state.Misfire),
+		Paused:       state.Paused,
+		Never:        state.Never,
+		LastDuration: state.LastDuration.Milliseconds(),
+		LastError:    state.LastError,
+		UpdatedAt:    state.UpdatedAt,
+	}
+	if !state.NextRun.IsZero() {
+		model.NextRun = &state.NextRun
+	}
+	if !state.LastRun.IsZero() {
+		model.LastRun = &state.LastRun
+	}
+	// Save inserts the row when no row is updated
+	return s.db.DB.Save(model).Error
+}
+
+func (s *DBJobStore) List() ([]*JobState, error) {
+	var models []*CronJobModel
+	if err := s.db.DB.Order("name").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	states := make([]*JobState, len(models))
+	for i, model := range models {
+		states[i] = model.state()
+	}
+	return states, nil
+}
+
+func (s *DBJobStore) Delete(name string) error {
+	result := s.db.DB.Where("name = ?", name).Delete(&CronJobModel{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+func (m *CronJobModel) state() *JobState {
+	state := &JobState{
+		Name:         m.Name,
+		Spec:         m.Spec,
+		Misfire:      MisfirePolicy(m.Misfire),
+		Paused:       m.Paused,
+		Never:        m.Never,
+		LastDuration: time.Duration(m.LastDuration) * time.Millisecond,
+		LastError:    m.LastError,
+		UpdatedAt:    m.UpdatedAt,
+	}
+	if m.NextRun != nil {
+		state.NextRun = *m.NextRun
+	}
+	if m.LastRun != nil {
+		state.LastRun = *m.LastRun
+	}
+	return state
+}
@@ -0,0 +1,88 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/AbnerEarl/goutils/redisc"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisJobStore saves the jobs as json in one redis hash, the field is the job name
+type RedisJobStore struct {
+	cli redis.Cmdable
+	key string
+}
+
+// NewRedisJobStore cli can be a *redisc.RedisCli, *redisc.RedisClusterCli or *redisc.UniversalClient,
+// key is "cron:jobs" when empty
+func NewRedisJobStore(cli redis.Cmdable, key string) *RedisJobStore {
+	//use example:
+	//s.SetStore(cron.NewRedisJobStore(rdb, "cron:jobs"))
+	if key == "" {
+		key = "cron:jobs"
+	}
+	return &RedisJobStore{cli: cli, key: key}
+}
+
+func (s *RedisJobStore) Load(name string) (*JobState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	data, err := s.cli.HGet(ctx, s.key, name).Bytes()
+	if err == redis.Nil {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &JobState{}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *RedisJobStore) Save(state *JobState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	return s.cli.HSet(ctx, s.key, state.Name, data).Err()
+}
+
+func (s *RedisJobStore) List() ([]*JobState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	values, err := s.cli.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*JobState, 0, len(values))
+	for _, data := range values {
+		state := &JobState{}
+		if err = json.Unmarshal([]byte(data), state); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states, nil
+}
+
+func (s *RedisJobStore) Delete(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	n, err := s.cli.HDel(ctx, s.key, name).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps the states as json, like RedisJobStore
+type memoryStore struct {
+	mu     sync.Mutex
+	states map[string][]byte
+}
+
+func (s *memoryStore) Load(name string) (*JobState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.states[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	state := &JobState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *memoryStore) Save(state *JobState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = make(map[string][]byte)
+	}
+	s.states[state.Name] = data
+	return nil
+}
+
+func (s *memoryStore) List() ([]*JobState, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, name)
+	return nil
+}
+
+// neverSchedule never fires
+type neverSchedule struct{}
+
+func (neverSchedule) Next(time.Time) time.Time {
+	return time.Time{}
+}
+
+func TestSaveStateNever(t *testing.T) {
+	store := &memoryStore{}
+	s := NewScheduler()
+	s.SetStore(store)
+	job := s.Cron("0 0 1 1 *").Name("never")
+	job.schedule = neverSchedule{}
+	if err := job.Do(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	state, err := store.Load("never")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Never || !state.NextRun.IsZero() {
+		t.Fatalf("got never %v, next run %v", state.Never, state.NextRun)
+	}
+
+	s = NewScheduler()
+	s.SetStore(store)
+	job = s.Cron("0 0 1 1 *").Name("never")
+	if err = job.Do(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if !job.nextRun.Equal(neverRun) {
+		t.Errorf("restored next run %v, want never", job.nextRun)
+	}
+
+	// a new spec is scheduled again
+	s = NewScheduler()
+	s.SetStore(store)
+	job = s.Cron("0 0 2 1 *").Name("never")
+	if err = job.Do(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = store.Load("never"); err != nil {
+		t.Fatal(err)
+	}
+	if state.Never || !state.NextRun.Equal(job.nextRun) {
+		t.Errorf("got never %v, next run %v, want %v", state.Never, state.NextRun, job.nextRun)
+	}
+}
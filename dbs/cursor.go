@@ -0,0 +1,271 @@
+package dbs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+)
+
+var ErrCursorInvalid = errors.New("the cursor is invalid or does not match the current order")
+
+const (
+	cursorNext = "n"
+	cursorPrev = "p"
+)
+
+type cursorPayload struct {
+	Order     string            `json:"o"`
+	Direction string            `json:"d"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+type seekColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+type CursorResult[T any] struct {
+	Items      []*T   `json:"items"`
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+	HasNext    bool   `json:"has_next"`
+	HasPrev    bool   `json:"has_prev"`
+}
+
+func (db *DB) RetrieveByModelCursor(pageSize int, cursor string, whereModel interface{}, orders ...string) (interface{}, string, string, error) {
+	//use example:
+	//m := dbs.UpdateModel{}
+	//result, next, prev, err := RetrieveByModelCursor(20, "", &m, "execute_time desc", "id desc")
+	//dataList := result.(*[]*dbs.UpdateModel)
+	//再次请求时传入 next 或 prev 即可向后或向前翻页
+	return db.seekPage(db.Model(whereModel).Where(whereModel), whereModel, pageSize, cursor, orders)
+}
+
+func (db *DB) RetrieveByWhereCursor(pageSize int, cursor string, dataModel interface{}, where string, args []interface{}, orders ...string) (interface{}, string, string, error) {
+	//use example:
+	//m := dbs.UpdateModel{}
+	//result, next, prev, err := RetrieveByWhereCursor(20, "", &m, "file_name like ?", []interface{}{"%.sql"}, "id")
+	//dataList := result.(*[]*dbs.UpdateModel)
+	return db.seekPage(db.Model(dataModel).Where(where, args...), dataModel, pageSize, cursor, orders)
+}
+
+// ListByCursor 基于游标（keyset）分页，orders 为排序列，例如 "created_at desc"，未包含主键时自动追加主键保证顺序唯一
+func (r *Repository[T]) ListByCursor(pageSize int, cursor string, orders []string, options ...QueryOption) (*CursorResult[T], error) {
+	opts := loadQueryOptions(options...)
+	tx := r.scope(opts)
+	if len(opts.fields) > 0 {
+		tx = tx.Select(opts.fields)
+	}
+	items, next, prev, err := r.db.seekPage(tx, new(T), pageSize, cursor, orders)
+	if err != nil {
+		return nil, err
+	}
+	pageSize, _ = normalizePage(pageSize, 1)
+	return &CursorResult[T]{
+		Items:      *(items.(*[]*T)),
+		PageSize:   pageSize,
+		NextCursor: next,
+		PrevCursor: prev,
+		HasNext:    next != "",
+		HasPrev:    prev != "",
+	}, nil
+}
+
+func (db *DB) seekPage(tx *gorm.DB, model interface{}, pageSize int, cursor string, orders []string) (interface{}, string, string, error) {
+	pageSize, _ = normalizePage(pageSize, 1)
+	stmt := &gorm.Statement{DB: db.DB}
+	if err := stmt.Parse(model); err != nil {
+		return nil, "", "", err
+	}
+	columns, err := parseSeekColumns(stmt.Schema, orders)
+	if err != nil {
+		return nil, "", "", err
+	}
+	orderSign := seekOrderSign(columns)
+
+	direction := cursorNext
+	if cursor != "" {
+		payload, err := decodeCursor(cursor, orderSign, len(columns))
+		if err != nil {
+			return nil, "", "", err
+		}
+		direction = payload.Direction
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			value := reflect.New(column.field.FieldType)
+			if err = json.Unmarshal(payload.Values[i], value.Interface()); err != nil {
+				return nil, "", "", ErrCursorInvalid
+			}
+			values[i] = value.Elem().Interface()
+		}
+		where, args := seekCondition(stmt, columns, values, direction == cursorPrev)
+		tx = tx.Where(where, args...)
+	}
+	for _, column := range columns {
+		desc := column.desc
+		if direction == cursorPrev {
+			desc = !desc
+		}
+		if desc {
+			tx = tx.Order(stmt.Quote(column.field.DBName) + " DESC")
+		} else {
+			tx = tx.Order(stmt.Quote(column.field.DBName) + " ASC")
+		}
+	}
+
+	typ := reflect.TypeOf(model)
+	if typ.Kind() != reflect.Ptr {
+		typ = reflect.PtrTo(typ)
+	}
+	results := reflect.New(reflect.SliceOf(typ))
+	if err = tx.Limit(pageSize + 1).Find(results.Interface()).Error; err != nil {
+		return nil, "", "", err
+	}
+	list := results.Elem()
+	hasMore := list.Len() > pageSize
+	if hasMore {
+		list.Set(list.Slice(0, pageSize))
+	}
+	if direction == cursorPrev {
+		swap := reflect.Swapper(list.Interface())
+		for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	hasNext, hasPrev := hasMore, cursor != ""
+	if direction == cursorPrev {
+		hasNext, hasPrev = true, hasMore
+	}
+	var next, prev string
+	if list.Len() > 0 {
+		ctx := context.Background()
+		if hasNext {
+			if next, err = encodeCursor(ctx, columns, list.Index(list.Len()-1), cursorNext, orderSign); err != nil {
+				return nil, "", "", err
+			}
+		}
+		if hasPrev {
+			if prev, err = encodeCursor(ctx, columns, list.Index(0), cursorPrev, orderSign); err != nil {
+				return nil, "", "", err
+			}
+		}
+	}
+	return results.Interface(), next, prev, nil
+}
+
+func parseSeekColumns(sch *schema.Schema, orders []string) ([]seekColumn, error) {
+	var columns []seekColumn
+	hasPrimary := false
+	primary := sch.PrioritizedPrimaryField
+	if primary == nil {
+		primary = sch.LookUpField("id")
+	}
+	for _, order := range orders {
+		parts := strings.Fields(order)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("invalid cursor order: %s", order)
+		}
+		field := sch.LookUpField(parts[0])
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("unknown cursor column: %s", parts[0])
+		}
+		desc := false
+		if len(parts) == 2 {
+			switch strings.ToLower(parts[1]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return nil, fmt.Errorf("invalid cursor order: %s", order)
+			}
+		}
+		if field == primary {
+			hasPrimary = true
+		}
+		columns = append(columns, seekColumn{field: field, desc: desc})
+	}
+	if !hasPrimary {
+		if primary == nil {
+			return nil, fmt.Errorf("the model %s has no primary key for cursor pagination", sch.Name)
+		}
+		columns = append(columns, seekColumn{field: primary})
+	}
+	return columns, nil
+}
+
+func seekOrderSign(columns []seekColumn) string {
+	signs := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if column.desc {
+			signs = append(signs, column.field.DBName+" desc")
+		} else {
+			signs = append(signs, column.field.DBName)
+		}
+	}
+	return strings.Join(signs, ",")
+}
+
+// seekCondition 生成 (a > ?) OR (a = ? AND b > ?) ... 形式的条件，兼容各列排序方向不同的情况
+func seekCondition(stmt *gorm.Statement, columns []seekColumn, values []interface{}, backward bool) (string, []interface{}) {
+	var ors []string
+	var args []interface{}
+	for i, column := range columns {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, stmt.Quote(columns[j].field.DBName)+" = ?")
+			args = append(args, values[j])
+		}
+		op := ">"
+		if column.desc != backward {
+			op = "<"
+		}
+		ands = append(ands, fmt.Sprintf("%s %s ?", stmt.Quote(column.field.DBName), op))
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func encodeCursor(ctx context.Context, columns []seekColumn, item reflect.Value, direction, orderSign string) (string, error) {
+	payload := cursorPayload{Order: orderSign, Direction: direction}
+	item = reflect.Indirect(item)
+	for _, column := range columns {
+		value, _ := column.field.ValueOf(ctx, item)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		payload.Values = append(payload.Values, raw)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor, orderSign string, size int) (*cursorPayload, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrCursorInvalid
+	}
+	payload := &cursorPayload{}
+	if err = json.Unmarshal(data, payload); err != nil {
+		return nil, ErrCursorInvalid
+	}
+	if payload.Order != orderSign || (payload.Direction != cursorNext && payload.Direction != cursorPrev) {
+		return nil, ErrCursorInvalid
+	}
+	if len(payload.Values) != size {
+		return nil, ErrCursorInvalid
+	}
+	return payload, nil
+}
@@ -0,0 +1,455 @@
+package dbs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMigrationLocked   = errors.New("the migration lock is held by another process")
+	ErrMigrationLockLost = errors.New("the migration lock has been lost")
+	ErrChecksumMismatch  = errors.New("the checksum of an applied migration has changed")
+	ErrDuplicateVersion  = errors.New("duplicate migration version")
+	ErrMissingDownScript = errors.New("the migration has no down script")
+	ErrUnknownMigration  = errors.New("the applied migration file does not exist")
+)
+
+var DefaultMigrationOwner = "migrator"
+
+// 迁移文件命名规则: <version>_<name>.up.sql / <version>_<name>.down.sql，不带 up/down 的 <version>_<name>.sql 视为只有 up 脚本
+var migrationFileReg = regexp.MustCompile(`^(\d+)_(.+?)(\.(up|down))?\.sql$`)
+
+type MigrationLockModel struct {
+	Id       uint64    `json:"id" gorm:"primary_key;column:id;comment:'主键ID'"`
+	Owner    string    `json:"owner" gorm:"column:owner;null;comment:'持有者'"`
+	LockedAt time.Time `json:"locked_at" gorm:"column:locked_at;null;comment:'加锁时间'"`
+}
+
+func (m *MigrationLockModel) TableName() string {
+	return "migration_lock"
+}
+
+type Migration struct {
+	Version  uint64 `json:"version"`
+	Name     string `json:"name"`
+	FileName string `json:"file_name"`
+	UpFile   string `json:"up_file"`
+	DownFile string `json:"down_file"`
+	Checksum string `json:"checksum"`
+}
+
+type MigrationStatus struct {
+	*Migration
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at"`
+	Drifted   bool      `json:"drifted"`
+}
+
+type Migrator struct {
+	db          *DB
+	BasePath    string        // 迁移文件所在目录，包含子目录
+	Owner       string        // 迁移锁持有者标识，多副本部署时建议使用主机名或实例ID
+	LockTimeout time.Duration // 超过该时间的锁视为失效，可被其它进程抢占，持有期间每 LockTimeout/3 刷新一次
+	LockWait    time.Duration // 获取锁的最长等待时间
+	AllowDrift  bool          // 为 true 时忽略已执行迁移文件的校验和变化
+}
+
+func (db *DB) NewMigrator(basePath string) *Migrator {
+	owner, err := os.Hostname()
+	if err != nil || owner == "" {
+		owner = DefaultMigrationOwner
+	}
+	return &Migrator{
+		db:          db,
+		BasePath:    basePath,
+		Owner:       fmt.Sprintf("%s-%d", owner, os.Getpid()),
+		LockTimeout: 10 * time.Minute,
+		LockWait:    30 * time.Second,
+	}
+}
+
+// Load 读取并按版本号排序所有迁移文件
+func (m *Migrator) Load() ([]*Migration, error) {
+	migrationMap := make(map[uint64]*Migration)
+	err := filepath.WalkDir(m.BasePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		matches := migrationFileReg.FindStringSubmatch(d.Name())
+		if matches == nil {
+			return nil
+		}
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		mg, ok := migrationMap[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: matches[2]}
+			migrationMap[version] = mg
+		} else if mg.Name != matches[2] {
+			return fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+		if matches[4] == "down" {
+			if mg.DownFile != "" {
+				return fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+			}
+			mg.DownFile = path
+			return nil
+		}
+		if mg.UpFile != "" {
+			return fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		mg.UpFile = path
+		mg.FileName = d.Name()
+		mg.Checksum = fmt.Sprintf("%x", sha256.Sum256(content))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]*Migration, 0, len(migrationMap))
+	for _, mg := range migrationMap {
+		if mg.UpFile == "" {
+			return nil, fmt.Errorf("the migration %d_%s has no up script", mg.Version, mg.Name)
+		}
+		migrations = append(migrations, mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Status 返回所有迁移文件的执行状态，Drifted 表示已执行的文件内容发生了变化
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	if err := m.db.autoMigrate(&UpdateModel{}); err != nil {
+		return nil, err
+	}
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedRecords()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*MigrationStatus, 0, len(migrations))
+	for _, mg := range migrations {
+		status := &MigrationStatus{Migration: mg}
+		if record, ok := applied[mg.FileName]; ok {
+			status.Applied = true
+			status.AppliedAt = record.ExecuteTime
+			status.Drifted = record.Checksum != "" && record.Checksum != mg.Checksum
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Plan 返回待执行的迁移，不做任何修改，用于 dry-run
+func (m *Migrator) Plan() ([]*Migration, error) {
+	statusList, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	if err = m.checkDrift(statusList); err != nil {
+		return nil, err
+	}
+	var pending []*Migration
+	for _, status := range statusList {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up 执行所有待执行的迁移
+func (m *Migrator) Up() ([]*Migration, error) {
+	return m.UpTo(0)
+}
+
+// UpTo 执行版本号不大于 version 的待执行迁移，version 为 0 表示不限制
+func (m *Migrator) UpTo(version uint64) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(func(held func() error) error {
+		pending, err := m.Plan()
+		if err != nil {
+			return err
+		}
+		for _, mg := range pending {
+			if version > 0 && mg.Version > version {
+				break
+			}
+			if err = held(); err != nil {
+				return err
+			}
+			if err = m.apply(mg); err != nil {
+				return fmt.Errorf("apply migration %s: %w", mg.FileName, err)
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本号倒序回滚最近执行的 steps 个迁移
+func (m *Migrator) Down(steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(func(held func() error) error {
+		statusList, err := m.Status()
+		if err != nil {
+			return err
+		}
+		if err = m.checkDrift(statusList); err != nil {
+			return err
+		}
+		applied, err := m.appliedRecords()
+		if err != nil {
+			return err
+		}
+		known := make(map[string]bool)
+		for _, status := range statusList {
+			known[status.FileName] = true
+		}
+		for fileName, record := range applied {
+			if record.Version > 0 && !known[fileName] {
+				return fmt.Errorf("%w: %s", ErrUnknownMigration, fileName)
+			}
+		}
+		for i := len(statusList) - 1; i >= 0 && len(done) < steps; i-- {
+			status := statusList[i]
+			if !status.Applied {
+				continue
+			}
+			if status.DownFile == "" {
+				return fmt.Errorf("%w: %s", ErrMissingDownScript, status.FileName)
+			}
+			if err = held(); err != nil {
+				return err
+			}
+			if err = m.revert(status.Migration); err != nil {
+				return fmt.Errorf("revert migration %s: %w", status.FileName, err)
+			}
+			done = append(done, status.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) checkDrift(statusList []*MigrationStatus) error {
+	if m.AllowDrift {
+		return nil
+	}
+	for _, status := range statusList {
+		if status.Drifted {
+			return fmt.Errorf("%w: %s", ErrChecksumMismatch, status.FileName)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) appliedRecords() (map[string]*UpdateModel, error) {
+	recordList := make([]*UpdateModel, 0)
+	if err := m.db.Find(&recordList).Error; err != nil {
+		return nil, err
+	}
+	recordMap := make(map[string]*UpdateModel)
+	for _, record := range recordList {
+		recordMap[record.FileName] = record
+	}
+	return recordMap, nil
+}
+
+// apply 在单个事务中执行 up 脚本并记录到 update_info，注意 MySQL 的 DDL 语句会隐式提交事务
+func (m *Migrator) apply(mg *Migration) error {
+	statements, err := readSqlStatements(mg.UpFile)
+	if err != nil {
+		return err
+	}
+	return m.db.Transaction(func(tx *TX) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("%s: %w", statement, err)
+			}
+		}
+		record := &UpdateModel{
+			FileName:    mg.FileName,
+			ExecuteTime: time.Now(),
+			Version:     mg.Version,
+			Checksum:    mg.Checksum,
+		}
+		return tx.Create(record).Error
+	})
+}
+
+func (m *Migrator) revert(mg *Migration) error {
+	statements, err := readSqlStatements(mg.DownFile)
+	if err != nil {
+		return err
+	}
+	return m.db.Transaction(func(tx *TX) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("%s: %w", statement, err)
+			}
+		}
+		return tx.Unscoped().Where("file_name = ?", mg.FileName).Delete(&UpdateModel{}).Error
+	})
+}
+
+// withLock 持有迁移锁执行 fn，持有期间定时刷新 locked_at，避免执行时间超过 LockTimeout 后被其它进程抢占，
+// 刷新失败或锁已被抢占时 held 返回错误，fn 应该在执行每个迁移前检查
+func (m *Migrator) withLock(fn func(held func() error) error) (err error) {
+	if err := m.db.autoMigrate(&UpdateModel{}, &MigrationLockModel{}); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(m.LockWait)
+	for {
+		locked, err := m.tryLock()
+		if err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	var (
+		mu   sync.Mutex
+		lost error
+		wg   sync.WaitGroup
+	)
+	held := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return lost
+	}
+	interval := m.LockTimeout / 3
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := m.refreshLock(); err != nil {
+					mu.Lock()
+					lost = err
+					mu.Unlock()
+					return
+				}
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		wg.Wait()
+		// 锁已被抢占时不会删除其它进程的锁
+		if uerr := m.unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+	if err = fn(held); err == nil {
+		err = held()
+	}
+	return err
+}
+
+func (m *Migrator) tryLock() (bool, error) {
+	now := time.Now()
+	err := m.db.DB.Create(&MigrationLockModel{Id: 1, Owner: m.Owner, LockedAt: now}).Error
+	if err == nil {
+		return true, nil
+	}
+	// 主键冲突说明锁已被持有，若已超时则抢占
+	result := m.db.Model(&MigrationLockModel{}).
+		Where("id = ? AND locked_at < ?", 1, now.Add(-m.LockTimeout)).
+		Updates(map[string]interface{}{"owner": m.Owner, "locked_at": now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// refreshLock 更新 locked_at，锁已经不属于当前进程时返回 ErrMigrationLockLost
+func (m *Migrator) refreshLock() error {
+	result := m.db.Model(&MigrationLockModel{}).
+		Where("id = ? AND owner = ?", 1, m.Owner).
+		Update("locked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("%w: %v", ErrMigrationLockLost, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMigrationLockLost
+	}
+	return nil
+}
+
+func (m *Migrator) unlock() error {
+	return m.db.Where("id = ? AND owner = ?", 1, m.Owner).Delete(&MigrationLockModel{}).Error
+}
+
+func (db *DB) autoMigrate(models ...interface{}) error {
+	tx := db.DB
+	if db.Dialector.Name() == "mysql" {
+		tx = tx.Set("gorm:table_options", "charset=utf8mb4")
+	}
+	return tx.AutoMigrate(models...)
+}
+
+// readSqlStatements 读取 sql 文件，去掉注释和空行后按分号拆分为多条语句
+func readSqlStatements(filePath string) ([]string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		statement = strings.TrimSpace(statement)
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements, nil
+}
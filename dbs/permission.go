@@ -0,0 +1,14 @@
+package dbs
+
+// RolePermissionModel 角色权限，Path 为空且 Parent 不为空的记录表示角色继承
+type RolePermissionModel struct {
+	BaseModel
+	Role   string `json:"role" gorm:"column:role;index;comment:'角色'"`
+	Parent string `json:"parent" gorm:"column:parent;null;comment:'继承的角色'"`
+	Method string `json:"method" gorm:"column:method;null;comment:'请求方法，多个用逗号分隔，*表示全部'"`
+	Path   string `json:"path" gorm:"column:path;null;comment:'路径模式，*匹配一级，**匹配多级'"`
+}
+
+func (m *RolePermissionModel) TableName() string {
+	return "role_permission"
+}
@@ -0,0 +1,424 @@
+package dbs
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/driver/clickhouse"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	PolicyRandom       = "random"
+	PolicyRoundRobin   = "round_robin"
+	PolicyLeastLatency = "least_latency"
+
+	RoleSource  = "source"
+	RoleReplica = "replica"
+)
+
+var ErrNoSource = errors.New("at least one source dsn is required")
+
+var dsnPasswordReg = regexp.MustCompile(`(:[^:@/]*@)|(password=\S+)`)
+
+type forcePrimaryKey struct{}
+
+// ForcePrimary 标记当前请求的后续读操作走主库，用于写后立即读的场景
+//
+//	ctx = dbs.ForcePrimary(c.Request.Context())
+//	db.WithContext(ctx).First(&user)
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+func isForcePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	force, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return force
+}
+
+// WithPrimary 返回一个所有操作都走主库的 DB
+func (db *DB) WithPrimary() *DB {
+	return &DB{db.Clauses(dbresolver.Write)}
+}
+
+type ReplicaStats struct {
+	Role       string        `json:"role"`
+	Addr       string        `json:"addr"`
+	Healthy    bool          `json:"healthy"`
+	Latency    time.Duration `json:"latency"`
+	Fails      int           `json:"fails"`
+	Picks      uint64        `json:"picks"`
+	Ejections  uint64        `json:"ejections"`
+	LastCheck  time.Time     `json:"last_check"`
+	LastError  string        `json:"last_error"`
+	ForcedPick uint64        `json:"forced_pick"`
+}
+
+type replicaNode struct {
+	role      string
+	addr      string
+	pool      gorm.ConnPool
+	healthy   bool
+	latency   time.Duration
+	fails     int
+	picks     uint64
+	ejections uint64
+	lastCheck time.Time
+	lastError string
+}
+
+// ReplicaPolicy 实现 dbresolver.Policy，按策略选择健康的副本，并定期探测副本健康状况
+type ReplicaPolicy struct {
+	Mode          string        // random, round_robin, least_latency
+	CheckInterval time.Duration // 健康检查间隔
+	CheckTimeout  time.Duration // 单次探测超时时间
+	FailThreshold int           // 连续失败多少次后摘除
+
+	lock    sync.RWMutex
+	nodes   map[gorm.ConnPool]*replicaNode
+	order   []*replicaNode
+	primary *replicaNode
+	counter uint64
+	forced  uint64
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func NewReplicaPolicy(mode string) *ReplicaPolicy {
+	return &ReplicaPolicy{
+		Mode:          mode,
+		CheckInterval: 5 * time.Second,
+		CheckTimeout:  time.Second,
+		FailThreshold: 3,
+		nodes:         make(map[gorm.ConnPool]*replicaNode),
+		stop:          make(chan struct{}),
+	}
+}
+
+func (p *ReplicaPolicy) register(role, dsn string, pool gorm.ConnPool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	node := &replicaNode{role: role, addr: maskDSN(dsn), pool: pool, healthy: true}
+	p.nodes[pool] = node
+	p.order = append(p.order, node)
+	if p.primary == nil && role == RoleSource {
+		p.primary = node
+	}
+}
+
+// Resolve 从健康的连接中选择一个，全部不可用时回退到主库
+func (p *ReplicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	p.lock.RLock()
+	healthy := make([]*replicaNode, 0, len(connPools))
+	for _, pool := range connPools {
+		if node, ok := p.nodes[pool]; !ok {
+			healthy = append(healthy, &replicaNode{pool: pool, healthy: true})
+		} else if node.healthy {
+			healthy = append(healthy, node)
+		}
+	}
+	primary := p.primary
+	p.lock.RUnlock()
+
+	if len(healthy) == 0 {
+		if primary != nil {
+			atomic.AddUint64(&primary.picks, 1)
+			return primary.pool
+		}
+		return connPools[rand.Intn(len(connPools))]
+	}
+
+	var node *replicaNode
+	switch p.Mode {
+	case PolicyRoundRobin:
+		n := atomic.AddUint64(&p.counter, 1)
+		node = healthy[int(n%uint64(len(healthy)))]
+	case PolicyLeastLatency:
+		p.lock.RLock()
+		for _, h := range healthy {
+			if node == nil || (h.latency > 0 && (node.latency == 0 || h.latency < node.latency)) {
+				node = h
+			}
+		}
+		p.lock.RUnlock()
+	default:
+		node = healthy[rand.Intn(len(healthy))]
+	}
+	atomic.AddUint64(&node.picks, 1)
+	return node.pool
+}
+
+func (p *ReplicaPolicy) start() {
+	go func() {
+		ticker := time.NewTicker(p.CheckInterval)
+		defer ticker.Stop()
+		p.check()
+		for {
+			select {
+			case <-ticker.C:
+				p.check()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止健康检查
+func (p *ReplicaPolicy) Close() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+}
+
+func (p *ReplicaPolicy) check() {
+	p.lock.RLock()
+	nodes := make([]*replicaNode, len(p.order))
+	copy(nodes, p.order)
+	p.lock.RUnlock()
+
+	for _, node := range nodes {
+		pinger, ok := node.pool.(interface {
+			PingContext(ctx context.Context) error
+		})
+		if !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.CheckTimeout)
+		start := time.Now()
+		err := pinger.PingContext(ctx)
+		cost := time.Since(start)
+		cancel()
+
+		p.lock.Lock()
+		node.lastCheck = time.Now()
+		if err != nil {
+			node.fails++
+			node.lastError = err.Error()
+			if node.healthy && node.fails >= p.FailThreshold {
+				node.healthy = false
+				node.ejections++
+			}
+		} else {
+			node.fails = 0
+			node.lastError = ""
+			node.healthy = true
+			if node.latency == 0 {
+				node.latency = cost
+			} else {
+				// 指数加权平均，平滑偶发抖动
+				node.latency = (node.latency*7 + cost*3) / 10
+			}
+		}
+		p.lock.Unlock()
+	}
+}
+
+// Stats 返回各节点的健康状况和路由统计
+func (p *ReplicaPolicy) Stats() []ReplicaStats {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	result := make([]ReplicaStats, 0, len(p.order))
+	for _, node := range p.order {
+		stats := ReplicaStats{
+			Role:      node.role,
+			Addr:      node.addr,
+			Healthy:   node.healthy,
+			Latency:   node.latency,
+			Fails:     node.fails,
+			Picks:     atomic.LoadUint64(&node.picks),
+			Ejections: node.ejections,
+			LastCheck: node.lastCheck,
+			LastError: node.lastError,
+		}
+		if node == p.primary {
+			stats.ForcedPick = atomic.LoadUint64(&p.forced)
+		}
+		result = append(result, stats)
+	}
+	return result
+}
+
+func (p *ReplicaPolicy) forcePrimary(db *gorm.DB) {
+	if db.Statement.Context == nil || !isForcePrimary(db.Statement.Context) {
+		return
+	}
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return
+	}
+	p.lock.RLock()
+	primary := p.primary
+	p.lock.RUnlock()
+	if primary != nil {
+		atomic.AddUint64(&p.forced, 1)
+		db.Statement.ConnPool = primary.pool
+	}
+}
+
+func maskDSN(dsn string) string {
+	return dsnPasswordReg.ReplaceAllStringFunc(dsn, func(s string) string {
+		if s[0] == ':' {
+			return ":***@"
+		}
+		return "password=***"
+	})
+}
+
+type clusterDialect struct {
+	open func(dsn string) gorm.Dialector
+	wrap func(dsn string, conn gorm.ConnPool) gorm.Dialector
+}
+
+var (
+	mysqlDialect = clusterDialect{
+		open: mysql.Open,
+		wrap: func(dsn string, conn gorm.ConnPool) gorm.Dialector {
+			return mysql.New(mysql.Config{DSN: dsn, Conn: conn})
+		},
+	}
+	postgresDialect = clusterDialect{
+		open: postgres.Open,
+		wrap: func(dsn string, conn gorm.ConnPool) gorm.Dialector {
+			return postgres.New(postgres.Config{DSN: dsn, Conn: conn})
+		},
+	}
+	sqliteDialect = clusterDialect{
+		open: sqlite.Open,
+		wrap: func(dsn string, conn gorm.ConnPool) gorm.Dialector {
+			return &sqlite.Dialector{DSN: dsn, Conn: conn}
+		},
+	}
+	sqlserverDialect = clusterDialect{
+		open: sqlserver.Open,
+		wrap: func(dsn string, conn gorm.ConnPool) gorm.Dialector {
+			return sqlserver.New(sqlserver.Config{DSN: dsn, Conn: conn})
+		},
+	}
+	clickhouseDialect = clusterDialect{
+		open: clickhouse.Open,
+		wrap: func(dsn string, conn gorm.ConnPool) gorm.Dialector {
+			return clickhouse.New(clickhouse.Config{DSN: dsn, Conn: conn})
+		},
+	}
+)
+
+func openReplicaCluster(dialect clusterDialect, sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	if len(sources) < 1 {
+		panic(ErrNoSource)
+	}
+	if maxConn < 1 {
+		maxConn = DefaultMaxConn
+	}
+	if idleConn < 1 {
+		idleConn = DefaultIdleConn
+	}
+	if policy == nil {
+		policy = NewReplicaPolicy(PolicyRandom)
+	}
+
+	var pools []gorm.ConnPool
+	openPool := func(dsn string) gorm.ConnPool {
+		pdb, err := gorm.Open(dialect.open(dsn), &gorm.Config{DryRun: dryRun})
+		if err != nil {
+			panic(err)
+		}
+		dc, err := pdb.DB()
+		if err != nil {
+			panic(err)
+		}
+		pools = append(pools, dc)
+		return dc
+	}
+
+	primary := openPool(sources[0])
+	policy.register(RoleSource, sources[0], primary)
+	db, err := gorm.Open(dialect.wrap(sources[0], primary), &gorm.Config{DryRun: dryRun})
+	if err != nil {
+		panic(err)
+	}
+
+	config := dbresolver.Config{
+		// sources/replicas load balancing policy
+		Policy: policy,
+	}
+	if len(sources) > 1 {
+		config.Sources = append(config.Sources, dialect.wrap(sources[0], primary))
+		for _, dsn := range sources[1:] {
+			pool := openPool(dsn)
+			policy.register(RoleSource, dsn, pool)
+			config.Sources = append(config.Sources, dialect.wrap(dsn, pool))
+		}
+	}
+	for _, dsn := range replicas {
+		pool := openPool(dsn)
+		policy.register(RoleReplica, dsn, pool)
+		config.Replicas = append(config.Replicas, dialect.wrap(dsn, pool))
+	}
+	if len(config.Sources) > 0 || len(config.Replicas) > 0 {
+		if err = db.Use(dbresolver.Register(config)); err != nil {
+			panic(err)
+		}
+		// dbresolver 选择连接之后再按 ctx 标记强制切换到主库
+		db.Callback().Query().After("gorm:db_resolver").Before("gorm:query").Register("dbs:force_primary", policy.forcePrimary)
+		db.Callback().Row().After("gorm:db_resolver").Before("gorm:row").Register("dbs:force_primary", policy.forcePrimary)
+		db.Callback().Raw().After("gorm:db_resolver").Before("gorm:raw").Register("dbs:force_primary", policy.forcePrimary)
+	}
+
+	for _, pool := range pools {
+		if dc, ok := pool.(interface {
+			SetMaxOpenConns(n int)
+			SetMaxIdleConns(n int)
+			SetConnMaxIdleTime(d time.Duration)
+			SetConnMaxLifetime(d time.Duration)
+		}); ok {
+			dc.SetMaxOpenConns(int(maxConn))
+			dc.SetMaxIdleConns(int(idleConn))
+			dc.SetConnMaxIdleTime(time.Hour)
+			dc.SetConnMaxLifetime(24 * time.Hour)
+		}
+	}
+	policy.start()
+	return &DB{db}
+}
+
+func OpenDBMySQLReplicas(sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	/**
+	@param sources, 写库，第一个为主库，such as: []string{"root:password@tcp(10.0.0.1:3306)/db_ex?charset=utf8&parseTime=true"}
+	@param replicas, 读库，such as: []string{"root:password@tcp(10.0.0.2:3306)/db_ex?charset=utf8&parseTime=true"}
+	@param policy, such as: dbs.NewReplicaPolicy(dbs.PolicyLeastLatency)
+	*/
+	return openReplicaCluster(mysqlDialect, sources, replicas, policy, dryRun, maxConn, idleConn)
+}
+
+func OpenDBPostgreSQLReplicas(sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	return openReplicaCluster(postgresDialect, sources, replicas, policy, dryRun, maxConn, idleConn)
+}
+
+func OpenDBSQLiteReplicas(sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	return openReplicaCluster(sqliteDialect, sources, replicas, policy, dryRun, maxConn, idleConn)
+}
+
+func OpenDBSQLServerReplicas(sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	return openReplicaCluster(sqlserverDialect, sources, replicas, policy, dryRun, maxConn, idleConn)
+}
+
+func OpenDBTiDBReplicas(sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	return openReplicaCluster(mysqlDialect, sources, replicas, policy, dryRun, maxConn, idleConn)
+}
+
+func OpenDBClickhouseReplicas(sources, replicas []string, policy *ReplicaPolicy, dryRun bool, maxConn, idleConn uint64) *DB {
+	return openReplicaCluster(clickhouseDialect, sources, replicas, policy, dryRun, maxConn, idleConn)
+}
@@ -0,0 +1,274 @@
+package dbs
+
+import (
+	"fmt"
+	"github.com/AbnerEarl/goutils/utils"
+	"gorm.io/gorm"
+	"reflect"
+	"time"
+)
+
+var baseModelType = reflect.TypeOf(BaseModel{})
+
+type whereClause struct {
+	query interface{}
+	args  []interface{}
+}
+
+type queryOptions struct {
+	wheres      []whereClause
+	fields      []string
+	order       string
+	orderKey    string
+	orderValues []interface{}
+	pageSize    int
+	pageNo      int
+	withDeleted bool
+	onlyDeleted bool
+}
+
+// QueryOption 组合查询条件，替代 String/Bytes/Select/OrderIn 等多种函数变体
+type QueryOption func(opts *queryOptions)
+
+// WithWhere 追加条件，例如 WithWhere("name = ? AND age > ?", "tom", 18)
+func WithWhere(where string, args ...interface{}) QueryOption {
+	return func(opts *queryOptions) {
+		opts.wheres = append(opts.wheres, whereClause{query: where, args: args})
+	}
+}
+
+// WithWhereModel 以结构体非零字段作为条件
+func WithWhereModel(whereModel interface{}) QueryOption {
+	return func(opts *queryOptions) {
+		opts.wheres = append(opts.wheres, whereClause{query: whereModel})
+	}
+}
+
+// WithWhereMap 以 map 作为等值条件
+func WithWhereMap(whereMap map[string]interface{}) QueryOption {
+	return func(opts *queryOptions) {
+		opts.wheres = append(opts.wheres, whereClause{query: whereMap})
+	}
+}
+
+// WithSelect 指定查询的字段
+func WithSelect(fields ...string) QueryOption {
+	return func(opts *queryOptions) {
+		opts.fields = fields
+	}
+}
+
+// WithOrder 指定排序，例如 WithOrder("id desc")
+func WithOrder(order string) QueryOption {
+	return func(opts *queryOptions) {
+		opts.order = order
+	}
+}
+
+// WithOrderIn 按给定值的顺序排序，依赖 MySQL 的 FIELD 函数
+func WithOrderIn(orderKey string, orderValues ...interface{}) QueryOption {
+	return func(opts *queryOptions) {
+		opts.orderKey = orderKey
+		opts.orderValues = orderValues
+	}
+}
+
+// WithPage 指定分页，pageSize 为 0 时使用 DefaultLimit，超过 DefaultMaxLimit 时按 DefaultMaxLimit 处理
+func WithPage(pageSize, pageNo int) QueryOption {
+	return func(opts *queryOptions) {
+		opts.pageSize = pageSize
+		opts.pageNo = pageNo
+	}
+}
+
+// WithDeleted 查询结果包含已软删除的数据
+func WithDeleted() QueryOption {
+	return func(opts *queryOptions) {
+		opts.withDeleted = true
+	}
+}
+
+// OnlyDeleted 只查询已软删除的数据
+func OnlyDeleted() QueryOption {
+	return func(opts *queryOptions) {
+		opts.onlyDeleted = true
+	}
+}
+
+func loadQueryOptions(options ...QueryOption) *queryOptions {
+	opts := new(queryOptions)
+	for _, option := range options {
+		option(opts)
+	}
+	opts.pageSize, opts.pageNo = normalizePage(opts.pageSize, opts.pageNo)
+	return opts
+}
+
+func normalizePage(pageSize, pageNo int) (int, int) {
+	if pageSize <= 0 {
+		pageSize = DefaultLimit
+	} else if pageSize > DefaultMaxLimit {
+		pageSize = DefaultMaxLimit
+	}
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+	return pageSize, pageNo
+}
+
+type PageResult[T any] struct {
+	Items    []*T  `json:"items"`
+	Total    int64 `json:"total"`
+	PageSize int   `json:"page_size"`
+	PageNo   int   `json:"page_no"`
+	Pages    int64 `json:"pages"`
+}
+
+// Repository 基于泛型的类型安全 CRUD，T 必须是嵌入了 BaseModel 的结构体
+type Repository[T any] struct {
+	db *DB
+}
+
+func NewRepository[T any](db *DB) *Repository[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Errorf("the repository model %s must be a struct", typ))
+	}
+	field, ok := typ.FieldByName("BaseModel")
+	if !ok || !field.Anonymous || field.Type != baseModelType {
+		panic(fmt.Errorf("the repository model %s must embed dbs.BaseModel", typ))
+	}
+	return &Repository[T]{db: db}
+}
+
+// WithTx 返回在事务中执行的 Repository
+func (r *Repository[T]) WithTx(tx *TX) *Repository[T] {
+	return &Repository[T]{db: &DB{tx.DB}}
+}
+
+func (r *Repository[T]) scope(opts *queryOptions) *gorm.DB {
+	tx := r.db.Model(new(T))
+	if opts.onlyDeleted {
+		tx = tx.Where("is_del = ?", 1)
+	} else if !opts.withDeleted {
+		tx = tx.Where("is_del = ?", 0)
+	}
+	for _, w := range opts.wheres {
+		tx = tx.Where(w.query, w.args...)
+	}
+	return tx
+}
+
+func (r *Repository[T]) ordered(tx *gorm.DB, opts *queryOptions) *gorm.DB {
+	if len(opts.fields) > 0 {
+		tx = tx.Select(opts.fields)
+	}
+	if len(opts.orderValues) > 0 {
+		tx = tx.Order(fmt.Sprintf("FIELD(%s,%s)", opts.orderKey, utils.List2String(opts.orderValues)))
+	} else if opts.order != "" {
+		tx = tx.Order(opts.order)
+	}
+	return tx
+}
+
+func (r *Repository[T]) Create(item *T) error {
+	return r.db.DB.Create(item).Error
+}
+
+func (r *Repository[T]) CreateBatch(items []*T, batchSize uint) error {
+	return r.db.CreateInBatches(items, int(batchSize)).Error
+}
+
+// Get 按主键查询，软删除的数据返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Get(id uint64, options ...QueryOption) (*T, error) {
+	options = append(options, WithWhere("id = ?", id))
+	return r.First(options...)
+}
+
+func (r *Repository[T]) First(options ...QueryOption) (*T, error) {
+	opts := loadQueryOptions(options...)
+	item := new(T)
+	if err := r.ordered(r.scope(opts), opts).First(item).Error; err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// List 分页查询
+//
+//	repo := dbs.NewRepository[User](db)
+//	page, err := repo.List(dbs.WithWhere("age > ?", 18), dbs.WithOrder("id desc"), dbs.WithPage(20, 1))
+func (r *Repository[T]) List(options ...QueryOption) (*PageResult[T], error) {
+	opts := loadQueryOptions(options...)
+	var count int64
+	if err := r.scope(opts).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	result := &PageResult[T]{
+		Items:    make([]*T, 0),
+		Total:    count,
+		PageSize: opts.pageSize,
+		PageNo:   opts.pageNo,
+		Pages:    (count + int64(opts.pageSize) - 1) / int64(opts.pageSize),
+	}
+	if count == 0 {
+		return result, nil
+	}
+	offset := (opts.pageNo - 1) * opts.pageSize
+	if err := r.ordered(r.scope(opts), opts).
+		Offset(offset).
+		Limit(opts.pageSize).
+		Find(&result.Items).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *Repository[T]) Count(options ...QueryOption) (int64, error) {
+	opts := loadQueryOptions(options...)
+	var count int64
+	err := r.scope(opts).Count(&count).Error
+	return count, err
+}
+
+// Update 按主键更新非零字段，指定 fields 时只更新这些字段（包括零值）
+func (r *Repository[T]) Update(id uint64, item *T, fields ...string) (int64, error) {
+	tx := r.db.Model(new(T)).Where("id = ? AND is_del = ?", id, 0)
+	if len(fields) > 0 {
+		tx = tx.Select(fields)
+	}
+	result := tx.Updates(item)
+	return result.RowsAffected, result.Error
+}
+
+// UpdateWhere 按条件批量更新，未指定条件时拒绝执行
+func (r *Repository[T]) UpdateWhere(updates map[string]interface{}, options ...QueryOption) (int64, error) {
+	opts := loadQueryOptions(options...)
+	if len(opts.wheres) == 0 {
+		return 0, gorm.ErrMissingWhereClause
+	}
+	result := r.scope(opts).Updates(updates)
+	return result.RowsAffected, result.Error
+}
+
+// SoftDelete 将 is_del 置为 1 并记录删除时间
+func (r *Repository[T]) SoftDelete(ids ...uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(new(T)).
+		Where("id IN ? AND is_del = ?", ids, 0).
+		Updates(map[string]interface{}{"is_del": 1, "deleted_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// Restore 恢复被软删除的数据
+func (r *Repository[T]) Restore(ids ...uint64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(new(T)).
+		Where("id IN ? AND is_del = ?", ids, 1).
+		Updates(map[string]interface{}{"is_del": 0, "deleted_at": nil})
+	return result.RowsAffected, result.Error
+}
@@ -16,12 +16,16 @@ type UpdateModel struct {
 	BaseModel
 	FileName    string    `json:"file_name" gorm:"column:file_name;not null;comment:'文件名称'"`
 	ExecuteTime time.Time `json:"execute_time" gorm:"column:execute_time;null;comment:'执行时间'"`
+	Version     uint64    `json:"version" gorm:"column:version;default:0;comment:'迁移版本'"`
+	Checksum    string    `json:"checksum" gorm:"column:checksum;null;comment:'文件校验和'"`
 }
 
 func (m *UpdateModel) TableName() string {
 	return "update_info"
 }
 
+// InitDefaultData 执行 basePath 下尚未执行过的 sql 文件，失败时直接退出进程
+// Deprecated: 使用 NewMigrator 获得有序、可回滚并返回错误的迁移能力
 func (db *DB) InitDefaultData(basePath string) {
 	db.Set("gorm:table_options", "charset=utf8mb4").AutoMigrate(&UpdateModel{})
 	fileList := files.GetFilesBySuffix(basePath, ".sql")
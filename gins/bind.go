@@ -0,0 +1,374 @@
+package gins
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/utils"
+)
+
+type validRule struct {
+	name string
+	arg  string
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	regexCache sync.Map
+)
+
+// BindArgs 将 Args 中间件合并后的请求参数绑定到结构体，并按 valid 标签校验，所有字段的错误汇总到一个 Errno 中返回
+//
+//	type Req struct {
+//		Name  string   `args:"name" valid:"required,max=32"`
+//		Age   int      `args:"age" valid:"min=1,max=150"`
+//		Email string   `args:"email" valid:"regex=^[^@]+@[^@]+$"`
+//		Sex   string   `args:"sex" valid:"enum=male|female"`
+//		Tags  []string `args:"tags" valid:"max=5"`
+//		Addr  *Address `args:"addr" valid:"required"`
+//	}
+//	var req Req
+//	if err := c.BindArgs(&req); err != nil {
+//		SendResponse(c, err, nil)
+//		return
+//	}
+//
+// 参数名取 args 标签，其次 json 标签，最后是字段名；数字的 min/max 限制取值，字符串和切片的 min/max 限制长度
+func (c *Context) BindArgs(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("BindArgs requires a non-nil pointer to struct, got %T", obj)
+	}
+	params, ok := c.Get("request_params")
+	data, _ := params.(map[string]interface{})
+	if !ok || data == nil {
+		data = make(map[string]interface{})
+		for k, v := range c.Keys {
+			data[k] = v
+		}
+		for _, p := range c.Params {
+			data[p.Key] = p.Value
+		}
+	}
+	var errs []FieldError
+	bindStruct(rv.Elem(), data, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	e := new(Errno)
+	*e = *ErrValidation
+	e.Details = errs
+	messages := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		messages = append(messages, fe.Message)
+	}
+	e.Err = strings.Join(messages, "; ")
+	return e
+}
+
+func bindStruct(rv reflect.Value, data map[string]interface{}, prefix string, errs *[]FieldError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("args") == "" {
+			bindStruct(rv.Field(i), data, prefix, errs)
+			continue
+		}
+		name := argsName(field)
+		if name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		rules := parseRules(field.Tag.Get("valid"))
+		raw, exists := data[name]
+		if !exists || raw == nil || raw == "" {
+			if hasRule(rules, "required") {
+				*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf("%s is required", path)})
+			}
+			continue
+		}
+		fv := rv.Field(i)
+		count := len(*errs)
+		if err := setValue(fv, raw, path, errs); err != nil {
+			*errs = append(*errs, FieldError{Field: path, Message: err.Error()})
+			continue
+		}
+		// 嵌套字段已有错误时不再校验当前字段
+		if len(*errs) == count {
+			validateValue(fv, rules, path, errs)
+		}
+	}
+}
+
+func argsName(field reflect.StructField) string {
+	if name := strings.Split(field.Tag.Get("args"), ",")[0]; name != "" {
+		return name
+	}
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+func parseRules(tag string) []validRule {
+	var rules []validRule
+	if tag == "" {
+		return rules
+	}
+	// regex 可能包含逗号，因此 regex 必须作为最后一条规则
+	regex := ""
+	if idx := strings.Index(tag, "regex="); idx >= 0 {
+		regex = tag[idx+len("regex="):]
+		tag = tag[:idx]
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) == 2 {
+			rules = append(rules, validRule{name: kv[0], arg: kv[1]})
+		} else {
+			rules = append(rules, validRule{name: kv[0]})
+		}
+	}
+	if regex != "" {
+		rules = append(rules, validRule{name: "regex", arg: regex})
+	}
+	return rules
+}
+
+func hasRule(rules []validRule, name string) bool {
+	for _, rule := range rules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func setValue(fv reflect.Value, raw interface{}, path string, errs *[]FieldError) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setValue(fv.Elem(), raw, path, errs)
+	}
+	if fv.Type() == timeType {
+		s := fmt.Sprint(raw)
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				fv.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be a time", path)
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		if s, ok := raw.(string); ok {
+			fv.SetString(s)
+		} else if list, ok := raw.([]string); ok {
+			fv.SetString(strings.Join(list, ","))
+		} else {
+			fv.SetString(fmt.Sprint(raw))
+		}
+	case reflect.Bool:
+		b, err := strconv.ParseBool(fmt.Sprint(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		decimalData, err := utils.NewFromString(fmt.Sprint(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		n, err := strconv.ParseInt(decimalData.String(), 10, 64)
+		if err != nil || fv.OverflowInt(n) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		decimalData, err := utils.NewFromString(fmt.Sprint(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be a non-negative integer", path)
+		}
+		n, err := strconv.ParseUint(decimalData.String(), 10, 64)
+		if err != nil || fv.OverflowUint(n) {
+			return fmt.Errorf("%s must be a non-negative integer", path)
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(fmt.Sprint(raw), 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number", path)
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		items := toSlice(raw)
+		list := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		failed := false
+		for i, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			if err := setValue(list.Index(i), item, itemPath, errs); err != nil {
+				*errs = append(*errs, FieldError{Field: itemPath, Message: err.Error()})
+				failed = true
+			}
+		}
+		if failed {
+			return nil
+		}
+		fv.Set(list)
+	case reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			if s, isString := raw.(string); !isString || json.Unmarshal([]byte(s), &m) != nil {
+				return fmt.Errorf("%s must be an object", path)
+			}
+		}
+		bindStruct(fv, m, path, errs)
+	case reflect.Map, reflect.Interface:
+		value := reflect.ValueOf(raw)
+		if value.Type().AssignableTo(fv.Type()) {
+			fv.Set(value)
+			return nil
+		}
+		bys, err := json.Marshal(raw)
+		if err != nil || json.Unmarshal(bys, fv.Addr().Interface()) != nil {
+			return fmt.Errorf("%s has an invalid value", path)
+		}
+	default:
+		return fmt.Errorf("%s has an unsupported type %s", path, fv.Type())
+	}
+	return nil
+}
+
+// toSlice 兼容 json 数组、文件名数组以及逗号分隔的字符串
+func toSlice(raw interface{}) []interface{} {
+	switch v := raw.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(v), "[") {
+			var items []interface{}
+			if json.Unmarshal([]byte(v), &items) == nil {
+				return items
+			}
+		}
+		var items []interface{}
+		for _, s := range strings.Split(v, ",") {
+			items = append(items, strings.TrimSpace(s))
+		}
+		return items
+	}
+	return []interface{}{raw}
+}
+
+func validateValue(fv reflect.Value, rules []validRule, path string, errs *[]FieldError) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+	for _, rule := range rules {
+		name, arg := rule.name, rule.arg
+		var msg string
+		switch name {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				msg = fmt.Sprintf("%s has an invalid %s rule: %s", path, name, arg)
+				break
+			}
+			value, isLength := measure(fv)
+			if (name == "min" && value < limit) || (name == "max" && value > limit) {
+				bound := "at least"
+				if name == "max" {
+					bound = "at most"
+				}
+				if isLength {
+					msg = fmt.Sprintf("%s length must be %s %s", path, bound, arg)
+				} else {
+					msg = fmt.Sprintf("%s must be %s %s", path, bound, arg)
+				}
+			}
+		case "regex":
+			reg, err := compileRegex(arg)
+			if err != nil {
+				msg = fmt.Sprintf("%s has an invalid regex rule: %s", path, arg)
+			} else if !reg.MatchString(fmt.Sprint(fv.Interface())) {
+				msg = fmt.Sprintf("%s does not match %s", path, arg)
+			}
+		case "enum":
+			value := fmt.Sprint(fv.Interface())
+			found := false
+			for _, option := range strings.Split(arg, "|") {
+				if option == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				msg = fmt.Sprintf("%s must be one of %s", path, strings.ReplaceAll(arg, "|", ", "))
+			}
+		}
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: path, Message: msg})
+		}
+	}
+}
+
+// measure 返回用于 min/max 比较的值，字符串、切片和 map 返回长度
+func measure(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(len([]rune(fv.String()))), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(fv.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	}
+	return 0, false
+}
+
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if reg, ok := regexCache.Load(expr); ok {
+		return reg.(*regexp.Regexp), nil
+	}
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(expr, reg)
+	return reg, nil
+}
@@ -5,11 +5,17 @@ import (
 )
 
 var (
-	OK              = &Errno{Code: 0, Message: "success", Tips: "成功"}
-	ParamError      = &Errno{Code: 1, Message: "request parameter error", Tips: "请求参数错误"}
-	InternalError   = &Errno{Code: 10001, Message: "internal server error", Tips: "服务器内部错误"}
-	ErrTokenInvalid = &Errno{Code: 20001, Message: "the token was invalid", Tips: "Token无效"}
-	ErrPageParam    = &Errno{Code: 30001, Message: "the parameter of page_no or page_size is error", Tips: "分页参数错误"}
+	OK                 = &Errno{Code: 0, Message: "success", Tips: "成功"}
+	ParamError         = &Errno{Code: 1, Message: "request parameter error", Tips: "请求参数错误"}
+	InternalError      = &Errno{Code: 10001, Message: "internal server error", Tips: "服务器内部错误"}
+	ErrTooManyRequests = &Errno{Code: 10002, Message: "too many requests", Tips: "请求过于频繁，请稍后再试"}
+	ErrTokenInvalid    = &Errno{Code: 20001, Message: "the token was invalid", Tips: "Token无效"}
+	ErrRefreshToken    = &Errno{Code: 20002, Message: "the refresh token was invalid", Tips: "刷新Token无效"}
+	ErrRefreshReuse    = &Errno{Code: 20003, Message: "the refresh token was reused, please login again", Tips: "刷新Token被重复使用，请重新登录"}
+	ErrForbidden       = &Errno{Code: 20004, Message: "permission denied", Tips: "没有访问权限"}
+	ErrPageParam       = &Errno{Code: 30001, Message: "the parameter of page_no or page_size is error", Tips: "分页参数错误"}
+	ErrCursorParam     = &Errno{Code: 30002, Message: "the parameter of cursor or page_size is error", Tips: "游标分页参数错误"}
+	ErrValidation      = &Errno{Code: 30003, Message: "the parameter validation failed", Tips: "参数校验失败"}
 )
 
 type Response struct {
@@ -30,10 +36,11 @@ type LogData struct {
 }
 
 type Errno struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Tips    string `json:"tips"`
-	Err     string `json:"err"`
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Tips    string       `json:"tips"`
+	Err     string       `json:"err"`
+	Details []FieldError `json:"details,omitempty"`
 }
 
 func NewErr(errno *Errno, err error) *Errno {
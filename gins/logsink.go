@@ -0,0 +1,336 @@
+package gins
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AbnerEarl/goutils/dbs"
+)
+
+const RedactedValue = "******"
+
+// DefaultRedactKeys 默认脱敏的参数和请求头，不区分大小写
+var DefaultRedactKeys = []string{"password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token", "authorization"}
+
+type logSinkOptions struct {
+	batchSize     int
+	bufferSize    int
+	flushInterval time.Duration
+	blockTimeout  time.Duration
+	redactKeys    map[string]bool
+	enrich        func(data LogData, model *dbs.LogInfoModel)
+}
+
+type LogSinkOption func(opts *logSinkOptions)
+
+// WithLogBatchSize 缓冲条数达到 size 时立即写库
+func WithLogBatchSize(size int) LogSinkOption {
+	return func(opts *logSinkOptions) {
+		opts.batchSize = size
+	}
+}
+
+// WithLogBufferSize 内存中最多缓冲的日志条数
+func WithLogBufferSize(size int) LogSinkOption {
+	return func(opts *logSinkOptions) {
+		opts.bufferSize = size
+	}
+}
+
+// WithLogFlushInterval 距离上次写库超过 interval 时写库
+func WithLogFlushInterval(interval time.Duration) LogSinkOption {
+	return func(opts *logSinkOptions) {
+		opts.flushInterval = interval
+	}
+}
+
+// WithLogBlockTimeout 缓冲区满时请求最多等待 timeout，超时后丢弃日志，为 0 时直接丢弃
+func WithLogBlockTimeout(timeout time.Duration) LogSinkOption {
+	return func(opts *logSinkOptions) {
+		opts.blockTimeout = timeout
+	}
+}
+
+// WithLogRedactKeys 替换默认的脱敏字段
+func WithLogRedactKeys(keys ...string) LogSinkOption {
+	return func(opts *logSinkOptions) {
+		opts.redactKeys = make(map[string]bool)
+		for _, key := range keys {
+			opts.redactKeys[strings.ToLower(key)] = true
+		}
+	}
+}
+
+// WithLogEnrich 写库前补充字段，例如从 LogInfo 中取出用户信息
+func WithLogEnrich(enrich func(data LogData, model *dbs.LogInfoModel)) LogSinkOption {
+	return func(opts *logSinkOptions) {
+		opts.enrich = enrich
+	}
+}
+
+type LogSinkStats struct {
+	Received uint64 `json:"received"`
+	Written  uint64 `json:"written"`
+	Dropped  uint64 `json:"dropped"`
+	Failed   uint64 `json:"failed"`
+	Pending  int    `json:"pending"`
+}
+
+// DBLogSink 将 LogAop 产生的日志异步批量写入 log_info 表
+type DBLogSink struct {
+	db      *dbs.DB
+	opts    *logSinkOptions
+	entries chan *dbs.LogInfoModel
+	lock    sync.RWMutex
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+
+	received uint64
+	written  uint64
+	dropped  uint64
+	failed   uint64
+}
+
+func NewDBLogSink(db *dbs.DB, options ...LogSinkOption) *DBLogSink {
+	//use example:
+	//db.Migration([]interface{}{&dbs.LogInfoModel{}})
+	//sink := gins.NewDBLogSink(db, gins.WithLogBatchSize(200), gins.WithLogBlockTimeout(10*time.Millisecond))
+	//router.Use(gins.LogAop(sink.Handle))
+	//defer sink.Close(context.Background())
+	opts := &logSinkOptions{
+		batchSize:     100,
+		bufferSize:    10000,
+		flushInterval: 3 * time.Second,
+	}
+	WithLogRedactKeys(DefaultRedactKeys...)(opts)
+	for _, option := range options {
+		option(opts)
+	}
+	if opts.batchSize < 1 {
+		opts.batchSize = 1
+	}
+	if opts.bufferSize < opts.batchSize {
+		opts.bufferSize = opts.batchSize
+	}
+	if opts.flushInterval <= 0 {
+		opts.flushInterval = 3 * time.Second
+	}
+	s := &DBLogSink{
+		db:      db,
+		opts:    opts,
+		entries: make(chan *dbs.LogInfoModel, opts.bufferSize),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Handle 作为 LogAop 的回调使用
+func (s *DBLogSink) Handle(data LogData) {
+	atomic.AddUint64(&s.received, 1)
+	model := s.convert(data)
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+	select {
+	case s.entries <- model:
+		return
+	default:
+	}
+	if s.opts.blockTimeout > 0 {
+		timer := time.NewTimer(s.opts.blockTimeout)
+		defer timer.Stop()
+		select {
+		case s.entries <- model:
+			return
+		case <-timer.C:
+		}
+	}
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+// Close 停止接收日志，并将缓冲区中的日志全部写库
+func (s *DBLogSink) Close(ctx context.Context) error {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.lock.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *DBLogSink) Stats() LogSinkStats {
+	return LogSinkStats{
+		Received: atomic.LoadUint64(&s.received),
+		Written:  atomic.LoadUint64(&s.written),
+		Dropped:  atomic.LoadUint64(&s.dropped),
+		Failed:   atomic.LoadUint64(&s.failed),
+		Pending:  len(s.entries),
+	}
+}
+
+func (s *DBLogSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*dbs.LogInfoModel, 0, s.opts.batchSize)
+	for {
+		select {
+		case model := <-s.entries:
+			batch = append(batch, model)
+			if len(batch) >= s.opts.batchSize {
+				batch = s.flush(batch)
+			}
+		case <-ticker.C:
+			batch = s.flush(batch)
+		case <-s.stop:
+			for {
+				select {
+				case model := <-s.entries:
+					batch = append(batch, model)
+					if len(batch) >= s.opts.batchSize {
+						batch = s.flush(batch)
+					}
+				default:
+					s.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *DBLogSink) flush(batch []*dbs.LogInfoModel) []*dbs.LogInfoModel {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := s.db.CreateBatch(&batch, uint(s.opts.batchSize)); err != nil {
+		atomic.AddUint64(&s.failed, uint64(len(batch)))
+		LogError(fmt.Sprintf("write %d request logs failed: %v", len(batch), err))
+	} else {
+		atomic.AddUint64(&s.written, uint64(len(batch)))
+	}
+	return make([]*dbs.LogInfoModel, 0, s.opts.batchSize)
+}
+
+func (s *DBLogSink) convert(data LogData) *dbs.LogInfoModel {
+	info := data.LogInfo
+	model := &dbs.LogInfoModel{
+		Method:        GetString(info["method"]),
+		ContentLength: logUint(info["content_length"]),
+		ContentType:   GetString(info["content_type"]),
+		CostTime:      logUint(info["cost_time"]),
+		RequestUrl:    GetString(info["request_url"]),
+		RequestHost:   GetString(info["request_host"]),
+		UserAgent:     GetString(info["user_agent"]),
+		RemoteIp:      GetString(info["remote_ip"]),
+		RemoteAddr:    GetString(info["remote_addr"]),
+		ApiPath:       GetString(info["api_path"]),
+		Referer:       GetString(info["referer"]),
+		ApiDesc:       GetString(info["api_desc"]),
+		StatusCode:    int(logUint(info["status_code"])),
+		ResponseData:  s.redactJson(data.ResponseData),
+		AccountInfo:   GetString(info["account_info"]),
+		AccountName:   GetString(info["account_name"]),
+		AccountId:     logUint(info["account_id"]),
+		RequestToken:  data.RequestToken,
+	}
+	if executeTime, ok := info["execute_time"].(time.Time); ok {
+		model.ExecuteTime = executeTime
+	} else {
+		model.ExecuteTime = time.Now()
+	}
+	if data.RequestParams != nil {
+		params, _ := json.Marshal(s.redact(data.RequestParams))
+		model.RequestParams = string(params)
+	} else {
+		model.RequestParams = s.redactJson(GetString(info["request_params"]))
+	}
+	if model.RequestToken != "" && (s.opts.redactKeys["token"] || s.opts.redactKeys["authorization"]) {
+		model.RequestToken = RedactedValue
+	}
+	if s.opts.enrich != nil {
+		s.opts.enrich(data, model)
+	}
+	return model
+}
+
+func (s *DBLogSink) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			if s.opts.redactKeys[strings.ToLower(key)] {
+				result[key] = RedactedValue
+			} else {
+				result[key] = s.redact(val)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = s.redact(val)
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// redactJson 对 json 字符串脱敏，非 json 内容原样返回
+func (s *DBLogSink) redactJson(data string) string {
+	if len(s.opts.redactKeys) == 0 || !strings.HasPrefix(strings.TrimSpace(data), "{") {
+		return data
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return data
+	}
+	result, err := json.Marshal(s.redact(value))
+	if err != nil {
+		return data
+	}
+	return string(result)
+}
+
+func logUint(value interface{}) uint64 {
+	switch v := value.(type) {
+	case int:
+		if v > 0 {
+			return uint64(v)
+		}
+	case int64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case uint64:
+		return v
+	case float64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case string:
+		n, _ := strconv.ParseUint(v, 10, 64)
+		return n
+	}
+	return 0
+}
@@ -2,6 +2,7 @@ package gins
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/AbnerEarl/goutils/jwts"
@@ -158,6 +159,31 @@ func CheckPageParam(c *Context) (bool, error) {
 	return true, nil
 }
 
+func CheckCursorParam(c *Context) (bool, error) {
+	// 游标分页使用 cursor 和 page_size，不能与 page_no 同时使用
+	if data, exists := c.Get("page_no"); exists && data != nil {
+		return false, ErrCursorParam
+	}
+	data, exists := c.Get("cursor")
+	if exists && data != nil {
+		cursor := fmt.Sprint(data)
+		if len(cursor) > 4096 {
+			return false, ErrCursorParam
+		}
+		if _, e := base64.RawURLEncoding.DecodeString(cursor); e != nil {
+			return false, ErrCursorParam
+		}
+	}
+	data, exists = c.Get("page_size")
+	if exists && data != nil {
+		result, e := strconv.ParseUint(fmt.Sprint(data), 10, 64)
+		if e != nil || result < 1 || result > 1000 {
+			return false, ErrCursorParam
+		}
+	}
+	return true, nil
+}
+
 func Cors() HandlerFunc {
 	return func(c *Context) {
 		method := c.Request.Method
@@ -236,12 +262,13 @@ func jwtVerify(c *Context, checkToken func(token, apiPath string, c *Context) (s
 	return nil
 }
 
+// Deprecated: CheckToken 会不断延长同一个 token 的有效期，请使用 TokenAuth 和 RefreshHandler
 func CheckToken(token, apiPath string, c *Context) (string, error) {
 	customClaims, err := jwts.ParseToken(token, JwtSecret)
-	c.Set("user_info", customClaims.UserInfo)
 	if err != nil {
 		return "", err
 	}
+	c.Set("user_info", customClaims.UserInfo)
 	if time.Now().Unix() > customClaims.ExpiresAt {
 		return "", ErrTokenInvalid
 	} else if customClaims.ExpiresAt-time.Now().Unix() > 20*60 {
@@ -282,9 +309,10 @@ func LogAop(dealLogInfo func(data LogData)) HandlerFunc {
 		reqToken := c.GetHeader("token")
 		logInfo["request_token"] = reqToken
 
+		paramMap, _ := requestParams.(map[string]interface{})
 		logData := LogData{
 			LogInfo:       logInfo,
-			RequestParams: requestParams.(map[string]interface{}),
+			RequestParams: paramMap,
 			RequestToken:  reqToken,
 			ResponseData:  respData,
 		}
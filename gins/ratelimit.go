@@ -0,0 +1,44 @@
+package gins
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AbnerEarl/goutils/web/limiter"
+)
+
+// RateLimit 限流中间件，keyFunc 为空时按客户端 IP 和接口路径限流，限流服务出错时放行
+//
+//	l, _ := limiter.NewRedisLimiter(rdb, "limit:api:", limiter.Rule{Algorithm: limiter.TokenBucket, Limit: 100, Period: time.Minute})
+//	server.Use(gins.RateLimit(l, nil))
+func RateLimit(l limiter.Limiter, keyFunc func(c *Context) string) HandlerFunc {
+	if keyFunc == nil {
+		keyFunc = func(c *Context) string {
+			return c.ClientIP() + ":" + c.Request.Method + ":" + c.FullPath()
+		}
+	}
+	return func(c *Context) {
+		res, err := l.Allow(keyFunc(c))
+		if err != nil {
+			LogError(fmt.Sprintf("rate limit failed: %v", err))
+			c.Next()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.ResetAfter), 10))
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(res.RetryAfter), 10))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, Response{Errno: ErrTooManyRequests})
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
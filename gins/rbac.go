@@ -0,0 +1,290 @@
+package gins
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/dbs"
+	"github.com/AbnerEarl/goutils/utils"
+)
+
+type Permission struct {
+	Method string `json:"method"` // GET,POST 或 *
+	Path   string `json:"path"`   // /api/v1/user/*，* 或 :id 匹配一级，** 匹配剩余所有层级
+}
+
+type RolePolicy struct {
+	Inherits    []string     `json:"inherits"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Policy 权限策略，yaml 格式如下:
+//
+//	roles:
+//	  admin:
+//	    inherits: [editor]
+//	    permissions:
+//	      - {method: "*", path: /api/v1/**}
+//	  editor:
+//	    permissions:
+//	      - {method: "GET,POST", path: /api/v1/article/*}
+type Policy struct {
+	Roles map[string]*RolePolicy `json:"roles"`
+}
+
+type PolicyLoader func() (*Policy, error)
+
+func YamlPolicyLoader(filePath string) PolicyLoader {
+	return func() (*Policy, error) {
+		data, err := utils.Yaml2JsonForFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		bys, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		policy := &Policy{}
+		if err = json.Unmarshal(bys, policy); err != nil {
+			return nil, err
+		}
+		return policy, nil
+	}
+}
+
+// DBPolicyLoader 从 role_permission 表加载策略
+func DBPolicyLoader(db *dbs.DB) PolicyLoader {
+	return func() (*Policy, error) {
+		var list []*dbs.RolePermissionModel
+		if err := db.Where("is_del = ?", 0).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		policy := &Policy{Roles: make(map[string]*RolePolicy)}
+		for _, item := range list {
+			role, ok := policy.Roles[item.Role]
+			if !ok {
+				role = &RolePolicy{}
+				policy.Roles[item.Role] = role
+			}
+			if item.Parent != "" {
+				role.Inherits = append(role.Inherits, item.Parent)
+			}
+			if item.Path != "" {
+				role.Permissions = append(role.Permissions, Permission{Method: item.Method, Path: item.Path})
+			}
+		}
+		return policy, nil
+	}
+}
+
+type permissionRule struct {
+	methods  map[string]bool
+	segments []string
+}
+
+// Authorizer 按角色判断请求方法和路径是否有权限，策略可定时重新加载
+type Authorizer struct {
+	lock   sync.RWMutex
+	rules  map[string][]*permissionRule
+	loader PolicyLoader
+	stop   chan struct{}
+	once   sync.Once
+
+	// RoleFunc 从请求中取出用户角色，默认读取 user_info 中的 roles 或 role 字段
+	RoleFunc func(c *Context) []string
+}
+
+func NewAuthorizer(loader PolicyLoader) (*Authorizer, error) {
+	//use example:
+	//auth, err := gins.NewAuthorizer(gins.YamlPolicyLoader("conf/rbac.yaml"))
+	//auth.StartReload(30 * time.Second)
+	//server.Use(gins.Validate(gins.CheckToken), gins.Authorize(auth))
+	a := &Authorizer{loader: loader, RoleFunc: UserInfoRoles, stop: make(chan struct{})}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload 重新加载策略，加载失败时保留原有策略
+func (a *Authorizer) Reload() error {
+	policy, err := a.loader()
+	if err != nil {
+		return err
+	}
+	rules, err := compilePolicy(policy)
+	if err != nil {
+		return err
+	}
+	a.lock.Lock()
+	a.rules = rules
+	a.lock.Unlock()
+	return nil
+}
+
+// StartReload 每隔 interval 重新加载一次策略，用于不重启服务更新权限
+func (a *Authorizer) StartReload(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.Reload(); err != nil {
+					LogError(fmt.Sprintf("reload rbac policy failed: %v", err))
+				}
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (a *Authorizer) Close() {
+	a.once.Do(func() {
+		close(a.stop)
+	})
+}
+
+func (a *Authorizer) Allow(roles []string, method, path string) bool {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	segments := splitPath(path)
+	method = strings.ToUpper(method)
+	for _, role := range roles {
+		for _, rule := range a.rules[role] {
+			if (rule.methods["*"] || rule.methods[method]) && matchSegments(rule.segments, segments) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Authorize 权限校验中间件，需放在 Validate 等设置 user_info 的中间件之后
+func Authorize(a *Authorizer) HandlerFunc {
+	return func(c *Context) {
+		if WhitelistAPI[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		if !a.Allow(a.RoleFunc(c), c.Request.Method, c.Request.URL.Path) {
+			c.Abort()
+			SendResponse(c, ErrForbidden, nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserInfoRoles 读取 user_info 中的 roles（数组或逗号分隔的字符串）或 role 字段
+func UserInfoRoles(c *Context) []string {
+	userInfo, ok := c.Get("user_info")
+	if !ok || userInfo == nil {
+		return nil
+	}
+	info, ok := userInfo.(map[string]interface{})
+	if !ok {
+		bys, err := json.Marshal(userInfo)
+		if err != nil {
+			return nil
+		}
+		info = make(map[string]interface{})
+		if err = json.Unmarshal(bys, &info); err != nil {
+			return nil
+		}
+	}
+	var roles []string
+	for _, key := range []string{"roles", "role"} {
+		switch v := info[key].(type) {
+		case string:
+			for _, role := range strings.Split(v, ",") {
+				if role = strings.TrimSpace(role); role != "" {
+					roles = append(roles, role)
+				}
+			}
+		case []interface{}:
+			for _, role := range v {
+				roles = append(roles, GetString(role))
+			}
+		case []string:
+			roles = append(roles, v...)
+		}
+	}
+	return roles
+}
+
+// compilePolicy 展开角色继承，检测循环继承
+func compilePolicy(policy *Policy) (map[string][]*permissionRule, error) {
+	rules := make(map[string][]*permissionRule)
+	var expand func(name string, visiting map[string]bool) ([]*permissionRule, error)
+	expand = func(name string, visiting map[string]bool) ([]*permissionRule, error) {
+		if result, ok := rules[name]; ok {
+			return result, nil
+		}
+		if visiting[name] {
+			return nil, fmt.Errorf("the rbac role %s is inherited circularly", name)
+		}
+		role, ok := policy.Roles[name]
+		if !ok {
+			return nil, fmt.Errorf("the rbac role %s is not defined", name)
+		}
+		visiting[name] = true
+		var result []*permissionRule
+		for _, perm := range role.Permissions {
+			rule := &permissionRule{methods: make(map[string]bool), segments: splitPath(perm.Path)}
+			method := perm.Method
+			if method == "" {
+				method = "*"
+			}
+			for _, m := range strings.Split(method, ",") {
+				rule.methods[strings.ToUpper(strings.TrimSpace(m))] = true
+			}
+			result = append(result, rule)
+		}
+		for _, parent := range role.Inherits {
+			parentRules, err := expand(parent, visiting)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, parentRules...)
+		}
+		delete(visiting, name)
+		rules[name] = result
+		return result, nil
+	}
+	for name := range policy.Roles {
+		if _, err := expand(name, make(map[string]bool)); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+func splitPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if p != "*" && !strings.HasPrefix(p, ":") && p != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
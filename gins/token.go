@@ -0,0 +1,85 @@
+package gins
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/AbnerEarl/goutils/jwts"
+)
+
+// TokenAuth 使用 TokenIssuer 校验访问令牌，不会自动续期，访问令牌过期后客户端需调用 RefreshHandler 换取新的令牌对
+//
+//	issuer := jwts.NewTokenIssuer(keys, jwts.NewRedisRefreshStore(rdb, ""))
+//	server.POST("/api/v1/account/refresh", gins.RefreshHandler(issuer))
+//	server.Use(gins.TokenAuth(issuer))
+func TokenAuth(issuer *jwts.TokenIssuer) HandlerFunc {
+	return func(c *Context) {
+		if WhitelistAPI[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		token := requestToken(c)
+		if token == "" {
+			c.Abort()
+			SendResponse(c, ErrTokenInvalid, nil)
+			return
+		}
+		claims, err := issuer.ParseAccess(token)
+		if err != nil {
+			c.Abort()
+			SendResponse(c, NewErr(ErrTokenInvalid, err), nil)
+			return
+		}
+		c.Set("user_info", claims.UserInfo)
+		c.Set("token_claims", claims)
+		c.Next()
+	}
+}
+
+// RefreshHandler 从 X-Refresh-Token 请求头或 refresh_token 参数中读取刷新令牌，返回新的令牌对
+func RefreshHandler(issuer *jwts.TokenIssuer) HandlerFunc {
+	return func(c *Context) {
+		token := c.GetHeader("X-Refresh-Token")
+		if token == "" {
+			if val, ok := c.Get("refresh_token"); ok {
+				token = GetString(val)
+			}
+		}
+		if token == "" {
+			var req struct {
+				RefreshToken string `json:"refresh_token" form:"refresh_token"`
+			}
+			_ = c.ShouldBind(&req)
+			token = req.RefreshToken
+		}
+		if token == "" {
+			SendResponse(c, ErrRefreshToken, nil)
+			return
+		}
+		pair, err := issuer.Refresh(token)
+		if err != nil {
+			if errors.Is(err, jwts.ErrRefreshReused) || errors.Is(err, jwts.ErrFamilyRevoked) {
+				SendResponse(c, NewErr(ErrRefreshReuse, err), nil)
+			} else if errors.Is(err, jwts.ErrRefreshInvalid) {
+				SendResponse(c, NewErr(ErrRefreshToken, err), nil)
+			} else {
+				SendResponse(c, err, nil)
+			}
+			return
+		}
+		SendResponse(c, nil, pair)
+	}
+}
+
+// requestToken 依次从 Authorization、token 请求头中读取访问令牌
+func requestToken(c *Context) string {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		token = c.GetHeader("token")
+	}
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token
+}
@@ -14,6 +14,7 @@ import (
 
 type CustomClaims struct {
 	UserInfo interface{}
+	Family   string `json:"fam,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -37,6 +38,9 @@ func GenerateToken(userInfo interface{}, secret string, issuer string, audience
 func ParseToken(tokenString string, secret string) (*CustomClaims, error) {
 	var hmacSampleSecret = []byte(secret)
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrSigningMethod
+		}
 		return hmacSampleSecret, nil
 	})
 	if err != nil {
@@ -49,6 +53,9 @@ func ParseToken(tokenString string, secret string) (*CustomClaims, error) {
 func RefreshToken(tokenString string, secret string, addMinutes int64) (string, error) {
 	var hmacSampleSecret = []byte(secret)
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrSigningMethod
+		}
 		return hmacSampleSecret, nil
 	})
 	if err != nil {
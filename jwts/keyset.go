@@ -0,0 +1,371 @@
+package jwts
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrSigningMethod  = errors.New("unexpected signing method")
+	ErrUnknownKid     = errors.New("unknown kid")
+	ErrNoSigningKey   = errors.New("no signing key in key set")
+	ErrTokenRevoked   = errors.New("token has been revoked")
+	ErrUnsupportedJWK = errors.New("unsupported jwk")
+)
+
+// Key 签名密钥，Private 为空时只能用于验签
+type Key struct {
+	Kid     string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+func NewHMACKey(kid, secret string) *Key {
+	return &Key{Kid: kid, Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+}
+
+func NewRSAKey(kid string, bits int) (*Key, error) {
+	if bits < 2048 {
+		bits = 2048
+	}
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Kid: kid, Method: jwt.SigningMethodRS256, Private: privateKey, Public: &privateKey.PublicKey}, nil
+}
+
+func NewECKey(kid string) (*Key, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Kid: kid, Method: jwt.SigningMethodES256, Private: privateKey, Public: &privateKey.PublicKey}, nil
+}
+
+func NewEdKey(kid string) (*Key, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Kid: kid, Method: jwt.SigningMethodEdDSA, Private: privateKey, Public: publicKey}, nil
+}
+
+// NewKeyFromPEM 从 PEM 格式的私钥创建签名密钥，alg 支持 RS256、ES256、EdDSA
+func NewKeyFromPEM(kid, alg string, pemData []byte) (*Key, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{Kid: kid, Method: jwt.SigningMethodRS256, Private: privateKey, Public: &privateKey.PublicKey}, nil
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		return &Key{Kid: kid, Method: jwt.SigningMethodES256, Private: privateKey, Public: &privateKey.PublicKey}, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		edKey := privateKey.(ed25519.PrivateKey)
+		return &Key{Kid: kid, Method: jwt.SigningMethodEdDSA, Private: edKey, Public: edKey.Public()}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSigningMethod, alg)
+}
+
+// KeySet 按 kid 管理多个密钥，使用当前密钥签名，使用 token 头中的 kid 对应的密钥验签，用于密钥轮换
+type KeySet struct {
+	lock    sync.RWMutex
+	keys    map[string]*Key
+	current string
+	Revoker Revoker
+}
+
+func NewKeySet(keys ...*Key) *KeySet {
+	//use example:
+	//key, _ := jwts.NewEdKey("2023-07")
+	//ks := jwts.NewKeySet(key)
+	//ks.Revoker = jwts.NewRedisRevoker(rdb, "jwt:revoked:")
+	//token, _ := ks.GenerateToken(userInfo, "issuer", "audience", 60)
+	//claims, err := ks.ParseToken(token)
+	ks := &KeySet{keys: make(map[string]*Key)}
+	for _, key := range keys {
+		ks.AddKey(key, ks.current == "")
+	}
+	return ks
+}
+
+// AddKey 添加密钥，current 为 true 时作为签名密钥，旧密钥保留用于验签直到被移除
+func (ks *KeySet) AddKey(key *Key, current bool) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	ks.keys[key.Kid] = key
+	if current && key.Private != nil {
+		ks.current = key.Kid
+	}
+}
+
+func (ks *KeySet) RemoveKey(kid string) {
+	ks.lock.Lock()
+	defer ks.lock.Unlock()
+	delete(ks.keys, kid)
+	if ks.current == kid {
+		ks.current = ""
+	}
+}
+
+func (ks *KeySet) Key(kid string) *Key {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	return ks.keys[kid]
+}
+
+func (ks *KeySet) Current() *Key {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	return ks.keys[ks.current]
+}
+
+func (ks *KeySet) GenerateToken(userInfo interface{}, issuer string, audience string, expiredMinutes int64) (string, error) {
+	nowTime := time.Now().Unix()
+	return ks.Sign(CustomClaims{
+		UserInfo: userInfo,
+		StandardClaims: jwt.StandardClaims{
+			Id:        NewJti(),
+			IssuedAt:  nowTime,
+			NotBefore: nowTime,
+			ExpiresAt: nowTime + expiredMinutes*60,
+			Issuer:    issuer,
+			Audience:  audience,
+		},
+	})
+}
+
+// Sign 使用当前密钥签名，并在 token 头中写入 kid
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := ks.Current()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.Private)
+}
+
+// ParseToken 校验签名算法、kid、有效期，设置了 Revoker 时同时校验 jti 是否已吊销
+func (ks *KeySet) ParseToken(tokenString string) (*CustomClaims, error) {
+	claims := &CustomClaims{}
+	if err := ks.ParseClaims(tokenString, claims); err != nil {
+		return nil, err
+	}
+	if err := ks.checkRevoked(claims.Id); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParseClaims 解析到自定义的 claims，不检查吊销状态
+func (ks *KeySet) ParseClaims(tokenString string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, ks.keyFunc)
+	return err
+}
+
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	key := ks.Key(kid)
+	if key == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKid, kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("%w: %s", ErrSigningMethod, t.Method.Alg())
+	}
+	return key.Public, nil
+}
+
+func (ks *KeySet) checkRevoked(jti string) error {
+	if ks.Revoker == nil || jti == "" {
+		return nil
+	}
+	revoked, err := ks.Revoker.IsRevoked(jti)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RevokeToken 吊销 token，吊销记录保留到 token 过期
+func (ks *KeySet) RevokeToken(tokenString string) error {
+	if ks.Revoker == nil {
+		return errors.New("the key set has no revoker")
+	}
+	claims := &CustomClaims{}
+	if err := ks.ParseClaims(tokenString, claims); err != nil {
+		return err
+	}
+	if claims.Id == "" {
+		return errors.New("the token has no jti")
+	}
+	return ks.Revoker.Revoke(claims.Id, time.Unix(claims.ExpiresAt, 0))
+}
+
+// RefreshToken 使用当前密钥签发新的 token（新的 jti），并吊销旧 token
+func (ks *KeySet) RefreshToken(tokenString string, addMinutes int64) (string, error) {
+	claims, err := ks.ParseToken(tokenString)
+	if err != nil {
+		return "", err
+	}
+	oldJti, oldExpiresAt := claims.Id, claims.ExpiresAt
+	nowTime := time.Now().Unix()
+	claims.Id = NewJti()
+	claims.IssuedAt = nowTime
+	claims.ExpiresAt = nowTime + addMinutes*60
+	newTokenString, err := ks.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+	if ks.Revoker != nil && oldJti != "" {
+		if err = ks.Revoker.Revoke(oldJti, time.Unix(oldExpiresAt, 0)); err != nil {
+			return "", err
+		}
+	}
+	return newTokenString, nil
+}
+
+func NewJti() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ExportJWKS 导出所有非对称密钥的公钥，HMAC 密钥不会导出
+func (ks *KeySet) ExportJWKS() ([]byte, error) {
+	ks.lock.RLock()
+	defer ks.lock.RUnlock()
+	jwks := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.Kid, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return json.Marshal(jwks)
+}
+
+// ImportJWKS 导入 JWKS 中的公钥，导入的密钥只能用于验签
+func (ks *KeySet) ImportJWKS(data []byte) error {
+	jwks := JWKS{}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return err
+	}
+	keys := make([]*Key, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.key()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		ks.AddKey(key, false)
+	}
+	return nil
+}
+
+func (jwk JWK) key() (*Key, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return &Key{Kid: jwk.Kid, Method: jwt.SigningMethodRS256, Public: pub}, nil
+	case "EC":
+		if jwk.Crv != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("%w: %s %s", ErrUnsupportedJWK, jwk.Kty, jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: invalid ec point", ErrUnsupportedJWK)
+		}
+		return &Key{Kid: jwk.Kid, Method: jwt.SigningMethodES256, Public: pub}, nil
+	case "OKP":
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: %s %s", ErrUnsupportedJWK, jwk.Kty, jwk.Crv)
+		}
+		return &Key{Kid: jwk.Kid, Method: jwt.SigningMethodEdDSA, Public: ed25519.PublicKey(x)}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedJWK, jwk.Kty)
+}
@@ -0,0 +1,311 @@
+package jwts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrRefreshInvalid = errors.New("the refresh token is invalid or expired")
+	ErrRefreshReused  = errors.New("the refresh token has been reused, the token family is revoked")
+	ErrFamilyRevoked  = errors.New("the token family has been revoked")
+)
+
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	AccessExpiresAt  int64  `json:"access_expires_at"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+}
+
+// RefreshRecord 服务端保存的刷新令牌信息，同一次登录轮换出的令牌属于同一个 Family
+type RefreshRecord struct {
+	Family    string      `json:"family"`
+	UserInfo  interface{} `json:"user_info"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// RefreshStore 刷新令牌存储，key 为令牌的 sha256 摘要，服务端不保存明文
+type RefreshStore interface {
+	Save(hash string, record *RefreshRecord) error
+	Get(hash string) (*RefreshRecord, error)
+	// MarkUsed 原子地标记令牌已使用，已被使用过时返回 false
+	MarkUsed(hash string, expiresAt time.Time) (bool, error)
+	RevokeFamily(family string, expiresAt time.Time) error
+	IsFamilyRevoked(family string) (bool, error)
+}
+
+// TokenIssuer 签发短期的访问令牌和长期的不透明刷新令牌，刷新令牌每次使用后轮换，旧令牌被重复使用时吊销整个令牌族
+type TokenIssuer struct {
+	Keys       *KeySet
+	Store      RefreshStore
+	Issuer     string
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewTokenIssuer(keys *KeySet, store RefreshStore) *TokenIssuer {
+	//use example:
+	//key, _ := jwts.NewEdKey("k1")
+	//issuer := jwts.NewTokenIssuer(jwts.NewKeySet(key), jwts.NewRedisRefreshStore(rdb, ""))
+	//pair, err := issuer.IssuePair(userInfo)
+	//pair, err = issuer.Refresh(pair.RefreshToken)
+	return &TokenIssuer{
+		Keys:       keys,
+		Store:      store,
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: 7 * 24 * time.Hour,
+	}
+}
+
+// IssuePair 登录成功后调用，创建新的令牌族
+func (ti *TokenIssuer) IssuePair(userInfo interface{}) (*TokenPair, error) {
+	return ti.issue(NewJti(), userInfo)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，旧的刷新令牌随即失效
+func (ti *TokenIssuer) Refresh(refreshToken string) (*TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+	record, err := ti.Store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || time.Now().After(record.ExpiresAt) {
+		return nil, ErrRefreshInvalid
+	}
+	revoked, err := ti.Store.IsFamilyRevoked(record.Family)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrFamilyRevoked
+	}
+	first, err := ti.Store.MarkUsed(hash, record.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		if err = ti.Store.RevokeFamily(record.Family, time.Now().Add(ti.RefreshTTL)); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshReused
+	}
+	return ti.issue(record.Family, record.UserInfo)
+}
+
+// Revoke 注销登录，吊销刷新令牌所在的整个令牌族
+func (ti *TokenIssuer) Revoke(refreshToken string) error {
+	record, err := ti.Store.Get(hashRefreshToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return ErrRefreshInvalid
+	}
+	return ti.Store.RevokeFamily(record.Family, time.Now().Add(ti.RefreshTTL))
+}
+
+// ParseAccess 校验访问令牌，令牌族被吊销后其访问令牌也立即失效
+func (ti *TokenIssuer) ParseAccess(accessToken string) (*CustomClaims, error) {
+	claims, err := ti.Keys.ParseToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Family != "" {
+		revoked, err := ti.Store.IsFamilyRevoked(claims.Family)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrFamilyRevoked
+		}
+	}
+	return claims, nil
+}
+
+func (ti *TokenIssuer) issue(family string, userInfo interface{}) (*TokenPair, error) {
+	now := time.Now()
+	accessExpiresAt := now.Add(ti.AccessTTL)
+	refreshExpiresAt := now.Add(ti.RefreshTTL)
+	accessToken, err := ti.Keys.Sign(CustomClaims{
+		UserInfo: userInfo,
+		Family:   family,
+		StandardClaims: jwt.StandardClaims{
+			Id:        NewJti(),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: accessExpiresAt.Unix(),
+			Issuer:    ti.Issuer,
+			Audience:  ti.Audience,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return nil, err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(b)
+	record := &RefreshRecord{Family: family, UserInfo: userInfo, ExpiresAt: refreshExpiresAt}
+	if err = ti.Store.Save(hashRefreshToken(refreshToken), record); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		AccessExpiresAt:  accessExpiresAt.Unix(),
+		RefreshExpiresAt: refreshExpiresAt.Unix(),
+	}, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+type memoryRefreshItem struct {
+	record *RefreshRecord
+	used   bool
+}
+
+// MemoryRefreshStore 单机内存实现，多实例部署时请使用 RedisRefreshStore
+type MemoryRefreshStore struct {
+	lock     sync.Mutex
+	items    map[string]*memoryRefreshItem
+	families map[string]time.Time
+}
+
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		items:    make(map[string]*memoryRefreshItem),
+		families: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryRefreshStore) Save(hash string, record *RefreshRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for k, item := range s.items {
+		if now.After(item.record.ExpiresAt) {
+			delete(s.items, k)
+		}
+	}
+	for k, expiresAt := range s.families {
+		if now.After(expiresAt) {
+			delete(s.families, k)
+		}
+	}
+	s.items[hash] = &memoryRefreshItem{record: record}
+	return nil
+}
+
+func (s *MemoryRefreshStore) Get(hash string) (*RefreshRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[hash]
+	if !ok {
+		return nil, nil
+	}
+	record := *item.record
+	return &record, nil
+}
+
+func (s *MemoryRefreshStore) MarkUsed(hash string, expiresAt time.Time) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item, ok := s.items[hash]
+	if !ok || item.used {
+		return false, nil
+	}
+	item.used = true
+	return true, nil
+}
+
+func (s *MemoryRefreshStore) RevokeFamily(family string, expiresAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.families[family] = expiresAt
+	return nil
+}
+
+func (s *MemoryRefreshStore) IsFamilyRevoked(family string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	expiresAt, ok := s.families[family]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+type RedisRefreshStore struct {
+	cli    *redisc.RedisCli
+	Prefix string
+}
+
+func NewRedisRefreshStore(cli *redisc.RedisCli, prefix string) *RedisRefreshStore {
+	if prefix == "" {
+		prefix = "jwt:refresh:"
+	}
+	return &RedisRefreshStore{cli: cli, Prefix: prefix}
+}
+
+func (s *RedisRefreshStore) Save(hash string, record *RefreshRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.cli.RdbSetEx(s.Prefix+"token:"+hash, data, ttlSeconds(record.ExpiresAt))
+}
+
+func (s *RedisRefreshStore) Get(hash string) (*RefreshRecord, error) {
+	data, err := s.cli.RdbGet(s.Prefix + "token:" + hash)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &RefreshRecord{}
+	if err = json.Unmarshal([]byte(data), record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *RedisRefreshStore) MarkUsed(hash string, expiresAt time.Time) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	expireTime := time.Second * time.Duration(ttlSeconds(expiresAt))
+	return s.cli.SetNX(ctx, s.Prefix+"used:"+hash, 1, expireTime).Result()
+}
+
+func (s *RedisRefreshStore) RevokeFamily(family string, expiresAt time.Time) error {
+	return s.cli.RdbSetEx(s.Prefix+"family:"+family, 1, ttlSeconds(expiresAt))
+}
+
+func (s *RedisRefreshStore) IsFamilyRevoked(family string) (bool, error) {
+	n, err := s.cli.RdbExists(s.Prefix + "family:" + family)
+	return n > 0, err
+}
+
+func ttlSeconds(expiresAt time.Time) uint64 {
+	ttl := time.Until(expiresAt)
+	if ttl < time.Second {
+		return 1
+	}
+	return uint64(ttl/time.Second) + 1
+}
@@ -0,0 +1,77 @@
+package jwts
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+// Revoker 按 jti 记录被吊销的 token，记录只需保留到 token 过期
+type Revoker interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
+
+// MemoryRevoker 单机内存实现，多实例部署时请使用 RedisRevoker
+type MemoryRevoker struct {
+	lock  sync.Mutex
+	items map[string]time.Time
+	count int
+}
+
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{items: make(map[string]time.Time)}
+}
+
+func (r *MemoryRevoker) Revoke(jti string, expiresAt time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.items[jti] = expiresAt
+	r.count++
+	// 定期清理已过期的记录
+	if r.count%1000 == 0 {
+		now := time.Now()
+		for k, v := range r.items {
+			if now.After(v) {
+				delete(r.items, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRevoker) IsRevoked(jti string) (bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	expiresAt, ok := r.items[jti]
+	if !ok {
+		return false, nil
+	}
+	if time.Now().After(expiresAt) {
+		delete(r.items, jti)
+		return false, nil
+	}
+	return true, nil
+}
+
+type RedisRevoker struct {
+	cli    *redisc.RedisCli
+	Prefix string
+}
+
+func NewRedisRevoker(cli *redisc.RedisCli, prefix string) *RedisRevoker {
+	if prefix == "" {
+		prefix = "jwt:revoked:"
+	}
+	return &RedisRevoker{cli: cli, Prefix: prefix}
+}
+
+func (r *RedisRevoker) Revoke(jti string, expiresAt time.Time) error {
+	return r.cli.RdbSetEx(r.Prefix+jti, 1, ttlSeconds(expiresAt))
+}
+
+func (r *RedisRevoker) IsRevoked(jti string) (bool, error) {
+	n, err := r.cli.RdbExists(r.Prefix + jti)
+	return n > 0, err
+}
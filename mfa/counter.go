@@ -0,0 +1,64 @@
+package mfa
+
+import (
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+// MemoryCounterStore 单机使用的 CounterStore
+type MemoryCounterStore struct {
+	lock sync.Mutex
+	last map[string]uint64
+}
+
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{last: make(map[string]uint64)}
+}
+
+func (s *MemoryCounterStore) Use(key string, counter uint64) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if last, ok := s.last[key]; ok && counter <= last {
+		return false, nil
+	}
+	s.last[key] = counter
+	return true, nil
+}
+
+var counterUseScript = redisc.NewScript(`
+local last = redis.call('GET', KEYS[1])
+if last and tonumber(last) >= tonumber(ARGV[1]) then
+	return 0
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// RedisCounterStore 多实例共享的 CounterStore
+type RedisCounterStore struct {
+	cli    redisc.ScriptRunner
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisCounterStore ttl 为记录的保留时间，TOTP 只需要大于 (2*skew+1)*period，HOTP 应为 0（不过期）
+func NewRedisCounterStore(cli redisc.ScriptRunner, prefix string, ttl time.Duration) *RedisCounterStore {
+	//use example:
+	//store := mfa.NewRedisCounterStore(rdb, "otp:used:", time.Hour)
+	return &RedisCounterStore{cli: cli, prefix: prefix, ttl: ttl}
+}
+
+func (s *RedisCounterStore) Use(key string, counter uint64) (bool, error) {
+	reply, err := s.cli.RdbEvalScript(counterUseScript, []string{s.prefix + key}, counter, s.ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
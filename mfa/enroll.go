@@ -0,0 +1,270 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/AbnerEarl/goutils/qrcode"
+)
+
+var (
+	ErrEnrollmentNotFound = errors.New("the mfa enrollment does not exist")
+	ErrEnrollmentExpired  = errors.New("the mfa enrollment has expired, please start again")
+	ErrMfaEnabled         = errors.New("mfa is already enabled")
+	ErrMfaNotEnabled      = errors.New("mfa is not enabled")
+	ErrRecoveryCode       = errors.New("invalid or used recovery code")
+)
+
+// Enrollment 用户的 TOTP 绑定信息，Confirmed 为 false 时表示还在等待第一次验证
+type Enrollment struct {
+	UserId      string     `json:"user_id"`
+	Secret      string     `json:"secret"`
+	Algorithm   Algorithm  `json:"algorithm"`
+	Digits      int        `json:"digits"`
+	Period      int64      `json:"period"`
+	Confirmed   bool       `json:"confirmed"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+}
+
+// EnrollmentStore 保存绑定信息和恢复码，恢复码只保存哈希
+type EnrollmentStore interface {
+	// Get 不存在时返回 ErrEnrollmentNotFound
+	Get(userId string) (*Enrollment, error)
+	// Save 不存在时新增，存在时覆盖
+	Save(e *Enrollment) error
+	// Delete 同时删除恢复码
+	Delete(userId string) error
+	// ReplaceRecoveryCodes 删除旧的恢复码并保存新的恢复码
+	ReplaceRecoveryCodes(userId string, hashes []string) error
+	// UseRecoveryCode 恢复码存在且未使用时标记为已使用并返回 true，需要保证并发时只有一次成功
+	UseRecoveryCode(userId, hash string) (bool, error)
+	// CountRecoveryCodes 未使用的恢复码数量
+	CountRecoveryCodes(userId string) (int, error)
+}
+
+// PendingEnrollment 返回给前端展示的绑定信息
+type PendingEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+// PNG 二维码图片，size 为宽高像素
+func (p *PendingEnrollment) PNG(size int) ([]byte, error) {
+	return qrcode.Encode(p.Uri, qrcode.Medium, size)
+}
+
+// SVG 二维码矢量图
+func (p *PendingEnrollment) SVG(size int) ([]byte, error) {
+	q, err := qrcode.New(p.Uri, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	return q.SVG(size), nil
+}
+
+// Enroller 负责 TOTP 的绑定、验证和恢复码
+type Enroller struct {
+	Store  EnrollmentStore
+	Issuer string
+	// Digits、Algorithm、Period 用于新的绑定，已绑定的用户使用绑定时的设置
+	Digits    int
+	Algorithm Algorithm
+	Period    int64
+	// PendingTTL 等待确认的有效期，默认 15 分钟
+	PendingTTL time.Duration
+	// RecoveryCodes 恢复码数量，默认 10
+	RecoveryCodes int
+	// Counters 防止验证码重复使用，默认单机内存存储，多实例部署时使用 RedisCounterStore
+	Counters CounterStore
+}
+
+func NewEnroller(store EnrollmentStore, issuer string) *Enroller {
+	//use example:
+	//enroller := mfa.NewEnroller(mfa.NewDBEnrollmentStore(db), "ACME Co")
+	//pending, err := enroller.Begin(userId, "john@example.com")
+	//png, err := pending.PNG(256)
+	//recoveryCodes, err := enroller.Confirm(userId, code)
+	//err = enroller.Verify(userId, code)
+	return &Enroller{
+		Store:         store,
+		Issuer:        issuer,
+		Digits:        6,
+		Algorithm:     AlgorithmSHA1,
+		Period:        30,
+		PendingTTL:    15 * time.Minute,
+		RecoveryCodes: 10,
+		Counters:      NewMemoryCounterStore(),
+	}
+}
+
+// Begin 生成新的密钥，等待 Confirm 确认，已启用时返回 ErrMfaEnabled
+func (e *Enroller) Begin(userId, account string) (*PendingEnrollment, error) {
+	old, err := e.Store.Get(userId)
+	if err != nil && err != ErrEnrollmentNotFound {
+		return nil, err
+	}
+	if old != nil && old.Confirmed {
+		return nil, ErrMfaEnabled
+	}
+	secret, err := GenerateSecret(20)
+	if err != nil {
+		return nil, err
+	}
+	enrollment := &Enrollment{
+		UserId:    userId,
+		Secret:    secret,
+		Algorithm: e.Algorithm,
+		Digits:    e.Digits,
+		Period:    e.Period,
+		CreatedAt: time.Now(),
+	}
+	t, err := e.totp(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.Store.Save(enrollment); err != nil {
+		return nil, err
+	}
+	label := account
+	if e.Issuer != "" {
+		label = e.Issuer + ":" + account
+	}
+	return &PendingEnrollment{Secret: secret, Uri: t.Uri(label, e.Issuer)}, nil
+}
+
+// Confirm 使用第一个有效的验证码确认绑定，返回明文恢复码，明文只在这里返回一次
+func (e *Enroller) Confirm(userId, code string) ([]string, error) {
+	enrollment, err := e.Store.Get(userId)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Confirmed {
+		return nil, ErrMfaEnabled
+	}
+	if e.PendingTTL > 0 && time.Since(enrollment.CreatedAt) > e.PendingTTL {
+		return nil, ErrEnrollmentExpired
+	}
+	t, err := e.totp(enrollment)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.Validate(code); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	enrollment.Confirmed = true
+	enrollment.ConfirmedAt = &now
+	if err = e.Store.Save(enrollment); err != nil {
+		return nil, err
+	}
+	return e.RegenerateRecoveryCodes(userId)
+}
+
+// Verify 登录时验证 TOTP 验证码
+func (e *Enroller) Verify(userId, code string) error {
+	enrollment, err := e.enabled(userId)
+	if err != nil {
+		return err
+	}
+	t, err := e.totp(enrollment)
+	if err != nil {
+		return err
+	}
+	return t.Validate(code)
+}
+
+// VerifyRecoveryCode 验证恢复码，成功后该恢复码失效
+func (e *Enroller) VerifyRecoveryCode(userId, code string) error {
+	if _, err := e.enabled(userId); err != nil {
+		return err
+	}
+	ok, err := e.Store.UseRecoveryCode(userId, hashRecoveryCode(userId, code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRecoveryCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 生成新的恢复码，旧的恢复码全部失效
+func (e *Enroller) RegenerateRecoveryCodes(userId string) ([]string, error) {
+	n := e.RecoveryCodes
+	if n <= 0 {
+		n = 10
+	}
+	codes := make([]string, n)
+	hashes := make([]string, n)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashRecoveryCode(userId, code)
+	}
+	if err := e.Store.ReplaceRecoveryCodes(userId, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RemainingRecoveryCodes 剩余可用的恢复码数量，可以在数量较少时提醒用户重新生成
+func (e *Enroller) RemainingRecoveryCodes(userId string) (int, error) {
+	return e.Store.CountRecoveryCodes(userId)
+}
+
+// Disable 关闭 MFA，删除密钥和恢复码
+func (e *Enroller) Disable(userId string) error {
+	return e.Store.Delete(userId)
+}
+
+func (e *Enroller) enabled(userId string) (*Enrollment, error) {
+	enrollment, err := e.Store.Get(userId)
+	if err == ErrEnrollmentNotFound {
+		return nil, ErrMfaNotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !enrollment.Confirmed {
+		return nil, ErrMfaNotEnabled
+	}
+	return enrollment, nil
+}
+
+func (e *Enroller) totp(enrollment *Enrollment) (*Totp, error) {
+	opts := []OtpOption{
+		WithDigits(enrollment.Digits),
+		WithAlgorithm(enrollment.Algorithm),
+		WithPeriod(time.Duration(enrollment.Period) * time.Second),
+	}
+	if e.Counters != nil {
+		opts = append(opts, WithCounterStore(e.Counters, enrollment.UserId))
+	}
+	return NewTotp(enrollment.Secret, opts...)
+}
+
+// newRecoveryCode 80 位随机数，格式为 XXXX-XXXX-XXXX-XXXX
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	s := base32.StdEncoding.EncodeToString(buf)
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// hashRecoveryCode 恢复码有 80 位随机数，不需要慢哈希；加入 userId 使相同的恢复码在不同用户下哈希不同
+func hashRecoveryCode(userId, code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(userId + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
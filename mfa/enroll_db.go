@@ -0,0 +1,128 @@
+package mfa
+
+import (
+	"time"
+
+	"github.com/AbnerEarl/goutils/dbs"
+	"gorm.io/gorm"
+)
+
+type MfaEnrollmentModel struct {
+	dbs.BaseModel
+	UserId      string     `json:"user_id" gorm:"column:user_id;type:varchar(128);uniqueIndex;comment:'用户ID'"`
+	Secret      string     `json:"-" gorm:"column:secret;type:varchar(128);comment:'base32 密钥'"`
+	Algorithm   int        `json:"algorithm" gorm:"column:algorithm;comment:'哈希算法'"`
+	Digits      int        `json:"digits" gorm:"column:digits;comment:'验证码位数'"`
+	Period      int64      `json:"period" gorm:"column:period;comment:'周期（秒）'"`
+	Confirmed   bool       `json:"confirmed" gorm:"column:confirmed;comment:'是否已确认'"`
+	ConfirmedAt *time.Time `json:"confirmed_at" gorm:"column:confirmed_at;null;comment:'确认时间'"`
+}
+
+func (m *MfaEnrollmentModel) TableName() string {
+	return "mfa_enrollment"
+}
+
+type MfaRecoveryCodeModel struct {
+	Id       uint64     `json:"id" gorm:"primary_key;column:id;comment:'主键ID'"`
+	UserId   string     `json:"user_id" gorm:"column:user_id;type:varchar(128);index;comment:'用户ID'"`
+	CodeHash string     `json:"-" gorm:"column:code_hash;type:varchar(64);uniqueIndex;comment:'恢复码哈希'"`
+	UsedAt   *time.Time `json:"used_at" gorm:"column:used_at;null;comment:'使用时间'"`
+}
+
+func (m *MfaRecoveryCodeModel) TableName() string {
+	return "mfa_recovery_code"
+}
+
+// DBEnrollmentStore 基于 dbs 的 EnrollmentStore
+type DBEnrollmentStore struct {
+	db *dbs.DB
+}
+
+func NewDBEnrollmentStore(db *dbs.DB) *DBEnrollmentStore {
+	//use example:
+	//db.Migration([]interface{}{&mfa.MfaEnrollmentModel{}, &mfa.MfaRecoveryCodeModel{}})
+	//store := mfa.NewDBEnrollmentStore(db)
+	return &DBEnrollmentStore{db: db}
+}
+
+func (s *DBEnrollmentStore) Get(userId string) (*Enrollment, error) {
+	var models []*MfaEnrollmentModel
+	if err := s.db.DB.Where("user_id = ?", userId).Limit(1).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, ErrEnrollmentNotFound
+	}
+	m := models[0]
+	return &Enrollment{
+		UserId:      m.UserId,
+		Secret:      m.Secret,
+		Algorithm:   Algorithm(m.Algorithm),
+		Digits:      m.Digits,
+		Period:      m.Period,
+		Confirmed:   m.Confirmed,
+		CreatedAt:   m.CreatedAt,
+		ConfirmedAt: m.ConfirmedAt,
+	}, nil
+}
+
+func (s *DBEnrollmentStore) Save(e *Enrollment) error {
+	var models []*MfaEnrollmentModel
+	if err := s.db.DB.Where("user_id = ?", e.UserId).Limit(1).Find(&models).Error; err != nil {
+		return err
+	}
+	model := &MfaEnrollmentModel{UserId: e.UserId}
+	if len(models) > 0 {
+		model = models[0]
+	}
+	model.Secret = e.Secret
+	model.Algorithm = int(e.Algorithm)
+	model.Digits = e.Digits
+	model.Period = e.Period
+	model.Confirmed = e.Confirmed
+	model.ConfirmedAt = e.ConfirmedAt
+	model.CreatedAt = e.CreatedAt
+	return s.db.DB.Save(model).Error
+}
+
+func (s *DBEnrollmentStore) Delete(userId string) error {
+	return s.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&MfaRecoveryCodeModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&MfaEnrollmentModel{}).Error
+	})
+}
+
+func (s *DBEnrollmentStore) ReplaceRecoveryCodes(userId string, hashes []string) error {
+	return s.db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&MfaRecoveryCodeModel{}).Error; err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+		models := make([]*MfaRecoveryCodeModel, len(hashes))
+		for i, hash := range hashes {
+			models[i] = &MfaRecoveryCodeModel{UserId: userId, CodeHash: hash}
+		}
+		return tx.Create(&models).Error
+	})
+}
+
+// UseRecoveryCode 条件更新保证并发时只有一次成功
+func (s *DBEnrollmentStore) UseRecoveryCode(userId, hash string) (bool, error) {
+	result := s.db.DB.Model(&MfaRecoveryCodeModel{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (s *DBEnrollmentStore) CountRecoveryCodes(userId string) (int, error) {
+	var count int64
+	err := s.db.DB.Model(&MfaRecoveryCodeModel{}).Where("user_id = ? AND used_at IS NULL", userId).Count(&count).Error
+	return int(count), err
+}
@@ -0,0 +1,256 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrOtpDigits    = errors.New("the number of otp digits must be between 6 and 10")
+	ErrOtpAlgorithm = errors.New("unsupported otp algorithm")
+	ErrOtpSecret    = errors.New("invalid base32 otp secret")
+	ErrOtpInvalid   = errors.New("invalid otp code")
+	ErrOtpReplayed  = errors.New("the otp code has already been used")
+)
+
+const base32Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+type Algorithm int
+
+const (
+	AlgorithmSHA1 Algorithm = iota
+	AlgorithmSHA256
+	AlgorithmSHA512
+)
+
+// String 返回 otpauth URI 中 algorithm 参数的值
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmSHA256:
+		return "SHA256"
+	case AlgorithmSHA512:
+		return "SHA512"
+	}
+	return "SHA1"
+}
+
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, ErrOtpAlgorithm
+}
+
+// GenerateSecret 使用 crypto/rand 生成 size 字节的密钥，返回不带填充的 base32 字符串，size 小于 10 时使用 20（RFC 4226 推荐 160 位）
+func GenerateSecret(size int) (string, error) {
+	if size < 10 {
+		size = 20
+	}
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key), nil
+}
+
+// DecodeSecret 解码 base32 密钥，兼容小写、空格、横线和填充，末尾不足一个字节的位被忽略，和常见的验证器客户端一致
+func DecodeSecret(secret string) ([]byte, error) {
+	var key []byte
+	var buffer uint64
+	bits := 0
+	for _, c := range strings.ToUpper(secret) {
+		if c == ' ' || c == '-' || c == '=' {
+			continue
+		}
+		i := strings.IndexRune(base32Alphabet, c)
+		if i < 0 {
+			return nil, ErrOtpSecret
+		}
+		buffer = buffer<<5 | uint64(i)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			key = append(key, byte(buffer>>uint(bits)))
+		}
+	}
+	if len(key) == 0 {
+		return nil, ErrOtpSecret
+	}
+	return key, nil
+}
+
+// CounterStore 记录每个 key 最后一次使用的计数器（HOTP 的计数器或 TOTP 的时间步），防止同一个验证码被使用两次
+type CounterStore interface {
+	// Use 计数器大于上次使用的值时记录并返回 true，否则返回 false
+	Use(key string, counter uint64) (bool, error)
+}
+
+type OtpOption func(o *otpOptions)
+
+type otpOptions struct {
+	digits    int
+	algorithm Algorithm
+	period    int64
+	skew      uint
+	lookAhead uint
+	clock     func() int64
+	store     CounterStore
+	storeKey  string
+}
+
+// WithDigits 验证码位数，6 到 10，默认 6
+func WithDigits(digits int) OtpOption {
+	return func(o *otpOptions) {
+		o.digits = digits
+	}
+}
+
+// WithAlgorithm 默认 SHA1，大部分验证器客户端只支持 SHA1
+func WithAlgorithm(algorithm Algorithm) OtpOption {
+	return func(o *otpOptions) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithCounterStore 防重放，key 一般为用户 id，同一个 key 使用过的验证码和更早的验证码不能再使用
+func WithCounterStore(store CounterStore, key string) OtpOption {
+	return func(o *otpOptions) {
+		o.store = store
+		o.storeKey = key
+	}
+}
+
+// WithLookAhead HOTP 验证时向后查找的计数器数量，默认 0，用户多次生成验证码没有使用时需要设置
+func WithLookAhead(n uint) OtpOption {
+	return func(o *otpOptions) {
+		o.lookAhead = n
+	}
+}
+
+func loadOtpOptions(opts []OtpOption) (*otpOptions, error) {
+	o := &otpOptions{digits: 6, period: 30, skew: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.digits < 6 || o.digits > 10 {
+		return nil, ErrOtpDigits
+	}
+	if _, err := o.algorithm.hash(); err != nil {
+		return nil, err
+	}
+	if o.period <= 0 {
+		o.period = 30
+	}
+	return o, nil
+}
+
+// Hotp RFC 4226 基于计数器的一次性密码
+type Hotp struct {
+	key  []byte
+	opts *otpOptions
+}
+
+func NewHotp(secret string, opts ...OtpOption) (*Hotp, error) {
+	//use example:
+	//h, err := mfa.NewHotp(secret, mfa.WithLookAhead(3))
+	//next, err := h.Validate(code, user.Counter)
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	o, err := loadOtpOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Hotp{key: key, opts: o}, nil
+}
+
+func (h *Hotp) Generate(counter uint64) string {
+	return hotpCode(h.key, counter, h.opts.digits, h.opts.algorithm)
+}
+
+// Validate 验证 counter 到 counter+lookAhead 的验证码，成功时返回下一次使用的计数器
+func (h *Hotp) Validate(code string, counter uint64) (uint64, error) {
+	for i := uint64(0); i <= uint64(h.opts.lookAhead); i++ {
+		if !codeEqual(h.Generate(counter+i), code) {
+			continue
+		}
+		if err := h.opts.use(counter + i); err != nil {
+			return counter, err
+		}
+		return counter + i + 1, nil
+	}
+	return counter, ErrOtpInvalid
+}
+
+// Uri 生成 otpauth://hotp 地址，label 格式为 "应用名称:账号名称"
+func (h *Hotp) Uri(label, issuer string, counter uint64) string {
+	v := otpValues(h.key, issuer, h.opts)
+	v.Set("counter", fmt.Sprint(counter))
+	return "otpauth://hotp/" + url.PathEscape(label) + "?" + v.Encode()
+}
+
+func (o *otpOptions) use(counter uint64) error {
+	if o.store == nil {
+		return nil
+	}
+	ok, err := o.store.Use(o.storeKey, counter)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrOtpReplayed
+	}
+	return nil
+}
+
+func otpValues(key []byte, issuer string, o *otpOptions) url.Values {
+	v := url.Values{}
+	v.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key))
+	if issuer != "" {
+		v.Set("issuer", issuer)
+	}
+	v.Set("algorithm", o.algorithm.String())
+	v.Set("digits", fmt.Sprint(o.digits))
+	return v
+}
+
+// hotpCode RFC 4226 第 5.3 节的动态截断，10 位时结果最大为 2^31-1，前面补零
+func hotpCode(key []byte, counter uint64, digits int, algorithm Algorithm) string {
+	newHash, err := algorithm.hash()
+	if err != nil {
+		return ""
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(newHash, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+func codeEqual(expected, code string) bool {
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.TrimSpace(code))) == 1
+}
@@ -0,0 +1,102 @@
+package mfa
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// WithPeriod TOTP 的时间步长，默认 30 秒
+func WithPeriod(period time.Duration) OtpOption {
+	return func(o *otpOptions) {
+		o.period = int64(period / time.Second)
+	}
+}
+
+// WithSkew TOTP 验证时前后允许的时间步数，默认 1，即允许前后各 30 秒的误差
+func WithSkew(skew uint) OtpOption {
+	return func(o *otpOptions) {
+		o.skew = skew
+	}
+}
+
+// WithClock 指定当前时间，默认 time.Now，用于测试或使用校准过的时间
+func WithClock(now func() time.Time) OtpOption {
+	return func(o *otpOptions) {
+		o.clock = func() int64 {
+			return now().Unix()
+		}
+	}
+}
+
+// Totp RFC 6238 基于时间的一次性密码
+type Totp struct {
+	key  []byte
+	opts *otpOptions
+}
+
+func NewTotp(secret string, opts ...OtpOption) (*Totp, error) {
+	//use example:
+	//secret, _ := mfa.GenerateSecret(20)
+	//t, err := mfa.NewTotp(secret, mfa.WithCounterStore(store, userId))
+	//if err := t.Validate(code); err != nil {
+	//	return err
+	//}
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	o, err := loadOtpOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.clock == nil {
+		o.clock = func() int64 {
+			return time.Now().Unix()
+		}
+	}
+	return &Totp{key: key, opts: o}, nil
+}
+
+// Generate 当前时间的验证码
+func (t *Totp) Generate() string {
+	return t.GenerateAt(t.opts.clock())
+}
+
+// GenerateAt unix 为秒级时间戳
+func (t *Totp) GenerateAt(unix int64) string {
+	return hotpCode(t.key, uint64(unix/t.opts.period), t.opts.digits, t.opts.algorithm)
+}
+
+// Validate 验证码有效时返回 nil，设置了 CounterStore 时同一个时间步的验证码只能使用一次
+func (t *Totp) Validate(code string) error {
+	_, err := t.ValidateAt(code, t.opts.clock())
+	return err
+}
+
+// ValidateAt 验证 unix 时间前后 skew 个时间步的验证码，返回匹配的时间步
+func (t *Totp) ValidateAt(code string, unix int64) (uint64, error) {
+	step := unix / t.opts.period
+	skew := int64(t.opts.skew)
+	for i := -skew; i <= skew; i++ {
+		if step+i < 0 {
+			continue
+		}
+		counter := uint64(step + i)
+		if !codeEqual(hotpCode(t.key, counter, t.opts.digits, t.opts.algorithm), code) {
+			continue
+		}
+		if err := t.opts.use(counter); err != nil {
+			return counter, err
+		}
+		return counter, nil
+	}
+	return 0, ErrOtpInvalid
+}
+
+// Uri 生成 otpauth://totp 地址，label 格式为 "应用名称:账号名称"
+func (t *Totp) Uri(label, issuer string) string {
+	v := otpValues(t.key, issuer, t.opts)
+	v.Set("period", fmt.Sprint(t.opts.period))
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + v.Encode()
+}
@@ -353,6 +353,53 @@ func (q *QRCode) PNG(size int) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// SVG returns the QR Code as an SVG image.
+//
+// size is both the image width and height in pixels, the modules are scaled
+// with a viewBox so the image stays sharp at any size. Negative values for size
+// set the size of each module, as in Image().
+func (q *QRCode) SVG(size int) []byte {
+	// Build QR code.
+	q.encode()
+
+	realSize := q.symbol.size
+	if size < 0 {
+		size = size * -1 * realSize
+	}
+	if size < realSize {
+		size = realSize
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, realSize, realSize)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/><path fill="%s" d="`,
+		realSize, realSize, svgColor(q.BackgroundColor), svgColor(q.ForegroundColor))
+	for y, row := range q.symbol.bitmap() {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			// merge horizontal runs of dark modules into one rectangle
+			start := x
+			for x+1 < len(row) && row[x+1] {
+				x++
+			}
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start, y, x-start+1, x-start+1)
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.Bytes()
+}
+
+func svgColor(c color.Color) string {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
 // Write writes the QR Code as a PNG image to io.Writer.
 //
 // size is both the image width and height in pixels. If size is too small then
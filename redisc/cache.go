@@ -0,0 +1,410 @@
+package redisc
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/ugorji/go/codec"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// ErrCacheMiss 缓存中没有该 key
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrNotFound loader 返回该错误时会被缓存 NegativeTTL，避免不存在的数据穿透到数据源
+	ErrNotFound = errors.New("not found")
+)
+
+// CacheClient RedisCli、RedisClusterCli、UniversalClient 都实现了该接口
+type CacheClient interface {
+	redis.Cmdable
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+type CacheCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func (c msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, c.handle).Encode(v)
+	return data, err
+}
+
+func (c msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, c.handle).Decode(v)
+}
+
+var (
+	JsonCodec    CacheCodec = jsonCodec{}
+	GobCodec     CacheCodec = gobCodec{}
+	MsgpackCodec CacheCodec = msgpackCodec{handle: &codec.MsgpackHandle{WriteExt: true}}
+)
+
+// 写入 redis 的值以一个字节标记开头，区分正常值和空值
+const (
+	cacheFlagValue    byte = 1
+	cacheFlagNotFound byte = 0
+)
+
+type CacheOption func(o *cacheOptions)
+
+type cacheOptions struct {
+	codec       CacheCodec
+	ttl         time.Duration
+	jitter      float64
+	negativeTTL time.Duration
+	localSize   int
+	localTTL    time.Duration
+	channel     string
+}
+
+// WithCacheCodec 序列化方式，默认 JsonCodec
+func WithCacheCodec(c CacheCodec) CacheOption {
+	return func(o *cacheOptions) {
+		o.codec = c
+	}
+}
+
+// WithCacheTTL 过期时间，实际过期时间会在 [ttl, ttl*(1+jitter)) 内随机，避免大量 key 同时过期
+func WithCacheTTL(ttl time.Duration, jitter float64) CacheOption {
+	return func(o *cacheOptions) {
+		o.ttl = ttl
+		o.jitter = jitter
+	}
+}
+
+// WithNegativeTTL loader 返回 ErrNotFound 时缓存空值的时间，0 表示不缓存空值
+func WithNegativeTTL(ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithLocalCache 开启进程内 LRU 缓存，其他实例修改或删除 key 时通过 pub/sub 通知失效，
+// 订阅断开期间的通知会丢失，因此本地缓存的 ttl 应该较短
+func WithLocalCache(size int, ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.localSize = size
+		o.localTTL = ttl
+	}
+}
+
+// WithInvalidateChannel 本地缓存失效通知的频道，默认为 prefix + "invalidate"
+func WithInvalidateChannel(channel string) CacheOption {
+	return func(o *cacheOptions) {
+		o.channel = channel
+	}
+}
+
+// Cache 带类型的 redis 缓存
+type Cache[T any] struct {
+	cli    CacheClient
+	prefix string
+	opts   cacheOptions
+	group  singleflight.Group
+	local  *lruCache[T]
+	id     string
+	pubsub *redis.PubSub
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func NewCache[T any](cli CacheClient, prefix string, opts ...CacheOption) (*Cache[T], error) {
+	//use example:
+	//users, err := redisc.NewCache[User](rdb, "cache:user:", redisc.WithCacheTTL(10*time.Minute, 0.1), redisc.WithNegativeTTL(time.Minute), redisc.WithLocalCache(1000, 10*time.Second))
+	//user, err := users.GetOrLoad("1", func() (User, error) { return loadUser(1) })
+	//err = users.Delete("1")
+	o := cacheOptions{codec: JsonCodec, ttl: 10 * time.Minute}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.channel == "" {
+		o.channel = prefix + "invalidate"
+	}
+	c := &Cache[T]{cli: cli, prefix: prefix, opts: o, stop: make(chan struct{})}
+	if o.localSize > 0 && o.localTTL > 0 {
+		nonce := make([]byte, 8)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		c.id = hex.EncodeToString(nonce)
+		c.local = newLruCache[T](o.localSize)
+		ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+		defer cancel()
+		c.pubsub = cli.Subscribe(context.Background(), o.channel)
+		// 等待订阅成功，避免订阅前的失效通知丢失
+		if _, err := c.pubsub.Receive(ctx); err != nil {
+			_ = c.pubsub.Close()
+			return nil, err
+		}
+		go c.listen()
+	}
+	return c, nil
+}
+
+// Get 缓存的空值返回 ErrNotFound，没有缓存返回 ErrCacheMiss
+func (c *Cache[T]) Get(key string) (T, error) {
+	if c.local != nil {
+		if value, notFound, ok := c.local.get(key); ok {
+			if notFound {
+				return value, ErrNotFound
+			}
+			return value, nil
+		}
+	}
+	var zero T
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	data, err := c.cli.Get(ctx, c.prefix+key).Bytes()
+	if err == redis.Nil {
+		return zero, ErrCacheMiss
+	}
+	if err != nil {
+		return zero, err
+	}
+	if len(data) == 0 {
+		return zero, ErrCacheMiss
+	}
+	if data[0] == cacheFlagNotFound {
+		if c.local != nil {
+			c.local.set(key, zero, true, c.opts.localTTL)
+		}
+		return zero, ErrNotFound
+	}
+	var value T
+	if err = c.opts.codec.Unmarshal(data[1:], &value); err != nil {
+		return zero, err
+	}
+	if c.local != nil {
+		c.local.set(key, value, false, c.opts.localTTL)
+	}
+	return value, nil
+}
+
+func (c *Cache[T]) Set(key string, value T) error {
+	return c.SetWithTTL(key, value, c.opts.ttl)
+}
+
+func (c *Cache[T]) SetWithTTL(key string, value T, ttl time.Duration) error {
+	data, err := c.opts.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err = c.write(key, append([]byte{cacheFlagValue}, data...), ttl); err != nil {
+		return err
+	}
+	if c.local != nil {
+		c.local.set(key, value, false, c.opts.localTTL)
+	}
+	return nil
+}
+
+// Delete 删除 key，并通知其他实例删除本地缓存
+func (c *Cache[T]) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = c.prefix + key
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	// 集群模式下多个 key 可能不在同一个槽，逐个删除
+	for _, key := range full {
+		if err := c.cli.Del(ctx, key).Err(); err != nil {
+			return err
+		}
+	}
+	if c.local != nil {
+		for _, key := range keys {
+			c.local.remove(key)
+		}
+	}
+	return c.publish(keys...)
+}
+
+// GetOrLoad 缓存未命中时调用 loader 并写入缓存，同一进程内同一个 key 的并发加载只执行一次
+func (c *Cache[T]) GetOrLoad(key string, loader func() (T, error)) (T, error) {
+	value, err := c.Get(key)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return value, err
+	}
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		// 等待期间其他请求可能已经写入缓存
+		if value, err := c.Get(key); err == nil || errors.Is(err, ErrNotFound) {
+			return value, err
+		}
+		value, err := loader()
+		if errors.Is(err, ErrNotFound) {
+			if c.opts.negativeTTL > 0 {
+				if werr := c.write(key, []byte{cacheFlagNotFound}, c.opts.negativeTTL); werr == nil && c.local != nil {
+					c.local.set(key, value, true, c.opts.localTTL)
+				}
+			}
+			return value, err
+		}
+		if err != nil {
+			return value, err
+		}
+		// 写缓存失败不影响返回结果
+		_ = c.SetWithTTL(key, value, c.opts.ttl)
+		return value, nil
+	})
+	value, _ = v.(T)
+	return value, err
+}
+
+// Close 取消失效通知的订阅
+func (c *Cache[T]) Close() error {
+	var err error
+	c.once.Do(func() {
+		close(c.stop)
+		if c.pubsub != nil {
+			err = c.pubsub.Close()
+		}
+	})
+	return err
+}
+
+func (c *Cache[T]) write(key string, data []byte, ttl time.Duration) error {
+	if ttl > 0 && c.opts.jitter > 0 {
+		ttl += time.Duration(mrand.Int63n(int64(float64(ttl)*c.opts.jitter) + 1))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	if err := c.cli.Set(ctx, c.prefix+key, data, ttl).Err(); err != nil {
+		return err
+	}
+	return c.publish(key)
+}
+
+// publish 消息格式为 实例id|key
+func (c *Cache[T]) publish(keys ...string) error {
+	if c.local == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	for _, key := range keys {
+		if err := c.cli.Publish(ctx, c.opts.channel, c.id+"|"+key).Err(); err != nil {
+			return fmt.Errorf("publish cache invalidation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Cache[T]) listen() {
+	ch := c.pubsub.Channel()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			parts := strings.SplitN(msg.Payload, "|", 2)
+			if len(parts) == 2 && parts[0] != c.id {
+				c.local.remove(parts[1])
+			}
+		}
+	}
+}
+
+type lruEntry[T any] struct {
+	key      string
+	value    T
+	notFound bool
+	expireAt time.Time
+}
+
+type lruCache[T any] struct {
+	lock  sync.Mutex
+	size  int
+	list  *list.List
+	items map[string]*list.Element
+}
+
+func newLruCache[T any](size int) *lruCache[T] {
+	return &lruCache[T]{size: size, list: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lruCache[T]) get(key string) (T, bool, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var zero T
+	elem, ok := l.items[key]
+	if !ok {
+		return zero, false, false
+	}
+	entry := elem.Value.(*lruEntry[T])
+	if time.Now().After(entry.expireAt) {
+		l.list.Remove(elem)
+		delete(l.items, key)
+		return zero, false, false
+	}
+	l.list.MoveToFront(elem)
+	return entry.value, entry.notFound, true
+}
+
+func (l *lruCache[T]) set(key string, value T, notFound bool, ttl time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entry := &lruEntry[T]{key: key, value: value, notFound: notFound, expireAt: time.Now().Add(ttl)}
+	if elem, ok := l.items[key]; ok {
+		elem.Value = entry
+		l.list.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.list.PushFront(entry)
+	for l.list.Len() > l.size {
+		oldest := l.list.Back()
+		l.list.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry[T]).key)
+	}
+}
+
+func (l *lruCache[T]) remove(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if elem, ok := l.items[key]; ok {
+		l.list.Remove(elem)
+		delete(l.items, key)
+	}
+}
@@ -40,6 +40,15 @@ type TX struct {
 	*redis.Tx
 }
 
+// Script lua 脚本，执行时优先使用 EVALSHA，脚本未缓存时自动回退到 EVAL
+type Script struct {
+	*redis.Script
+}
+
+func NewScript(src string) *Script {
+	return &Script{redis.NewScript(src)}
+}
+
 func InitRedis(addr string, db, poolSize, idleConns int, username, password string) *RedisCli {
 	if db < 0 {
 		db = 0
@@ -195,6 +204,15 @@ func (rsc *RedisCli) RdbSetNx(key string, value interface{}, expireTimeSecond ui
 	return err
 }
 
+// RdbSetNxBool 返回 key 是否设置成功
+func (rsc *RedisCli) RdbSetNxBool(key string, value interface{}, expireTimeSecond uint64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	expireTime := time.Second * time.Duration(expireTimeSecond)
+	result, err := rsc.SetNX(ctx, key, value, expireTime).Result()
+	return result, err
+}
+
 func (rsc *RedisCli) RdbLPush(key string, values ...interface{}) (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
 	defer cancel()
@@ -326,3 +344,10 @@ func (rsc *RedisCli) RdbWatch(fn func(tx *TX) error, keys ...string) error {
 	err := rsc.Watch(ctx, fp, keys...)
 	return err
 }
+
+func (rsc *RedisCli) RdbEvalScript(script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	result, err := script.Run(ctx, rsc, keys, args...).Result()
+	return result, err
+}
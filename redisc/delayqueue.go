@@ -0,0 +1,351 @@
+package redisc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrTaskExists   = errors.New("the task id already exists")
+	ErrTaskNotFound = errors.New("the task does not exist")
+)
+
+// 队列的所有 key 使用同一个 hash tag，兼容集群模式：
+// delayed 为未到期任务的 zset（score 为到期时间），ready 为已到期待处理的 list，
+// inflight 为处理中任务的 zset（score 为可见性超时时间），data/due/attempts 分别保存内容、到期时间和投递次数
+const delayQueueNow = `
+if redis.replicate_commands then redis.replicate_commands() end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local delayed, ready, inflight = KEYS[1], KEYS[2], KEYS[3]
+local data, due, attempts = KEYS[4], KEYS[5], KEYS[6]
+`
+
+var (
+	delayEnqueueScript = NewScript(delayQueueNow + `
+if redis.call('HSETNX', data, ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+local at = now + tonumber(ARGV[3])
+redis.call('HSET', due, ARGV[1], at)
+redis.call('ZADD', delayed, at, ARGV[1])
+return 1
+`)
+	// 先把到期任务和可见性超时的任务移到 ready，再取出一个任务
+	delayPopScript = NewScript(delayQueueNow + `
+local ids = redis.call('ZRANGEBYSCORE', delayed, '-inf', now, 'LIMIT', 0, 100)
+for _, id in ipairs(ids) do
+	redis.call('ZREM', delayed, id)
+	redis.call('RPUSH', ready, id)
+end
+ids = redis.call('ZRANGEBYSCORE', inflight, '-inf', now, 'LIMIT', 0, 100)
+for _, id in ipairs(ids) do
+	redis.call('ZREM', inflight, id)
+	redis.call('RPUSH', ready, id)
+end
+if ARGV[1] == '0' then
+	return false
+end
+while true do
+	local id = redis.call('LPOP', ready)
+	if not id then
+		return false
+	end
+	local payload = redis.call('HGET', data, id)
+	if payload then
+		redis.call('ZADD', inflight, now + tonumber(ARGV[1]), id)
+		local n = redis.call('HINCRBY', attempts, id, 1)
+		return {id, payload, redis.call('HGET', due, id), n}
+	end
+end
+`)
+	// ARGV[2] 为 Pop 返回的投递次数，任务超时后被重新投递时投递次数会变化，旧的回执失效
+	delayAckScript = NewScript(delayQueueNow + `
+if tonumber(redis.call('HGET', attempts, ARGV[1])) ~= tonumber(ARGV[2]) then
+	return 0
+end
+if redis.call('ZREM', inflight, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', data, ARGV[1])
+redis.call('HDEL', due, ARGV[1])
+redis.call('HDEL', attempts, ARGV[1])
+return 1
+`)
+	// ARGV[2] 不为 0 时为 Pop 返回的投递次数，只处理这次投递的处理中的任务（Retry），否则处理任意状态的任务（Reschedule）
+	delayRescheduleScript = NewScript(delayQueueNow + `
+if redis.call('HEXISTS', data, ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[2] ~= '0' and tonumber(redis.call('HGET', attempts, ARGV[1])) ~= tonumber(ARGV[2]) then
+	return 0
+end
+local removed = redis.call('ZREM', inflight, ARGV[1])
+if ARGV[2] ~= '0' and removed == 0 then
+	return 0
+end
+redis.call('ZREM', delayed, ARGV[1])
+redis.call('LREM', ready, 0, ARGV[1])
+local at = now + tonumber(ARGV[3])
+redis.call('HSET', due, ARGV[1], at)
+redis.call('ZADD', delayed, at, ARGV[1])
+return 1
+`)
+	delayCancelScript = NewScript(delayQueueNow + `
+if redis.call('HDEL', data, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', delayed, ARGV[1])
+redis.call('ZREM', inflight, ARGV[1])
+redis.call('LREM', ready, 0, ARGV[1])
+redis.call('HDEL', due, ARGV[1])
+redis.call('HDEL', attempts, ARGV[1])
+return 1
+`)
+	delayStatsScript = NewScript(delayQueueNow + `
+local lag = 0
+local head = redis.call('LINDEX', ready, 0)
+if head then
+	local at = tonumber(redis.call('HGET', due, head))
+	if at and now > at then
+		lag = now - at
+	end
+end
+local first = redis.call('ZRANGE', delayed, 0, 0, 'WITHSCORES')
+if first[2] and now - tonumber(first[2]) > lag then
+	lag = now - tonumber(first[2])
+end
+return {redis.call('ZCARD', delayed), redis.call('LLEN', ready), redis.call('ZCARD', inflight), lag}
+`)
+)
+
+type DelayedTask struct {
+	Id      string    `json:"id"`
+	Payload string    `json:"payload"`
+	DueAt   time.Time `json:"due_at"`
+	// Attempts 投递次数，同时是这次投递的回执，Ack 和 Retry 时传入
+	Attempts int64 `json:"attempts"`
+}
+
+type DelayQueueStats struct {
+	// Delayed 未到期的任务数
+	Delayed int64 `json:"delayed"`
+	// Ready 已到期等待处理的任务数（包括到期但还没有被移到 ready 的）
+	Ready int64 `json:"ready"`
+	// Inflight 正在处理的任务数
+	Inflight int64 `json:"inflight"`
+	// Lag 最早到期但还没有被处理的任务已经等待的时间
+	Lag time.Duration `json:"lag"`
+}
+
+// DelayQueue 基于 zset 的延迟队列，任务到期后被取出，处理超时（可见性超时）未 ack 的任务会被重新投递
+type DelayQueue struct {
+	cli  ScriptRunner
+	keys []string
+	// Visibility 可见性超时，默认 1 分钟
+	Visibility time.Duration
+	// OnError Run 中取出任务、处理、重试和 ack 出错时调用，默认忽略
+	OnError func(err error)
+}
+
+func NewDelayQueue(cli ScriptRunner, name string) *DelayQueue {
+	//use example:
+	//q := redisc.NewDelayQueue(rdb, "reminder")
+	//id, err := q.Enqueue(`{"user_id":1}`, 30*time.Minute)
+	//go q.Run(ctx, 4, time.Second, func(ctx context.Context, task *redisc.DelayedTask) error { return send(task.Payload) })
+	prefix := "delay_queue:{" + name + "}:"
+	return &DelayQueue{
+		cli:        cli,
+		keys:       []string{prefix + "delayed", prefix + "ready", prefix + "inflight", prefix + "data", prefix + "due", prefix + "attempts"},
+		Visibility: time.Minute,
+	}
+}
+
+// Enqueue 添加任务，delay 后到期，返回随机生成的任务 id
+func (q *DelayQueue) Enqueue(payload string, delay time.Duration) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(nonce)
+	return id, q.EnqueueWithId(id, payload, delay)
+}
+
+// EnqueueAt 指定到期时间，按本地时间换算为延迟后以 redis 时间为准
+func (q *DelayQueue) EnqueueAt(id, payload string, at time.Time) error {
+	return q.EnqueueWithId(id, payload, time.Until(at))
+}
+
+// EnqueueWithId 使用业务 id 添加任务，id 已存在时返回 ErrTaskExists，可以用于去重
+func (q *DelayQueue) EnqueueWithId(id, payload string, delay time.Duration) error {
+	n, err := q.eval(delayEnqueueScript, id, payload, delayMillis(delay))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTaskExists
+	}
+	return nil
+}
+
+// Pop 取出一个到期的任务，没有任务时返回 nil，处理完成后需要使用 task.Attempts 作为回执调用 Ack
+func (q *DelayQueue) Pop() (*DelayedTask, error) {
+	visibility := q.Visibility
+	if visibility <= 0 {
+		visibility = time.Minute
+	}
+	reply, err := q.cli.RdbEvalScript(delayPopScript, q.keys, visibility.Milliseconds())
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected delay queue reply: %v", reply)
+	}
+	task := &DelayedTask{}
+	task.Id, _ = values[0].(string)
+	task.Payload, _ = values[1].(string)
+	if due, ok := values[2].(string); ok {
+		var ms int64
+		_, _ = fmt.Sscan(due, &ms)
+		task.DueAt = time.UnixMilli(ms)
+	}
+	task.Attempts, _ = values[3].(int64)
+	return task, nil
+}
+
+// Ack 确认任务完成并删除，receipt 为 Pop 返回的 task.Attempts，
+// 任务已经超时被重新投递（回执失效）时返回 ErrTaskNotFound
+func (q *DelayQueue) Ack(id string, receipt int64) error {
+	return q.result(q.eval(delayAckScript, id, receipt))
+}
+
+// Retry 处理失败时 delay 后重新投递，receipt 和回执失效时的返回值同 Ack
+func (q *DelayQueue) Retry(id string, receipt int64, delay time.Duration) error {
+	if receipt <= 0 {
+		return ErrTaskNotFound
+	}
+	return q.result(q.eval(delayRescheduleScript, id, receipt, delayMillis(delay)))
+}
+
+// Reschedule 修改任务的到期时间，任意状态的任务都可以修改
+func (q *DelayQueue) Reschedule(id string, delay time.Duration) error {
+	return q.result(q.eval(delayRescheduleScript, id, 0, delayMillis(delay)))
+}
+
+func (q *DelayQueue) Cancel(id string) error {
+	return q.result(q.eval(delayCancelScript, id))
+}
+
+func (q *DelayQueue) Stats() (*DelayQueueStats, error) {
+	// 先移动到期任务，使 Ready 包括已到期的任务
+	if _, err := q.cli.RdbEvalScript(delayPopScript, q.keys, 0); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	reply, err := q.cli.RdbEvalScript(delayStatsScript, q.keys)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected delay queue reply: %v", reply)
+	}
+	nums := make([]int64, 4)
+	for i, v := range values {
+		nums[i], _ = v.(int64)
+	}
+	return &DelayQueueStats{Delayed: nums[0], Ready: nums[1], Inflight: nums[2], Lag: time.Duration(nums[3]) * time.Millisecond}, nil
+}
+
+// Run 启动 concurrency 个协程轮询处理任务，直到 ctx 结束，handler 返回错误时按投递次数退避重试
+func (q *DelayQueue) Run(ctx context.Context, concurrency int, interval time.Duration, handler func(ctx context.Context, task *DelayedTask) error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				task, err := q.Pop()
+				if err != nil {
+					q.reportError(fmt.Errorf("pop delayed task failed: %w", err))
+				}
+				if err != nil || task == nil {
+					select {
+					case <-ctx.Done():
+					case <-time.After(interval):
+					}
+					continue
+				}
+				if err = handleDelayedTask(ctx, task, handler); err != nil {
+					q.reportError(fmt.Errorf("handle delayed task %s failed: %w", task.Id, err))
+					backoff := time.Duration(task.Attempts*task.Attempts) * time.Second
+					if err = q.Retry(task.Id, task.Attempts, backoff); err != nil {
+						q.reportError(fmt.Errorf("retry delayed task %s failed: %w", task.Id, err))
+					}
+					continue
+				}
+				if err = q.Ack(task.Id, task.Attempts); err != nil {
+					q.reportError(fmt.Errorf("ack delayed task %s failed: %w", task.Id, err))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func handleDelayedTask(ctx context.Context, task *DelayedTask, handler func(ctx context.Context, task *DelayedTask) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, task)
+}
+
+func (q *DelayQueue) reportError(err error) {
+	if q.OnError != nil {
+		q.OnError(err)
+	}
+}
+
+func (q *DelayQueue) eval(script *Script, args ...interface{}) (int64, error) {
+	reply, err := q.cli.RdbEvalScript(script, q.keys, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return n, nil
+}
+
+func (q *DelayQueue) result(n int64, err error) error {
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+func delayMillis(delay time.Duration) int64 {
+	if delay < 0 {
+		return 0
+	}
+	return delay.Milliseconds()
+}
@@ -0,0 +1,392 @@
+package redisc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLockNotHeld = errors.New("the lock is not held by this owner")
+	ErrLockFailed  = errors.New("failed to acquire the lock")
+)
+
+// 锁是一个 hash：owner 字段为重入次数，__token 字段为本次加锁的 fencing token；
+// token 计数器是单独的 key，和锁使用同一个 hash tag，保证在集群的同一个槽且删除锁后仍然递增
+var (
+	lockAcquireScript = NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	local token = redis.call('INCR', KEYS[2])
+	redis.call('HSET', KEYS[1], ARGV[1], 1)
+	redis.call('HSET', KEYS[1], '__token', token)
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return token
+end
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return tonumber(redis.call('HGET', KEYS[1], '__token'))
+end
+return 0
+`)
+	// ARGV[2] 大于 0 时最后一次解锁不删除 key，而是保留 ARGV[2] 毫秒
+	lockReleaseScript = NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+local n = redis.call('HINCRBY', KEYS[1], ARGV[1], -1)
+if n > 0 then
+	return n
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+else
+	redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+	lockRenewScript = NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+)
+
+// ScriptRunner RedisCli、RedisClusterCli、UniversalClient 都实现了该接口
+type ScriptRunner interface {
+	RdbEvalScript(script *Script, keys []string, args ...interface{}) (interface{}, error)
+}
+
+type MutexOption func(m *Mutex)
+
+// WithLockTTL 锁的过期时间，默认 30 秒
+func WithLockTTL(ttl time.Duration) MutexOption {
+	return func(m *Mutex) {
+		m.ttl = ttl
+	}
+}
+
+// WithLockOwner 指定持有者，相同持有者可以重入，默认随机生成
+func WithLockOwner(owner string) MutexOption {
+	return func(m *Mutex) {
+		m.owner = owner
+	}
+}
+
+// WithWatchdog 持有锁期间每 ttl/3 自动续期，任务执行时间不确定时使用
+func WithWatchdog() MutexOption {
+	return func(m *Mutex) {
+		m.watchdog = true
+	}
+}
+
+// WithRetryInterval Lock 等待锁时的重试间隔，默认 100 毫秒
+func WithRetryInterval(d time.Duration) MutexOption {
+	return func(m *Mutex) {
+		m.retry = d
+	}
+}
+
+// Mutex 基于 redis 的分布式锁，同一个 Mutex 不应在多个 goroutine 中并发加锁
+type Mutex struct {
+	clients  []ScriptRunner
+	key      string
+	fenceKey string
+	owner    string
+	ttl      time.Duration
+	watchdog bool
+	retry    time.Duration
+
+	lock     sync.Mutex
+	count    int
+	token    int64
+	expireAt time.Time
+	lost     bool
+	stop     chan struct{}
+}
+
+// NewMutex 单节点（或集群）模式
+func NewMutex(cli ScriptRunner, name string, opts ...MutexOption) *Mutex {
+	//use example:
+	//m := redisc.NewMutex(rdb, "order:1001", redisc.WithWatchdog())
+	//if err := m.Lock(ctx); err != nil {
+	//	return err
+	//}
+	//defer m.Unlock()
+	//token := m.Token() // 写入存储时携带 token，存储拒绝比已见过的 token 更小的写入
+	return newMutex([]ScriptRunner{cli}, name, opts...)
+}
+
+// NewRedlock Redlock 模式，clis 为相互独立的 redis 实例，在多数实例上加锁成功才算成功；
+// 此时 Token 为各实例返回值的最大值，只能保证大致递增
+func NewRedlock(clis []*RedisCli, name string, opts ...MutexOption) *Mutex {
+	clients := make([]ScriptRunner, len(clis))
+	for i, cli := range clis {
+		clients[i] = cli
+	}
+	return newMutex(clients, name, opts...)
+}
+
+func newMutex(clients []ScriptRunner, name string, opts ...MutexOption) *Mutex {
+	m := &Mutex{
+		clients:  clients,
+		key:      "lock:{" + name + "}",
+		fenceKey: "lock:{" + name + "}:fence",
+		ttl:      30 * time.Second,
+		retry:    100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.owner == "" {
+		nonce := make([]byte, 16)
+		_, _ = rand.Read(nonce)
+		m.owner = hex.EncodeToString(nonce)
+	}
+	if m.ttl < 10*time.Millisecond {
+		m.ttl = 10 * time.Millisecond
+	}
+	if m.retry <= 0 {
+		m.retry = 100 * time.Millisecond
+	}
+	return m
+}
+
+func (m *Mutex) Owner() string {
+	return m.owner
+}
+
+// Token 当前持有锁的 fencing token，未持有时为 0
+func (m *Mutex) Token() int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.token
+}
+
+// Valid 是否仍然持有锁，续期失败或者超过过期时间后返回 false
+func (m *Mutex) Valid() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.count > 0 && !m.lost && time.Now().Before(m.expireAt)
+}
+
+// Lock 阻塞直到加锁成功或 ctx 结束
+func (m *Mutex) Lock(ctx context.Context) error {
+	for {
+		ok, err := m.TryLock()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.retry):
+		}
+	}
+}
+
+func (m *Mutex) TryLock() (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	start := time.Now()
+	tokens, errs := m.eval(lockAcquireScript, []string{m.key, m.fenceKey}, m.owner, m.ttl.Milliseconds())
+	var token int64
+	acquired := 0
+	for _, t := range tokens {
+		if t > 0 {
+			acquired++
+			if t > token {
+				token = t
+			}
+		}
+	}
+	// 时钟漂移按 ttl 的 1% 加 2 毫秒计算
+	validity := m.ttl - time.Since(start) - m.ttl/100 - 2*time.Millisecond
+	if acquired < m.quorum() || validity <= 0 {
+		// 回滚部分节点上的加锁，重入时只回滚本次增加的次数
+		if acquired > 0 {
+			m.eval(lockReleaseScript, []string{m.key}, m.owner, 0)
+		}
+		if acquired == 0 && len(errs) > 0 {
+			return false, errs[0]
+		}
+		return false, nil
+	}
+	m.count++
+	m.token = token
+	m.expireAt = start.Add(validity)
+	m.lost = false
+	if m.count == 1 && m.watchdog {
+		m.stop = make(chan struct{})
+		go m.keepalive(m.stop)
+	}
+	return true, nil
+}
+
+func (m *Mutex) Unlock() error {
+	return m.release(0)
+}
+
+// release hold 大于 0 时最后一次解锁后锁继续保留 hold 时间
+func (m *Mutex) release(hold time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.count == 0 {
+		return ErrLockNotHeld
+	}
+	m.count--
+	if m.count == 0 {
+		if m.stop != nil {
+			close(m.stop)
+			m.stop = nil
+		}
+		m.token = 0
+	}
+	results, errs := m.eval(lockReleaseScript, []string{m.key}, m.owner, hold.Milliseconds())
+	released := 0
+	for _, n := range results {
+		if n >= 0 {
+			released++
+		}
+	}
+	if released >= m.quorum() {
+		return nil
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return ErrLockNotHeld
+}
+
+func (m *Mutex) keepalive(stop chan struct{}) {
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			start := time.Now()
+			results, errs := m.eval(lockRenewScript, []string{m.key}, m.owner, m.ttl.Milliseconds())
+			renewed := 0
+			for _, n := range results {
+				if n == 1 {
+					renewed++
+				}
+			}
+			m.lock.Lock()
+			if m.stop != stop {
+				m.lock.Unlock()
+				return
+			}
+			if renewed >= m.quorum() {
+				m.expireAt = start.Add(m.ttl - m.ttl/100 - 2*time.Millisecond)
+			} else if len(errs) == 0 {
+				// 锁已经过期或被删除，不再续期
+				m.lost = true
+				m.lock.Unlock()
+				return
+			}
+			m.lock.Unlock()
+		}
+	}
+}
+
+func (m *Mutex) quorum() int {
+	return len(m.clients)/2 + 1
+}
+
+// eval 在所有节点上执行脚本，返回成功节点的结果，失败的节点结果为 -1
+func (m *Mutex) eval(script *Script, keys []string, args ...interface{}) ([]int64, []error) {
+	results := make([]int64, len(m.clients))
+	errs := make([]error, len(m.clients))
+	var wg sync.WaitGroup
+	for i, cli := range m.clients {
+		wg.Add(1)
+		go func(i int, cli ScriptRunner) {
+			defer wg.Done()
+			reply, err := cli.RdbEvalScript(script, keys, args...)
+			if err != nil {
+				results[i], errs[i] = -1, err
+				return
+			}
+			results[i], _ = reply.(int64)
+		}(i, cli)
+	}
+	wg.Wait()
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return results, failed
+}
+
+// CronLocker 实现 cron.Locker，加锁不等待，拿不到锁的实例跳过本次执行
+type CronLocker struct {
+	cli     ScriptRunner
+	prefix  string
+	ttl     time.Duration
+	minHold time.Duration
+
+	lock  sync.Mutex
+	held  map[string]*Mutex
+	since map[string]time.Time
+}
+
+// NewCronLocker minHold 为锁的最短持有时间，任务很快结束时锁会保留到 minHold，
+// 避免各实例的时钟有偏差时同一次调度被其他实例再执行一次，一般设置为调度周期的一半
+func NewCronLocker(cli ScriptRunner, prefix string, ttl, minHold time.Duration) *CronLocker {
+	//use example:
+	//cron.SetLocker(redisc.NewCronLocker(rdb, "cron:", time.Minute, 30*time.Second))
+	//cron.Every(1).Minute().Lock().Do(task)
+	if prefix == "" {
+		prefix = "cron:"
+	}
+	return &CronLocker{
+		cli:     cli,
+		prefix:  prefix,
+		ttl:     ttl,
+		minHold: minHold,
+		held:    make(map[string]*Mutex),
+		since:   make(map[string]time.Time),
+	}
+}
+
+func (l *CronLocker) Lock(key string) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.held[key]; ok {
+		// 上一次执行还没有结束
+		return false, nil
+	}
+	m := NewMutex(l.cli, l.prefix+key, WithLockTTL(l.ttl), WithWatchdog())
+	ok, err := m.TryLock()
+	if err != nil || !ok {
+		return false, err
+	}
+	l.held[key] = m
+	l.since[key] = time.Now()
+	return true, nil
+}
+
+func (l *CronLocker) Unlock(key string) error {
+	l.lock.Lock()
+	m, ok := l.held[key]
+	since := l.since[key]
+	delete(l.held, key)
+	delete(l.since, key)
+	l.lock.Unlock()
+	if !ok {
+		return ErrLockNotHeld
+	}
+	return m.release(l.minHold - time.Since(since))
+}
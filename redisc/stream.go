@@ -0,0 +1,330 @@
+package redisc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/pool"
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamClient *RedisCli、*RedisClusterCli、*UniversalClient 都实现了该接口
+type StreamClient interface {
+	redis.Cmdable
+}
+
+type StreamMessage struct {
+	Id     string                 `json:"id"`
+	Stream string                 `json:"stream"`
+	Values map[string]interface{} `json:"values"`
+	// Retry 投递次数，第一次投递为 1
+	Retry int64 `json:"retry"`
+}
+
+type StreamProducer struct {
+	cli    StreamClient
+	Stream string
+	MaxLen int64
+	// Approx 为 true 时使用 MAXLEN ~，裁剪更高效但保留的数量可能略多于 MaxLen
+	Approx bool
+}
+
+// NewStreamProducer maxLen 为 0 时不裁剪
+func NewStreamProducer(cli StreamClient, stream string, maxLen int64) *StreamProducer {
+	//use example:
+	//producer := redisc.NewStreamProducer(rdb, "stream:order", 100000)
+	//id, err := producer.Publish(map[string]interface{}{"order_id": 1001})
+	return &StreamProducer{cli: cli, Stream: stream, MaxLen: maxLen, Approx: true}
+}
+
+func (p *StreamProducer) Publish(values map[string]interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	return p.cli.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.Stream,
+		MaxLen: p.MaxLen,
+		Approx: p.Approx,
+		Values: values,
+	}).Result()
+}
+
+type StreamConsumerConfig struct {
+	Stream   string
+	Group    string
+	Consumer string
+	// Batch 每次读取的最大数量，默认 10
+	Batch int64
+	// Block 阻塞读取的最长时间，默认 5 秒
+	Block time.Duration
+	// MinIdle 超过该时间没有 ack 的消息会被重新认领，默认 1 分钟，应大于处理一条消息的最长时间
+	MinIdle time.Duration
+	// ClaimInterval 检查待认领消息的间隔，默认 MinIdle/2
+	ClaimInterval time.Duration
+	// MaxRetries 超过该投递次数的消息转入死信队列，默认 5
+	MaxRetries int64
+	// DeadLetter 死信队列，默认为 Stream + ":dead"
+	DeadLetter string
+	// DeadLetterMaxLen 死信队列的长度上限，0 表示不裁剪
+	DeadLetterMaxLen int64
+}
+
+type StreamHandler func(ctx context.Context, msg *StreamMessage) error
+
+// StreamConsumer 消费组消费者，处理成功后 ack，失败的消息等待 MinIdle 后被重新认领投递
+type StreamConsumer struct {
+	cli     StreamClient
+	cfg     StreamConsumerConfig
+	handler StreamHandler
+	pool    *pool.Pool
+	wg      sync.WaitGroup
+	// OnError 读取、认领、ack 等出错时调用，默认忽略
+	OnError func(err error)
+}
+
+// NewStreamConsumer p 为空时创建一个大小为 Batch 的协程池
+func NewStreamConsumer(cli StreamClient, cfg StreamConsumerConfig, handler StreamHandler, p *pool.Pool) (*StreamConsumer, error) {
+	//use example:
+	//consumer, err := redisc.NewStreamConsumer(rdb, redisc.StreamConsumerConfig{Stream: "stream:order", Group: "billing", Consumer: hostname}, handle, nil)
+	//go consumer.Run(ctx)
+	if cfg.Stream == "" || cfg.Group == "" || cfg.Consumer == "" {
+		return nil, errors.New("the stream, group and consumer must not be empty")
+	}
+	if cfg.Batch <= 0 {
+		cfg.Batch = 10
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = 5 * time.Second
+	}
+	if cfg.MinIdle <= 0 {
+		cfg.MinIdle = time.Minute
+	}
+	if cfg.ClaimInterval <= 0 {
+		cfg.ClaimInterval = cfg.MinIdle / 2
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.DeadLetter == "" {
+		cfg.DeadLetter = cfg.Stream + ":dead"
+	}
+	if p == nil {
+		var err error
+		if p, err = pool.NewPool(int(cfg.Batch)); err != nil {
+			return nil, err
+		}
+	}
+	return &StreamConsumer{cli: cli, cfg: cfg, handler: handler, pool: p}, nil
+}
+
+// EnsureGroup 创建消费组，消费组已存在时忽略，新建的消费组从最新的消息开始消费
+func (c *StreamConsumer) EnsureGroup() error {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	err := c.cli.XGroupCreateMkStream(ctx, c.cfg.Stream, c.cfg.Group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// Run 阻塞消费直到 ctx 结束，返回前等待正在处理的消息完成
+func (c *StreamConsumer) Run(ctx context.Context) error {
+	if err := c.EnsureGroup(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.claimLoop(ctx)
+	}()
+	for ctx.Err() == nil {
+		streams, err := c.cli.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			Streams:  []string{c.cfg.Stream, ">"},
+			Count:    c.cfg.Batch,
+			Block:    c.cfg.Block,
+		}).Result()
+		if err != nil {
+			if err != redis.Nil && ctx.Err() == nil {
+				c.reportError(fmt.Errorf("read stream %s failed: %w", c.cfg.Stream, err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+				}
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				c.dispatch(ctx, &StreamMessage{Id: msg.ID, Stream: stream.Stream, Values: msg.Values, Retry: 1})
+			}
+		}
+	}
+	<-done
+	c.wg.Wait()
+	return nil
+}
+
+// Close 释放协程池，需要在 Run 返回后调用
+func (c *StreamConsumer) Close(timeout time.Duration) error {
+	return c.pool.ReleaseTimeout(timeout)
+}
+
+func (c *StreamConsumer) dispatch(ctx context.Context, msg *StreamMessage) {
+	c.wg.Add(1)
+	err := c.pool.Submit(func() {
+		defer c.wg.Done()
+		if err := c.handle(ctx, msg); err != nil {
+			// 不 ack，等待超过 MinIdle 后被重新认领
+			c.reportError(fmt.Errorf("handle message %s failed: %w", msg.Id, err))
+			return
+		}
+		if err := c.ack(msg.Id); err != nil {
+			c.reportError(err)
+		}
+	})
+	if err != nil {
+		c.wg.Done()
+		c.reportError(fmt.Errorf("submit message %s failed: %w", msg.Id, err))
+	}
+}
+
+func (c *StreamConsumer) handle(ctx context.Context, msg *StreamMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return c.handler(ctx, msg)
+}
+
+func (c *StreamConsumer) claimLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.ClaimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.claim(ctx); err != nil && ctx.Err() == nil {
+				c.reportError(fmt.Errorf("claim stream %s failed: %w", c.cfg.Stream, err))
+			}
+		}
+	}
+}
+
+// claim 使用 XAUTOCLAIM 认领超时的消息，投递次数超过 MaxRetries 的转入死信队列
+func (c *StreamConsumer) claim(ctx context.Context) error {
+	start := "0-0"
+	for ctx.Err() == nil {
+		messages, next, err := c.cli.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.cfg.Stream,
+			Group:    c.cfg.Group,
+			Consumer: c.cfg.Consumer,
+			MinIdle:  c.cfg.MinIdle,
+			Start:    start,
+			Count:    c.cfg.Batch,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) > 0 {
+			retries, err := c.retries(ctx, messages[0].ID, messages[len(messages)-1].ID, int64(len(messages)))
+			if err != nil {
+				return err
+			}
+			for _, msg := range messages {
+				retry, ok := retries[msg.ID]
+				if !ok {
+					// 范围内还有本消费者正在处理的其他消息时，可能没有读到，单独查询
+					single, err := c.retries(ctx, msg.ID, msg.ID, 1)
+					if err != nil {
+						return err
+					}
+					retry = single[msg.ID]
+				}
+				if retry > c.cfg.MaxRetries {
+					if err = c.deadLetter(msg, retry); err != nil {
+						return err
+					}
+					continue
+				}
+				c.dispatch(ctx, &StreamMessage{Id: msg.ID, Stream: c.cfg.Stream, Values: msg.Values, Retry: retry})
+			}
+		}
+		if next == "" || next == "0-0" {
+			return nil
+		}
+		start = next
+	}
+	return nil
+}
+
+// retries 从 XPENDING 中读取投递次数，XAUTOCLAIM 认领时已经加 1
+func (c *StreamConsumer) retries(ctx context.Context, start, end string, count int64) (map[string]int64, error) {
+	pending, err := c.cli.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   c.cfg.Stream,
+		Group:    c.cfg.Group,
+		Start:    start,
+		End:      end,
+		Count:    count,
+		Consumer: c.cfg.Consumer,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	retries := make(map[string]int64, len(pending))
+	for _, p := range pending {
+		retries[p.ID] = p.RetryCount
+	}
+	return retries, nil
+}
+
+// deadLetter 先写入死信队列再 ack，写入失败时消息保留在待处理列表中
+func (c *StreamConsumer) deadLetter(msg redis.XMessage, retry int64) error {
+	values := make(map[string]interface{}, len(msg.Values)+3)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["_origin_stream"] = c.cfg.Stream
+	values["_origin_id"] = msg.ID
+	values["_retry"] = retry
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	err := c.cli.XAdd(ctx, &redis.XAddArgs{
+		Stream: c.cfg.DeadLetter,
+		MaxLen: c.cfg.DeadLetterMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return err
+	}
+	return c.ack(msg.ID)
+}
+
+func (c *StreamConsumer) ack(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	if err := c.cli.XAck(ctx, c.cfg.Stream, c.cfg.Group, id).Err(); err != nil {
+		return fmt.Errorf("ack message %s failed: %w", id, err)
+	}
+	return nil
+}
+
+func (c *StreamConsumer) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+var (
+	_ StreamClient = (*RedisCli)(nil)
+	_ StreamClient = (*RedisClusterCli)(nil)
+	_ StreamClient = (*UniversalClient)(nil)
+)
@@ -123,6 +123,15 @@ func (rsc *UniversalClient) RdbSetNx(key string, value interface{}, expireTimeSe
 	return err
 }
 
+// RdbSetNxBool 返回 key 是否设置成功
+func (rsc *UniversalClient) RdbSetNxBool(key string, value interface{}, expireTimeSecond uint64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	expireTime := time.Second * time.Duration(expireTimeSecond)
+	result, err := rsc.SetNX(ctx, key, value, expireTime).Result()
+	return result, err
+}
+
 func (rsc *UniversalClient) RdbLPush(key string, values ...interface{}) (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
 	defer cancel()
@@ -242,3 +251,10 @@ func (rsc *UniversalClient) RdbWatch(fn func(tx *TX) error, keys ...string) erro
 	err := rsc.Watch(ctx, fp, keys...)
 	return err
 }
+
+func (rsc *UniversalClient) RdbEvalScript(script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), CtxExpireTime)
+	defer cancel()
+	result, err := script.Run(ctx, rsc, keys, args...).Result()
+	return result, err
+}
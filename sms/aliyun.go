@@ -8,29 +8,27 @@
 package sms
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
-	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
-	"math/rand"
+	mrand "math/rand"
+	"net/http"
+	"net/url"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 )
 
-var (
-	one       sync.Once
-	SmsClient = &smsClient{}
-)
-
-type smsConfig struct {
-	RegionId        string
-	AccessKeyId     string
-	AccessKeySecret string
-}
+var SmsClient = &smsClient{}
 
 type smsClient struct {
-	client *sdk.Client
-	config *smsConfig
+	sender *AliyunSender
 }
 
 type SMSContent struct {
@@ -40,55 +38,35 @@ type SMSContent struct {
 	TemplateParam string // JSON串
 }
 
-// SendSms https://api.aliyun.com/#/?product=Dysmsapi&api=SendSms&params={}&tab=DEMO&lang=GO
-func (sms *smsClient) SendSms(content *SMSContent) bool {
-	if sms.client == nil {
-		one.Do(func() {
-			sms.config = &smsConfig{
-				// https://help.aliyun.com/document_detail/40654.html?spm=a2c6h.13066369.0.0.54a120f8MncWFW
-				RegionId:        "cn-hangzhou",
-				AccessKeyId:     "",
-				AccessKeySecret: "",
-			}
-			client, err := sdk.NewClientWithAccessKey(sms.config.RegionId, sms.config.AccessKeyId, sms.config.AccessKeySecret)
-			if err != nil {
-				panic(err)
-			}
-			sms.client = client
-		})
-	}
-
-	request := requests.NewCommonRequest()
-	request.Method = "POST"
-	request.Scheme = "https" // https | http
-	request.Domain = "dysmsapi.aliyuncs.com"
-	request.Version = "2017-05-25"
-	request.ApiName = "SendSms"
-	request.QueryParams["RegionId"] = sms.config.RegionId
-
-	// 自定义
-	request.QueryParams["PhoneNumbers"] = content.PhoneNumbers
-	request.QueryParams["SignName"] = content.SignName
-	request.QueryParams["TemplateCode"] = content.TemplateCode
-	request.QueryParams["TemplateParam"] = content.TemplateParam
-
-	response, err := sms.client.ProcessCommonRequest(request)
+// Init 设置阿里云的地域和密钥，调用 SendSms 之前需要先初始化
+func (sms *smsClient) Init(config AliyunConfig) error {
+	sender, err := NewAliyunSender(config)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	fmt.Println(response.GetHttpContentString())
+	sms.sender = sender
+	return nil
+}
 
-	return response.IsSuccess()
+// SendSms https://api.aliyun.com/#/?product=Dysmsapi&api=SendSms&params={}&tab=DEMO&lang=GO
+//
+// Deprecated: 使用 NewAliyunSender 和 NewClient，可以拿到失败的原因，并支持模板校验、限流和切换服务商
+func (sms *smsClient) SendSms(content *SMSContent) bool {
+	if sms.sender == nil {
+		return false
+	}
+	_, err := sms.sender.send(context.Background(), content.PhoneNumbers, content.SignName, content.TemplateCode, content.TemplateParam)
+	return err == nil
 }
 
 // GenValidateCode 生成随机数验证码
 func GenValidateCode(len int) string {
 	numbers := [10]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
-	rand.Seed(time.Now().UnixNano())
+	mrand.Seed(time.Now().UnixNano())
 
 	var sb strings.Builder
 	for i := 0; i < len; i++ {
-		fmt.Fprintf(&sb, "%d", numbers[rand.Intn(10)])
+		fmt.Fprintf(&sb, "%d", numbers[mrand.Intn(10)])
 	}
 	return sb.String()
 }
@@ -102,3 +80,137 @@ func StartSend() {
 	}
 	SmsClient.SendSms(content)
 }
+
+// AliyunConfig 阿里云短信配置，https://help.aliyun.com/document_detail/419273.html
+type AliyunConfig struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	// RegionId 默认 cn-hangzhou
+	RegionId string
+	// SignName 短信签名
+	SignName string
+	// BaseUrl 默认 https://dysmsapi.aliyuncs.com，测试时可以指向 httptest.Server
+	BaseUrl    string
+	HTTPClient *http.Client
+}
+
+// AliyunSender 阿里云短信，只支持模板短信，模板编号取 Template.Codes["aliyun"]
+type AliyunSender struct {
+	config AliyunConfig
+}
+
+func NewAliyunSender(config AliyunConfig) (*AliyunSender, error) {
+	//use example:
+	//sender, err := sms.NewAliyunSender(sms.AliyunConfig{AccessKeyId: id, AccessKeySecret: secret, SignName: "ACME"})
+	if config.AccessKeyId == "" || config.AccessKeySecret == "" {
+		return nil, errors.New("the aliyun access key id and secret are required")
+	}
+	if config.RegionId == "" {
+		config.RegionId = "cn-hangzhou"
+	}
+	if config.BaseUrl == "" {
+		config.BaseUrl = "https://dysmsapi.aliyuncs.com"
+	}
+	config.BaseUrl = strings.TrimSuffix(config.BaseUrl, "/")
+	return &AliyunSender{config: config}, nil
+}
+
+func (s *AliyunSender) Name() string {
+	return "aliyun"
+}
+
+func (s *AliyunSender) Send(ctx context.Context, msg *Message) (*Result, error) {
+	if msg.Template == nil || msg.Template.Codes[s.Name()] == "" {
+		return nil, ErrUnsupported
+	}
+	params := msg.Params
+	if params == nil {
+		params = map[string]string{}
+	}
+	templateParam, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(ctx, aliyunPhone(msg.To), s.config.SignName, msg.Template.Codes[s.Name()], string(templateParam))
+}
+
+// send 调用 SendSms 接口，签名算法见 https://help.aliyun.com/document_detail/315526.html
+func (s *AliyunSender) send(ctx context.Context, phones, signName, templateCode, templateParam string) (*Result, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Set("AccessKeyId", s.config.AccessKeyId)
+	values.Set("Action", "SendSms")
+	values.Set("Format", "JSON")
+	values.Set("RegionId", s.config.RegionId)
+	values.Set("SignatureMethod", "HMAC-SHA1")
+	values.Set("SignatureNonce", hex.EncodeToString(nonce))
+	values.Set("SignatureVersion", "1.0")
+	values.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	values.Set("Version", "2017-05-25")
+	values.Set("PhoneNumbers", phones)
+	values.Set("SignName", signName)
+	values.Set("TemplateCode", templateCode)
+	values.Set("TemplateParam", templateParam)
+	values.Set("Signature", aliyunSignature(http.MethodPost, values, s.config.AccessKeySecret))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.BaseUrl+"/", strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	status, body, err := doRequest(s.config.HTTPClient, s.Name(), req)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Code      string `json:"Code"`
+		Message   string `json:"Message"`
+		BizId     string `json:"BizId"`
+		RequestId string `json:"RequestId"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil || status != http.StatusOK || resp.Code != "OK" {
+		if resp.Message == "" {
+			resp.Message = strings.TrimSpace(string(body))
+		}
+		return nil, &ProviderError{
+			Provider:  s.Name(),
+			Status:    status,
+			Code:      resp.Code,
+			Message:   resp.Message,
+			Temporary: temporaryStatus(status) || resp.Code == "Throttling" || resp.Code == "ServiceUnavailable",
+		}
+	}
+	return &Result{Provider: s.Name(), MessageId: resp.BizId}, nil
+}
+
+func aliyunSignature(method string, values url.Values, secret string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = aliyunEncode(k) + "=" + aliyunEncode(values.Get(k))
+	}
+	stringToSign := method + "&" + aliyunEncode("/") + "&" + aliyunEncode(strings.Join(pairs, "&"))
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func aliyunEncode(s string) string {
+	return strings.NewReplacer("+", "%20", "*", "%2A", "%7E", "~").Replace(url.QueryEscape(s))
+}
+
+// aliyunPhone 国内号码去掉 +86，国际号码为国际区号加号码
+func aliyunPhone(phone string) string {
+	if strings.HasPrefix(phone, "+86") {
+		return phone[3:]
+	}
+	return strings.TrimPrefix(phone, "+")
+}
@@ -0,0 +1,171 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/web/limiter"
+)
+
+var (
+	ErrNoSender  = errors.New("no sms sender is configured")
+	ErrAllFailed = errors.New("all sms senders failed")
+	ErrThrottled = errors.New("too many sms to the phone number")
+)
+
+// ThrottleError 手机号被限流，RetryAfter 为距离下次可以发送的时间
+type ThrottleError struct {
+	Phone      string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%v, retry after %s", ErrThrottled, e.RetryAfter)
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return ErrThrottled
+}
+
+// Client 按顺序使用 Senders 发送短信，服务商失败时切换到下一个
+type Client struct {
+	Senders []Sender
+	// Throttles 按手机号限流，全部放行才会发送，限流服务出错时不发送，发送失败也会计数，
+	// 多实例部署时使用 limiter.NewRedisLimiter
+	Throttles []limiter.Limiter
+	// Retries 同一个服务商临时错误（网络错误、限流、5xx）的重试次数，默认 1
+	Retries int
+	// Backoff 第 n 次重试前等待 n*Backoff，默认 500 毫秒
+	Backoff time.Duration
+	// OnError 服务商发送失败时回调，可以用来记录日志或告警
+	OnError func(provider string, msg *Message, err error)
+
+	lock      sync.RWMutex
+	templates map[string]*Template
+}
+
+func NewClient(senders ...Sender) *Client {
+	//use example:
+	//aliyun, _ := sms.NewAliyunSender(sms.AliyunConfig{AccessKeyId: id, AccessKeySecret: secret, SignName: "ACME"})
+	//twilio, _ := sms.NewTwilioSender(sms.TwilioConfig{AccountSid: sid, AuthToken: token, From: "+15005550006"})
+	//client := sms.NewClient(aliyun, twilio)
+	//perMinute, _ := limiter.NewRedisLimiter(rdb, "sms:minute:", limiter.Rule{Algorithm: limiter.SlidingWindowLog, Limit: 1, Period: time.Minute})
+	//perDay, _ := limiter.NewRedisLimiter(rdb, "sms:day:", limiter.Rule{Algorithm: limiter.FixedWindow, Limit: 10, Period: 24 * time.Hour})
+	//client.Throttles = []limiter.Limiter{perMinute, perDay}
+	//err := client.AddTemplate(&sms.Template{Name: "login", Text: "您的验证码是${code}，5分钟内有效", Codes: map[string]string{"aliyun": "SMS_173230275"}, Params: map[string]string{"code": `\d{6}`}})
+	//res, err := client.Send(ctx, "+8613800000000", "login", map[string]string{"code": "123456"})
+	return &Client{
+		Senders:   senders,
+		Retries:   1,
+		Backoff:   500 * time.Millisecond,
+		templates: make(map[string]*Template),
+	}
+}
+
+// AddTemplate 注册模板，同名模板会被覆盖
+func (c *Client) AddTemplate(t *Template) error {
+	if err := t.compile(); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.templates[t.Name] = t
+	return nil
+}
+
+// Template 不存在时返回 ErrTemplateNotFound
+func (c *Client) Template(name string) (*Template, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	t, ok := c.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	return t, nil
+}
+
+// Send 使用命名模板发送，参数需要通过模板的校验
+func (c *Client) Send(ctx context.Context, to, template string, params map[string]string) (*Result, error) {
+	t, err := c.Template(template)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.Validate(params); err != nil {
+		return nil, err
+	}
+	return c.SendMessage(ctx, &Message{To: to, Template: t, Params: params})
+}
+
+// SendText 不使用模板直接发送内容，只有支持文本短信的服务商会发送
+func (c *Client) SendText(ctx context.Context, to, text string) (*Result, error) {
+	return c.SendMessage(ctx, &Message{To: to, Text: text})
+}
+
+// SendMessage 限流后依次尝试各个服务商，不会修改 msg。
+// 发送前就会扣除限流次数，所有服务商都失败时同样计数：超时的请求可能已经发出，
+// 先扣除也可以防止并发请求绕过限流
+func (c *Client) SendMessage(ctx context.Context, msg *Message) (*Result, error) {
+	if len(c.Senders) == 0 {
+		return nil, ErrNoSender
+	}
+	to, err := normalizePhone(msg.To)
+	if err != nil {
+		return nil, err
+	}
+	m := *msg
+	m.To = to
+	msg = &m
+	if err = c.throttle(to); err != nil {
+		return nil, err
+	}
+	var failures []string
+	for _, sender := range c.Senders {
+		res, err := c.sendWithRetry(ctx, sender, msg)
+		if err == nil {
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, ErrUnsupported) && c.OnError != nil {
+			c.OnError(sender.Name(), msg, err)
+		}
+		failures = append(failures, sender.Name()+": "+err.Error())
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAllFailed, strings.Join(failures, "; "))
+}
+
+func (c *Client) throttle(phone string) error {
+	for _, l := range c.Throttles {
+		res, err := l.Allow(phone)
+		if err != nil {
+			return err
+		}
+		if !res.Allowed {
+			return &ThrottleError{Phone: phone, RetryAfter: res.RetryAfter}
+		}
+	}
+	return nil
+}
+
+func (c *Client) sendWithRetry(ctx context.Context, sender Sender, msg *Message) (*Result, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := sender.Send(ctx, msg)
+		if err == nil {
+			return res, nil
+		}
+		var pe *ProviderError
+		if attempt >= c.Retries || !errors.As(err, &pe) || !pe.Temporary {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * c.Backoff):
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AbnerEarl/goutils/web/limiter"
+)
+
+// fakeProvider 模拟服务商接口，check 校验请求，reply 返回状态码和响应体
+type fakeProvider struct {
+	*httptest.Server
+	hits int32
+}
+
+func newFakeProvider(t *testing.T, check func(r *http.Request, form url.Values), reply func(n int32) (int, string)) *fakeProvider {
+	p := &fakeProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&p.hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			t.Errorf("invalid form: %v", err)
+		}
+		if check != nil {
+			check(r, form)
+		}
+		status, resp := reply(n)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, resp)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProvider) count() int {
+	return int(atomic.LoadInt32(&p.hits))
+}
+
+func reply(status int, body string) func(int32) (int, string) {
+	return func(int32) (int, string) {
+		return status, body
+	}
+}
+
+func newTemplate(t *testing.T) *Template {
+	tpl := &Template{
+		Name:   "login",
+		Text:   "您的验证码是${code}，5分钟内有效",
+		Codes:  map[string]string{"aliyun": "SMS_173230275"},
+		Params: map[string]string{"code": `\d{6}`},
+	}
+	if err := tpl.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return tpl
+}
+
+func checkAliyun(t *testing.T) func(r *http.Request, form url.Values) {
+	return func(r *http.Request, form url.Values) {
+		if r.Method != http.MethodPost {
+			t.Errorf("aliyun method %s", r.Method)
+		}
+		signature := form.Get("Signature")
+		form.Del("Signature")
+		if want := aliyunSignature(http.MethodPost, form, "secret"); signature != want {
+			t.Errorf("aliyun signature %q, want %q", signature, want)
+		}
+		if got := form.Get("AccessKeyId"); got != "key" {
+			t.Errorf("aliyun AccessKeyId %q", got)
+		}
+		if got := form.Get("PhoneNumbers"); got != "13800000000" {
+			t.Errorf("aliyun PhoneNumbers %q", got)
+		}
+		if got := form.Get("TemplateCode"); got != "SMS_173230275" {
+			t.Errorf("aliyun TemplateCode %q", got)
+		}
+		if got := form.Get("TemplateParam"); got != `{"code":"123456"}` {
+			t.Errorf("aliyun TemplateParam %q", got)
+		}
+	}
+}
+
+func checkTwilio(t *testing.T) func(r *http.Request, form url.Values) {
+	return func(r *http.Request, form url.Values) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "AC123" || pass != "token" {
+			t.Errorf("twilio basic auth %q %q", user, pass)
+		}
+		if r.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+			t.Errorf("twilio path %s", r.URL.Path)
+		}
+		if form.Get("To") != "+8613800000000" || form.Get("From") != "+15005550006" || form.Get("Body") != "您的验证码是123456，5分钟内有效" {
+			t.Errorf("twilio form %v", form)
+		}
+	}
+}
+
+func checkTextMagic(t *testing.T) func(r *http.Request, form url.Values) {
+	return func(r *http.Request, form url.Values) {
+		if r.Header.Get("X-TM-Username") != "acme" || r.Header.Get("X-TM-Key") != "key" {
+			t.Errorf("textmagic headers %v", r.Header)
+		}
+		if r.URL.Path != "/api/v2/messages" {
+			t.Errorf("textmagic path %s", r.URL.Path)
+		}
+		if form.Get("phones") != "8613800000000" || form.Get("text") != "您的验证码是123456，5分钟内有效" {
+			t.Errorf("textmagic form %v", form)
+		}
+	}
+}
+
+func newAliyun(t *testing.T, baseUrl string) *AliyunSender {
+	s, err := NewAliyunSender(AliyunConfig{AccessKeyId: "key", AccessKeySecret: "secret", SignName: "ACME", BaseUrl: baseUrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTwilio(t *testing.T, baseUrl string) *TwilioSender {
+	s, err := NewTwilioSender(TwilioConfig{AccountSid: "AC123", AuthToken: "token", From: "+15005550006", BaseUrl: baseUrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newTextMagic(t *testing.T, baseUrl string) *TextMagicSender {
+	s, err := NewTextMagicSender(TextMagicConfig{Username: "acme", ApiKey: "key", BaseUrl: baseUrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// 阿里云文档中的签名示例
+func TestAliyunSignature(t *testing.T) {
+	values := url.Values{}
+	values.Set("AccessKeyId", "testid")
+	values.Set("Action", "DescribeRegions")
+	values.Set("Format", "XML")
+	values.Set("SignatureMethod", "HMAC-SHA1")
+	values.Set("SignatureNonce", "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf")
+	values.Set("SignatureVersion", "1.0")
+	values.Set("Timestamp", "2016-02-23T12:46:24Z")
+	values.Set("Version", "2014-05-26")
+	if got, want := aliyunSignature(http.MethodGet, values, "testsecret"), "OLeaidS1JvxuMvnyHOwuJ+uX5qY="; got != want {
+		t.Fatalf("signature %q, want %q", got, want)
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	msg := &Message{To: "+8613800000000", Template: newTemplate(t), Params: map[string]string{"code": "123456"}}
+	cases := []struct {
+		name      string
+		sender    func(t *testing.T, baseUrl string) Sender
+		check     func(t *testing.T) func(r *http.Request, form url.Values)
+		status    int
+		body      string
+		messageId string
+		code      string
+		temporary bool
+	}{
+		{"aliyun ok", aliyunSender, checkAliyun, 200, `{"Code":"OK","BizId":"biz-1","RequestId":"r"}`, "biz-1", "", false},
+		{"aliyun limit", aliyunSender, checkAliyun, 200, `{"Code":"isv.BUSINESS_LIMIT_CONTROL","Message":"limit"}`, "", "isv.BUSINESS_LIMIT_CONTROL", false},
+		{"aliyun throttling", aliyunSender, checkAliyun, 400, `{"Code":"Throttling","Message":"busy"}`, "", "Throttling", true},
+		{"aliyun unavailable", aliyunSender, checkAliyun, 503, `unavailable`, "", "", true},
+		{"twilio ok", twilioSender, checkTwilio, 201, `{"sid":"SM1"}`, "SM1", "", false},
+		{"twilio invalid to", twilioSender, checkTwilio, 400, `{"code":21211,"message":"Invalid 'To' Phone Number"}`, "", "21211", false},
+		{"twilio too many requests", twilioSender, checkTwilio, 429, `{"code":20429,"message":"Too Many Requests"}`, "", "20429", true},
+		{"twilio server error", twilioSender, checkTwilio, 500, `oops`, "", "", true},
+		{"textmagic ok", textMagicSender, checkTextMagic, 201, `{"id":11,"messageId":22}`, "22", "", false},
+		{"textmagic invalid", textMagicSender, checkTextMagic, 400, `{"code":400,"message":"Validation Failed"}`, "", "400", false},
+		{"textmagic unauthorized", textMagicSender, checkTextMagic, 401, `{"code":401,"message":"Unauthorized"}`, "", "401", false},
+		{"textmagic server error", textMagicSender, checkTextMagic, 502, `bad gateway`, "", "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newFakeProvider(t, tc.check(t), reply(tc.status, tc.body))
+			res, err := tc.sender(t, p.URL).Send(context.Background(), msg)
+			if tc.messageId != "" {
+				if err != nil || res.MessageId != tc.messageId {
+					t.Fatalf("got %v %v, want message id %s", res, err, tc.messageId)
+				}
+				return
+			}
+			var pe *ProviderError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got %v, want a ProviderError", err)
+			}
+			if pe.Status != tc.status || pe.Code != tc.code || pe.Temporary != tc.temporary {
+				t.Fatalf("got status %d code %q temporary %v", pe.Status, pe.Code, pe.Temporary)
+			}
+		})
+	}
+}
+
+func aliyunSender(t *testing.T, baseUrl string) Sender    { return newAliyun(t, baseUrl) }
+func twilioSender(t *testing.T, baseUrl string) Sender    { return newTwilio(t, baseUrl) }
+func textMagicSender(t *testing.T, baseUrl string) Sender { return newTextMagic(t, baseUrl) }
+
+func TestClientFailover(t *testing.T) {
+	aliyun := newFakeProvider(t, checkAliyun(t), reply(503, `{"Code":"ServiceUnavailable"}`))
+	twilio := newFakeProvider(t, checkTwilio(t), reply(400, `{"code":21610,"message":"Attempt to send to unsubscribed recipient"}`))
+	textMagic := newFakeProvider(t, checkTextMagic(t), reply(201, `{"id":1,"messageId":2}`))
+
+	client := NewClient(newAliyun(t, aliyun.URL), newTwilio(t, twilio.URL), newTextMagic(t, textMagic.URL))
+	client.Backoff = time.Millisecond
+	var failed []string
+	client.OnError = func(provider string, msg *Message, err error) {
+		failed = append(failed, provider)
+	}
+	if err := client.AddTemplate(newTemplate(t)); err != nil {
+		t.Fatal(err)
+	}
+	tpl, _ := client.Template("login")
+	msg := &Message{To: "+86 138-0000-0000", Template: tpl, Params: map[string]string{"code": "123456"}}
+	res, err := client.SendMessage(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Provider != "textmagic" || res.MessageId != "2" {
+		t.Fatalf("result %+v", res)
+	}
+	// 临时错误重试一次，永久错误直接切换
+	if aliyun.count() != 2 || twilio.count() != 1 || textMagic.count() != 1 {
+		t.Fatalf("hits aliyun %d, twilio %d, textmagic %d", aliyun.count(), twilio.count(), textMagic.count())
+	}
+	if len(failed) != 2 || failed[0] != "aliyun" || failed[1] != "twilio" {
+		t.Fatalf("OnError %v", failed)
+	}
+	if msg.To != "+86 138-0000-0000" {
+		t.Fatalf("the message was modified: %q", msg.To)
+	}
+}
+
+func TestClientAllFailed(t *testing.T) {
+	aliyun := newFakeProvider(t, nil, reply(200, `{"Code":"OK","BizId":"biz-1"}`))
+	twilio := newFakeProvider(t, nil, reply(401, `{"code":20003,"message":"Authenticate"}`))
+	client := NewClient(newAliyun(t, aliyun.URL), newTwilio(t, twilio.URL))
+	l, err := limiter.NewMemoryLimiter(limiter.Rule{Algorithm: limiter.FixedWindow, Limit: 1, Period: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Throttles = []limiter.Limiter{l}
+
+	// 阿里云不支持文本短信，Twilio 鉴权失败
+	_, err = client.SendText(context.Background(), "+8613800000000", "hello")
+	if !errors.Is(err, ErrAllFailed) {
+		t.Fatalf("got %v, want %v", err, ErrAllFailed)
+	}
+	if aliyun.count() != 0 || twilio.count() != 1 {
+		t.Fatalf("hits aliyun %d, twilio %d", aliyun.count(), twilio.count())
+	}
+	// 失败的发送同样计入限流
+	var te *ThrottleError
+	if _, err = client.SendText(context.Background(), "+8613800000000", "hello"); !errors.As(err, &te) || !errors.Is(err, ErrThrottled) {
+		t.Fatalf("got %v, want a ThrottleError", err)
+	}
+	if te.RetryAfter <= 0 || twilio.count() != 1 {
+		t.Fatalf("retry after %s, twilio hits %d", te.RetryAfter, twilio.count())
+	}
+}
+
+func TestClientTemplate(t *testing.T) {
+	aliyun := newFakeProvider(t, checkAliyun(t), reply(200, `{"Code":"OK","BizId":"biz-1"}`))
+	client := NewClient(newAliyun(t, aliyun.URL))
+	if err := client.AddTemplate(newTemplate(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Send(context.Background(), "+8613800000000", "signup", nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrTemplateNotFound)
+	}
+	for _, params := range []map[string]string{nil, {"code": "12ab56"}, {"code": "123456", "name": "x"}} {
+		if _, err := client.Send(context.Background(), "+8613800000000", "login", params); !errors.Is(err, ErrTemplateParam) {
+			t.Fatalf("params %v: got %v, want %v", params, err, ErrTemplateParam)
+		}
+	}
+	if _, err := client.Send(context.Background(), "13800000000", "login", map[string]string{"code": "123456"}); !errors.Is(err, ErrPhone) {
+		t.Fatalf("got %v, want %v", err, ErrPhone)
+	}
+	res, err := client.Send(context.Background(), "+8613800000000", "login", map[string]string{"code": "123456"})
+	if err != nil || res.MessageId != "biz-1" {
+		t.Fatalf("got %v %v", res, err)
+	}
+	if aliyun.count() != 1 {
+		t.Fatalf("aliyun hits %d", aliyun.count())
+	}
+}
@@ -15,6 +15,9 @@ import (
 	"strings"
 )
 
+// SendWithTwilio 直接调用 Twilio 接口并返回原始响应
+//
+// Deprecated: 使用 NewTwilioSender，可以配置 BaseUrl、HTTPClient，并支持模板、限流和切换服务商
 func SendWithTwilio(accountSid, authToken, from, to, message string) (map[string]interface{}, error) {
 	// visit https://www.twilio.com/ to get auth info.
 
@@ -48,11 +51,17 @@ func SendWithTwilio(accountSid, authToken, from, to, message string) (map[string
 	return res, err
 }
 
+// SendWithTextMagic 没有实现，保留以兼容旧代码
+//
+// Deprecated: 使用 NewTextMagicSender
 func SendWithTextMagic() {
 	// visit https://textmagic.com/ to get auth info.
 
 }
 
+// SendWithCourier 没有实现，保留以兼容旧代码
+//
+// Deprecated: 没有 Courier 的实现，实现 Sender 接口后交给 NewClient 使用
 func SendWithCourier() {
 	// visit https://www.courier.com/ to get auth info.
 }
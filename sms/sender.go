@@ -0,0 +1,105 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupported = errors.New("the message is not supported by the provider")
+	ErrPhone       = errors.New("invalid phone number")
+)
+
+// Message 要发送的短信，Template 和 Text 二选一
+type Message struct {
+	// To 手机号，使用 E.164 格式，如 +8613800000000
+	To       string
+	Template *Template
+	Params   map[string]string
+	// Text 不使用模板时直接发送的内容，阿里云只支持模板短信
+	Text string
+}
+
+// content 渲染后的短信内容
+func (m *Message) content() string {
+	if m.Template != nil {
+		return m.Template.Render(m.Params)
+	}
+	return m.Text
+}
+
+// Result 发送结果，MessageId 为服务商返回的消息编号
+type Result struct {
+	Provider  string `json:"provider"`
+	MessageId string `json:"message_id"`
+}
+
+// Sender 短信服务商，不能发送该消息（如缺少模板编号）时返回 ErrUnsupported
+type Sender interface {
+	Name() string
+	Send(ctx context.Context, msg *Message) (*Result, error)
+}
+
+// ProviderError 服务商返回的错误，Temporary 为 true 时（网络错误、限流、5xx）可以重试
+type ProviderError struct {
+	Provider  string
+	Status    int
+	Code      string
+	Message   string
+	Temporary bool
+}
+
+func (e *ProviderError) Error() string {
+	if e.Status > 0 {
+		return fmt.Sprintf("%s: status %d, code %s: %s", e.Provider, e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Provider, e.Message)
+}
+
+// defaultHTTPClient 各服务商未设置 HTTPClient 时使用
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// doRequest 发送请求并读取响应，网络错误包装为可以重试的 ProviderError
+func doRequest(client *http.Client, provider string, req *http.Request) (int, []byte, error) {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return 0, nil, ctxErr
+		}
+		return 0, nil, &ProviderError{Provider: provider, Message: err.Error(), Temporary: true}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, nil, &ProviderError{Provider: provider, Status: resp.StatusCode, Message: err.Error(), Temporary: true}
+	}
+	return resp.StatusCode, body, nil
+}
+
+// temporaryStatus 限流和服务端错误可以重试
+func temporaryStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// normalizePhone 去掉空格和横线，要求为 E.164 格式
+func normalizePhone(phone string) (string, error) {
+	phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(phone)
+	digits := strings.TrimPrefix(phone, "+")
+	if !strings.HasPrefix(phone, "+") || len(digits) < 6 || len(digits) > 15 {
+		return "", fmt.Errorf("%w: %s", ErrPhone, phone)
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", fmt.Errorf("%w: %s", ErrPhone, phone)
+		}
+	}
+	return phone, nil
+}
@@ -0,0 +1,101 @@
+package sms
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrTemplate         = errors.New("invalid sms template")
+	ErrTemplateNotFound = errors.New("the sms template does not exist")
+	ErrTemplateParam    = errors.New("invalid sms template parameter")
+)
+
+var placeholderRegexp = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Template 命名的短信模板
+type Template struct {
+	Name string
+	// Text 短信内容，参数使用 ${name} 占位，和阿里云模板的写法一致，Twilio、TextMagic 发送渲染后的内容
+	Text string
+	// Codes 服务商的模板编号，key 为 Sender.Name()，如 {"aliyun": "SMS_173230275"}
+	Codes map[string]string
+	// Params 参数校验规则，key 为参数名，value 为正则表达式（需要完整匹配），空字符串表示只要求非空。
+	// 为空时使用 Text 中的全部占位符
+	Params map[string]string
+
+	compiled map[string]*regexp.Regexp
+}
+
+// compile 检查占位符和参数规则是否一致并编译正则
+func (t *Template) compile() error {
+	if t.Name == "" || t.Text == "" {
+		return fmt.Errorf("%w: the name and text are required", ErrTemplate)
+	}
+	names := t.placeholders()
+	if t.Params == nil {
+		t.Params = make(map[string]string, len(names))
+		for _, name := range names {
+			t.Params[name] = ""
+		}
+	}
+	for _, name := range names {
+		if _, ok := t.Params[name]; !ok {
+			return fmt.Errorf("%w: %s has no rule for ${%s}", ErrTemplate, t.Name, name)
+		}
+	}
+	t.compiled = make(map[string]*regexp.Regexp, len(t.Params))
+	for name, expr := range t.Params {
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return fmt.Errorf("%w: %s.%s: %v", ErrTemplate, t.Name, name, err)
+		}
+		t.compiled[name] = re
+	}
+	return nil
+}
+
+// Validate 参数必须和规则一一对应，不允许缺少或多余的参数
+func (t *Template) Validate(params map[string]string) error {
+	for name := range t.Params {
+		value := strings.TrimSpace(params[name])
+		if value == "" {
+			return fmt.Errorf("%w: %s is required", ErrTemplateParam, name)
+		}
+		if re := t.compiled[name]; re != nil && !re.MatchString(value) {
+			return fmt.Errorf("%w: %s is malformed", ErrTemplateParam, name)
+		}
+	}
+	for name := range params {
+		if _, ok := t.Params[name]; !ok {
+			return fmt.Errorf("%w: %s is unknown", ErrTemplateParam, name)
+		}
+	}
+	return nil
+}
+
+// Render 替换 Text 中的占位符
+func (t *Template) Render(params map[string]string) string {
+	return placeholderRegexp.ReplaceAllStringFunc(t.Text, func(s string) string {
+		return params[s[2:len(s)-1]]
+	})
+}
+
+func (t *Template) placeholders() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range placeholderRegexp.FindAllStringSubmatch(t.Text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,92 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TextMagicConfig TextMagic 短信配置，ApiKey 在 https://my.textmagic.com/online/api/rest-api/keys 生成
+type TextMagicConfig struct {
+	Username string
+	ApiKey   string
+	// From 发送方号码或名称，可以为空
+	From string
+	// BaseUrl 默认 https://rest.textmagic.com，测试时可以指向 httptest.Server
+	BaseUrl    string
+	HTTPClient *http.Client
+}
+
+// TextMagicSender TextMagic 短信，发送模板渲染后的内容
+type TextMagicSender struct {
+	config TextMagicConfig
+}
+
+func NewTextMagicSender(config TextMagicConfig) (*TextMagicSender, error) {
+	//use example:
+	//sender, err := sms.NewTextMagicSender(sms.TextMagicConfig{Username: "acme", ApiKey: key})
+	if config.Username == "" || config.ApiKey == "" {
+		return nil, errors.New("the textmagic username and api key are required")
+	}
+	if config.BaseUrl == "" {
+		config.BaseUrl = "https://rest.textmagic.com"
+	}
+	config.BaseUrl = strings.TrimSuffix(config.BaseUrl, "/")
+	return &TextMagicSender{config: config}, nil
+}
+
+func (s *TextMagicSender) Name() string {
+	return "textmagic"
+}
+
+// Send https://docs.textmagic.com/#tag/Outbound-Messages/operation/sendMessage
+func (s *TextMagicSender) Send(ctx context.Context, msg *Message) (*Result, error) {
+	content := msg.content()
+	if content == "" {
+		return nil, ErrUnsupported
+	}
+	values := url.Values{}
+	values.Set("text", content)
+	values.Set("phones", strings.TrimPrefix(msg.To, "+"))
+	if s.config.From != "" {
+		values.Set("from", s.config.From)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.BaseUrl+"/api/v2/messages", strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-TM-Username", s.config.Username)
+	req.Header.Set("X-TM-Key", s.config.ApiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	status, body, err := doRequest(s.config.HTTPClient, s.Name(), req)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Id        json.Number `json:"id"`
+		MessageId json.Number `json:"messageId"`
+		Code      json.Number `json:"code"`
+		Message   string      `json:"message"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil || status/100 != 2 {
+		if resp.Message == "" {
+			resp.Message = strings.TrimSpace(string(body))
+		}
+		return nil, &ProviderError{
+			Provider:  s.Name(),
+			Status:    status,
+			Code:      resp.Code.String(),
+			Message:   resp.Message,
+			Temporary: temporaryStatus(status),
+		}
+	}
+	id := resp.MessageId.String()
+	if id == "" {
+		id = resp.Id.String()
+	}
+	return &Result{Provider: s.Name(), MessageId: id}, nil
+}
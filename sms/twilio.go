@@ -0,0 +1,93 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TwilioConfig Twilio 短信配置，From 和 MessagingServiceSid 二选一
+type TwilioConfig struct {
+	AccountSid          string
+	AuthToken           string
+	From                string
+	MessagingServiceSid string
+	// BaseUrl 默认 https://api.twilio.com，测试时可以指向 httptest.Server
+	BaseUrl    string
+	HTTPClient *http.Client
+}
+
+// TwilioSender Twilio 短信，发送模板渲染后的内容
+type TwilioSender struct {
+	config TwilioConfig
+}
+
+func NewTwilioSender(config TwilioConfig) (*TwilioSender, error) {
+	//use example:
+	//sender, err := sms.NewTwilioSender(sms.TwilioConfig{AccountSid: sid, AuthToken: token, From: "+15005550006"})
+	if config.AccountSid == "" || config.AuthToken == "" {
+		return nil, errors.New("the twilio account sid and auth token are required")
+	}
+	if config.From == "" && config.MessagingServiceSid == "" {
+		return nil, errors.New("the twilio from number or messaging service sid is required")
+	}
+	if config.BaseUrl == "" {
+		config.BaseUrl = "https://api.twilio.com"
+	}
+	config.BaseUrl = strings.TrimSuffix(config.BaseUrl, "/")
+	return &TwilioSender{config: config}, nil
+}
+
+func (s *TwilioSender) Name() string {
+	return "twilio"
+}
+
+// Send https://www.twilio.com/docs/messaging/api/message-resource#create-a-message-resource
+func (s *TwilioSender) Send(ctx context.Context, msg *Message) (*Result, error) {
+	content := msg.content()
+	if content == "" {
+		return nil, ErrUnsupported
+	}
+	values := url.Values{}
+	values.Set("To", msg.To)
+	values.Set("Body", content)
+	if s.config.MessagingServiceSid != "" {
+		values.Set("MessagingServiceSid", s.config.MessagingServiceSid)
+	} else {
+		values.Set("From", s.config.From)
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", s.config.BaseUrl, url.PathEscape(s.config.AccountSid))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(s.config.AccountSid, s.config.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	status, body, err := doRequest(s.config.HTTPClient, s.Name(), req)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Sid     string      `json:"sid"`
+		Code    json.Number `json:"code"`
+		Message string      `json:"message"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil || status/100 != 2 || resp.Sid == "" {
+		if resp.Message == "" {
+			resp.Message = strings.TrimSpace(string(body))
+		}
+		return nil, &ProviderError{
+			Provider:  s.Name(),
+			Status:    status,
+			Code:      resp.Code.String(),
+			Message:   resp.Message,
+			Temporary: temporaryStatus(status),
+		}
+	}
+	return &Result{Provider: s.Name(), MessageId: resp.Sid}, nil
+}
@@ -22,12 +22,28 @@ const (
 	AUTO_ID_GENERATOR_COUNTER_KEY = "auto_id_generator_counter_key"
 )
 
+// GenAutoId redis 不可用时返回 0，需要处理错误时使用 GenAutoIdErr
 func GenAutoId(client *redisc.RedisCli) uint64 {
-	saltOne, _ := rand.Int(rand.Reader, big.NewInt(MAX_RANDOM_NUMBER))
-	saltTwo, _ := rand.Int(rand.Reader, big.NewInt(MAX_RANDOM_NUMBER))
-	incrValue, _ := client.RdbIncr(AUTO_ID_GENERATOR_COUNTER_KEY)
+	id, _ := GenAutoIdErr(client)
+	return id
+}
+
+// GenAutoIdErr 同 GenAutoId，redis 自增或生成随机数失败时返回错误
+func GenAutoIdErr(client *redisc.RedisCli) (uint64, error) {
+	incrValue, err := client.RdbIncr(AUTO_ID_GENERATOR_COUNTER_KEY)
+	if err != nil {
+		return 0, err
+	}
+	saltOne, err := rand.Int(rand.Reader, big.NewInt(MAX_RANDOM_NUMBER))
+	if err != nil {
+		return 0, err
+	}
+	saltTwo, err := rand.Int(rand.Reader, big.NewInt(MAX_RANDOM_NUMBER))
+	if err != nil {
+		return 0, err
+	}
 	result := (incrValue << INCREASE_COUNTER_SHIFT) | (saltOne.Int64() << SALT_ONE_SHIFT) | saltTwo.Int64()
-	return uint64(result)
+	return uint64(result), nil
 }
 
 func GenAutoIds(n uint64, client *redisc.RedisCli) []uint64 {
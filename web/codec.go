@@ -0,0 +1,215 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+)
+
+var (
+	ErrCodecAlphabet = errors.New("the alphabet must contain at least 2 unique ascii characters")
+	ErrCodecBits     = errors.New("the permutation bits must be an even number between 8 and 64")
+	ErrCodeInvalid   = errors.New("the code contains invalid characters or is not canonical")
+	ErrCodeChecksum  = errors.New("the check character of the code is wrong")
+	ErrIdOverflow    = errors.New("the id exceeds the range of the codec")
+)
+
+const (
+	DefaultCodecAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	feistelRounds        = 6
+)
+
+type CodecOption func(c *Codec)
+
+// WithAlphabet 自定义字符表，例如去掉容易混淆的 0/O、1/l/I
+func WithAlphabet(alphabet string) CodecOption {
+	return func(c *Codec) {
+		c.alphabet = alphabet
+	}
+}
+
+// WithMinLength 最小长度（不包括校验字符），不足时在前面补字符表的第一个字符
+func WithMinLength(n int) CodecOption {
+	return func(c *Codec) {
+		c.minLength = n
+	}
+}
+
+// WithPermutation 使用密钥对 [0, 2^bits) 内的 id 做一一映射，连续的 id 生成不相邻的短码，
+// bits 决定 id 的上限和短码的长度，例如 62 个字符时 40 位最多 7 个字符
+func WithPermutation(key []byte, bits uint) CodecOption {
+	return func(c *Codec) {
+		c.key = key
+		c.bits = bits
+	}
+}
+
+// WithCheckChar 在末尾追加一个校验字符（Luhn mod N），可以发现单个字符错误和大部分相邻字符颠倒
+func WithCheckChar() CodecOption {
+	return func(c *Codec) {
+		c.checkChar = true
+	}
+}
+
+// Codec 整数和短码的相互转换，只使用整数运算，高位在前
+type Codec struct {
+	alphabet  string
+	minLength int
+	key       []byte
+	bits      uint
+	checkChar bool
+	index     [256]int
+}
+
+func NewCodec(opts ...CodecOption) (*Codec, error) {
+	//use example:
+	//codec, err := web.NewCodec(web.WithMinLength(6), web.WithPermutation([]byte("secret"), 40), web.WithCheckChar())
+	//code, err := codec.Encode(10086)
+	//id, err := codec.Decode(code)
+	//svc.Encode = codec.Encode
+	c := &Codec{alphabet: DefaultCodecAlphabet}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if len(c.alphabet) < 2 {
+		return nil, ErrCodecAlphabet
+	}
+	for i := range c.index {
+		c.index[i] = -1
+	}
+	for i := 0; i < len(c.alphabet); i++ {
+		ch := c.alphabet[i]
+		if ch >= 128 || c.index[ch] >= 0 {
+			return nil, ErrCodecAlphabet
+		}
+		c.index[ch] = i
+	}
+	if c.key != nil && (c.bits < 8 || c.bits > 64 || c.bits%2 != 0) {
+		return nil, ErrCodecBits
+	}
+	if c.minLength < 0 {
+		c.minLength = 0
+	}
+	return c, nil
+}
+
+func (c *Codec) Encode(id uint64) (string, error) {
+	if c.key != nil {
+		if c.bits < 64 && id >= 1<<c.bits {
+			return "", ErrIdOverflow
+		}
+		id = c.permute(id, false)
+	}
+	base := uint64(len(c.alphabet))
+	var buf [64]byte
+	pos := len(buf)
+	for id > 0 || pos == len(buf) {
+		pos--
+		buf[pos] = c.alphabet[id%base]
+		id /= base
+	}
+	code := string(buf[pos:])
+	if len(code) < c.minLength {
+		code = strings.Repeat(c.alphabet[:1], c.minLength-len(code)) + code
+	}
+	if c.checkChar {
+		code += string(c.alphabet[c.checksum(code)])
+	}
+	return code, nil
+}
+
+// Decode 先校验字符和校验位，不合法的短码不需要再查询存储
+func (c *Codec) Decode(code string) (uint64, error) {
+	if c.checkChar {
+		if len(code) < 2 {
+			return 0, ErrCodeInvalid
+		}
+		body, check := code[:len(code)-1], code[len(code)-1]
+		if c.index[check] < 0 {
+			return 0, ErrCodeInvalid
+		}
+		for i := 0; i < len(body); i++ {
+			if c.index[body[i]] < 0 {
+				return 0, ErrCodeInvalid
+			}
+		}
+		if c.index[check] != c.checksum(body) {
+			return 0, ErrCodeChecksum
+		}
+		code = body
+	}
+	// 只接受 Encode 生成的形式，避免多个短码对应同一个 id
+	if len(code) == 0 || len(code) < c.minLength || (len(code) > c.minLength && len(code) > 1 && code[0] == c.alphabet[0]) {
+		return 0, ErrCodeInvalid
+	}
+	base := uint64(len(c.alphabet))
+	var id uint64
+	for i := 0; i < len(code); i++ {
+		n := c.index[code[i]]
+		if n < 0 {
+			return 0, ErrCodeInvalid
+		}
+		if id > (math.MaxUint64-uint64(n))/base {
+			return 0, ErrIdOverflow
+		}
+		id = id*base + uint64(n)
+	}
+	if c.key != nil {
+		if c.bits < 64 && id >= 1<<c.bits {
+			return 0, ErrIdOverflow
+		}
+		id = c.permute(id, true)
+	}
+	return id, nil
+}
+
+// Valid 只校验短码格式和校验位
+func (c *Codec) Valid(code string) bool {
+	_, err := c.Decode(code)
+	return err == nil
+}
+
+// permute 平衡 Feistel 网络，每轮的轮函数为 HMAC-SHA256(key, round|right)
+func (c *Codec) permute(id uint64, inverse bool) uint64 {
+	half := c.bits / 2
+	mask := uint64(1)<<half - 1
+	left, right := id>>half&mask, id&mask
+	for i := 0; i < feistelRounds; i++ {
+		if inverse {
+			round := feistelRounds - 1 - i
+			left, right = right^c.round(round, left)&mask, left
+		} else {
+			left, right = right, left^c.round(i, right)&mask
+		}
+	}
+	return left<<half | right
+}
+
+func (c *Codec) round(round int, value uint64) uint64 {
+	var buf [9]byte
+	buf[0] = byte(round)
+	binary.BigEndian.PutUint64(buf[1:], value)
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(buf[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// checksum Luhn mod N 算法
+func (c *Codec) checksum(code string) int {
+	n := len(c.alphabet)
+	factor := 2
+	sum := 0
+	for i := len(code) - 1; i >= 0; i-- {
+		addend := factor * c.index[code[i]]
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+		sum += addend/n + addend%n
+	}
+	return (n - sum%n) % n
+}
@@ -8,145 +8,84 @@
 package web
 
 import (
-	"encoding/json"
 	"fmt"
-	"github.com/AbnerEarl/goutils/redisc"
-	"golang.org/x/time/rate"
 	"sync"
 	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+	"github.com/AbnerEarl/goutils/web/limiter"
 )
 
-var funnels = sync.Map{}
+// 以下函数保留用于兼容，新代码请直接使用 web/limiter 包
+// capacity 为桶容量，leakingRate 为每秒流出（或补充）的数量，redis 出错时放行
 
-type funnelRateLimiter struct {
-	Capacity    int64
-	LeakingRate float64
-	LeftQuota   int64
-	LeakingTs   int64
-}
+var memoryLimiters = sync.Map{}
 
-func (frl *funnelRateLimiter) makeSpace() {
-	nowTs := time.Millisecond.Milliseconds()
-	deltaTs := nowTs - frl.LeakingTs
-	deltaQuota := int64(float64(deltaTs) * frl.LeakingRate)
-	if deltaQuota < 0 {
-		frl.LeftQuota = frl.Capacity
-		frl.LeakingTs = nowTs
-		return
-	} else if deltaQuota < 1 {
-		return
+func bucketRule(algorithm limiter.Algorithm, capacity int64, leakingRate float64) limiter.Rule {
+	period := time.Second
+	if leakingRate > 0 {
+		period = time.Duration(float64(capacity) / leakingRate * float64(time.Second))
 	}
-	frl.LeftQuota += deltaQuota
-	frl.LeakingTs = nowTs
-	if frl.LeftQuota > frl.Capacity {
-		frl.LeftQuota = frl.Capacity
+	return limiter.Rule{Algorithm: algorithm, Limit: capacity, Period: period}
+}
+
+func allowByMap(key string, rule limiter.Rule) bool {
+	name := fmt.Sprintf("%s:%d:%d", rule.Algorithm, rule.Limit, rule.Period)
+	l, ok := memoryLimiters.Load(name)
+	if !ok {
+		ml, err := limiter.NewMemoryLimiter(rule)
+		if err != nil {
+			return false
+		}
+		l, _ = memoryLimiters.LoadOrStore(name, ml)
 	}
+	res, _ := l.(*limiter.MemoryLimiter).Allow(key)
+	return res.Allowed
 }
 
-func (frl *funnelRateLimiter) watering(quota int64) bool {
-	frl.makeSpace()
-	if frl.LeftQuota > quota {
-		frl.LeftQuota -= quota
-		return true
+func allowByRedis(key string, rule limiter.Rule, newLimiter func(rule limiter.Rule) (*limiter.RedisLimiter, error)) bool {
+	l, err := newLimiter(rule)
+	if err != nil {
+		return false
 	}
-	return false
+	res, err := l.Allow(key)
+	return err != nil || res.Allowed
 }
 
 func IsAllowedByMap(userId, actionKey string, capacity int64, leakingRate float64) bool {
 	key := fmt.Sprintf("%s:%s", userId, actionKey)
-	funnel, ok := funnels.Load(key)
-	if !ok {
-		frl := funnelRateLimiter{
-			Capacity:    capacity,
-			LeakingRate: leakingRate,
-			LeftQuota:   capacity,
-			LeakingTs:   time.Millisecond.Milliseconds(),
-		}
-		funnels.Store(key, frl)
-		return frl.watering(1)
-	}
-	frl := funnel.(funnelRateLimiter)
-	return frl.watering(1)
+	return allowByMap(key, bucketRule(limiter.LeakyBucket, capacity, leakingRate))
 }
 
 func IsAllowedByRedis(userId, actionKey string, capacity int64, leakingRate float64, client *redisc.RedisCli) bool {
 	key := fmt.Sprintf("%s:%s", userId, actionKey)
-	result, err := client.RdbGet(key)
-	expTime := uint64(float64(capacity)*leakingRate) + 1
-	if err != nil {
-		frl := funnelRateLimiter{
-			Capacity:    capacity,
-			LeakingRate: leakingRate,
-			LeftQuota:   capacity,
-			LeakingTs:   time.Millisecond.Milliseconds(),
-		}
-		client.RdbSet(key, frl, expTime)
-		return frl.watering(1)
-	}
-	client.RdbExpire(key, expTime)
-	frl := funnelRateLimiter{}
-	json.Unmarshal([]byte(result), &frl)
-	return frl.watering(1)
+	return allowByRedis(key, bucketRule(limiter.LeakyBucket, capacity, leakingRate), func(rule limiter.Rule) (*limiter.RedisLimiter, error) {
+		return limiter.NewRedisLimiter(client, "funnel:", rule)
+	})
 }
 
 func IsAllowedByRedisCluster(userId, actionKey string, capacity int64, leakingRate float64, client *redisc.RedisClusterCli) bool {
 	key := fmt.Sprintf("%s:%s", userId, actionKey)
-	result, err := client.RdbGet(key)
-	expTime := uint64(float64(capacity)*leakingRate) + 1
-	if err != nil {
-		frl := funnelRateLimiter{
-			Capacity:    capacity,
-			LeakingRate: leakingRate,
-			LeftQuota:   capacity,
-			LeakingTs:   time.Millisecond.Milliseconds(),
-		}
-		client.RdbSet(key, frl, expTime)
-		return frl.watering(1)
-	}
-	client.RdbExpire(key, expTime)
-	frl := funnelRateLimiter{}
-	json.Unmarshal([]byte(result), &frl)
-	return frl.watering(1)
+	return allowByRedis(key, bucketRule(limiter.LeakyBucket, capacity, leakingRate), func(rule limiter.Rule) (*limiter.RedisLimiter, error) {
+		return limiter.NewRedisClusterLimiter(client, "funnel:", rule)
+	})
 }
 
 func IsLimitedByMap(userId, actionKey string, capacity int, leakingRate float64) bool {
 	key := fmt.Sprintf("%s:%s", userId, actionKey)
-	funnel, ok := funnels.Load(key)
-	if !ok {
-		limiter := rate.NewLimiter(rate.Limit(leakingRate), capacity)
-		funnels.Store(key, limiter)
-		return limiter.Allow()
-	}
-	limiter := funnel.(rate.Limiter)
-	return limiter.Allow()
+	return allowByMap(key, bucketRule(limiter.TokenBucket, int64(capacity), leakingRate))
 }
 
 func IsLimitedByRedis(userId, actionKey string, capacity int, leakingRate float64, client *redisc.RedisCli) bool {
 	key := fmt.Sprintf("%s:%s", userId, actionKey)
-	result, err := client.RdbGet(key)
-	expTime := uint64(float64(capacity)*leakingRate) + 1
-	if err != nil {
-		limiter := rate.NewLimiter(rate.Limit(leakingRate), capacity)
-		client.RdbSet(key, limiter, expTime)
-		return limiter.Allow()
-	}
-	client.RdbExpire(key, expTime)
-	limiter := rate.Limiter{}
-	json.Unmarshal([]byte(result), &limiter)
-	return limiter.Allow()
+	return allowByRedis(key, bucketRule(limiter.TokenBucket, int64(capacity), leakingRate), func(rule limiter.Rule) (*limiter.RedisLimiter, error) {
+		return limiter.NewRedisLimiter(client, "bucket:", rule)
+	})
 }
 
 func IsLimitedByRedisCluster(userId, actionKey string, capacity int, leakingRate float64, client *redisc.RedisClusterCli) bool {
 	key := fmt.Sprintf("%s:%s", userId, actionKey)
-	result, err := client.RdbGet(key)
-	expTime := uint64(float64(capacity)*leakingRate) + 1
-	if err != nil {
-		limiter := rate.NewLimiter(rate.Limit(leakingRate), capacity)
-		client.RdbSet(key, limiter, expTime)
-		return limiter.Allow()
-	}
-	client.RdbExpire(key, expTime)
-	limiter := rate.Limiter{}
-	json.Unmarshal([]byte(result), &limiter)
-	return limiter.Allow()
+	return allowByRedis(key, bucketRule(limiter.TokenBucket, int64(capacity), leakingRate), func(rule limiter.Rule) (*limiter.RedisLimiter, error) {
+		return limiter.NewRedisClusterLimiter(client, "bucket:", rule)
+	})
 }
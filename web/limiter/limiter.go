@@ -0,0 +1,209 @@
+package limiter
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	TokenBucket      Algorithm = "token_bucket"       // 令牌桶，允许突发 Limit 个请求，令牌按 Limit/Period 的速率补充
+	LeakyBucket      Algorithm = "leaky_bucket"       // 漏桶，桶内水位按 Limit/Period 的速率流出，水位不超过 Limit
+	FixedWindow      Algorithm = "fixed_window"       // 固定窗口，每个 Period 内最多 Limit 个请求
+	SlidingWindowLog Algorithm = "sliding_window_log" // 滑动窗口日志，任意 Period 内最多 Limit 个请求
+)
+
+var ErrInvalidRule = errors.New("the limit and period of a rate limit rule must be positive")
+
+type Rule struct {
+	Algorithm Algorithm
+	Limit     int64
+	Period    time.Duration
+}
+
+func (r Rule) validate() error {
+	if r.Limit <= 0 || r.Period < time.Millisecond {
+		return ErrInvalidRule
+	}
+	switch r.Algorithm {
+	case TokenBucket, LeakyBucket, FixedWindow, SlidingWindowLog:
+		return nil
+	}
+	return errors.New("unknown rate limit algorithm: " + string(r.Algorithm))
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	RetryAfter time.Duration // 被拒绝时距离下次可能放行的时间
+	ResetAfter time.Duration // 距离完全恢复的时间
+}
+
+type Limiter interface {
+	Allow(key string) (*Result, error)
+	AllowN(key string, n int64) (*Result, error)
+}
+
+type memoryState struct {
+	value float64
+	ts    int64
+	log   []int64
+}
+
+// MemoryLimiter 单机内存限流，多实例部署时请使用 RedisLimiter
+type MemoryLimiter struct {
+	rule   Rule
+	lock   sync.Mutex
+	states map[string]*memoryState
+	calls  int64
+	now    func() time.Time
+}
+
+func NewMemoryLimiter(rule Rule) (*MemoryLimiter, error) {
+	//use example:
+	//l, _ := limiter.NewMemoryLimiter(limiter.Rule{Algorithm: limiter.TokenBucket, Limit: 100, Period: time.Minute})
+	//res, _ := l.Allow("user:1")
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	return &MemoryLimiter{rule: rule, states: make(map[string]*memoryState), now: time.Now}, nil
+}
+
+func (l *MemoryLimiter) Allow(key string) (*Result, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *MemoryLimiter) AllowN(key string, n int64) (*Result, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now().UnixMilli()
+	l.calls++
+	if l.calls%1000 == 0 {
+		l.cleanup(now)
+	}
+	state, ok := l.states[key]
+	if !ok {
+		state = &memoryState{ts: now}
+		if l.rule.Algorithm == TokenBucket {
+			state.value = float64(l.rule.Limit)
+		}
+		l.states[key] = state
+	}
+	period := l.rule.Period.Milliseconds()
+	limit := l.rule.Limit
+	switch l.rule.Algorithm {
+	case TokenBucket:
+		return tokenBucket(state, now, limit, period, n), nil
+	case LeakyBucket:
+		return leakyBucket(state, now, limit, period, n), nil
+	case FixedWindow:
+		return fixedWindow(state, now, limit, period, n), nil
+	default:
+		return slidingWindowLog(state, now, limit, period, n), nil
+	}
+}
+
+// cleanup 清理长时间未访问的 key
+func (l *MemoryLimiter) cleanup(now int64) {
+	expire := 2 * l.rule.Period.Milliseconds()
+	for key, state := range l.states {
+		last := state.ts
+		if len(state.log) > 0 {
+			last = state.log[len(state.log)-1]
+		}
+		if now-last > expire {
+			delete(l.states, key)
+		}
+	}
+}
+
+func tokenBucket(state *memoryState, now, limit, period, n int64) *Result {
+	rate := float64(limit) / float64(period)
+	if now > state.ts {
+		state.value = math.Min(float64(limit), state.value+float64(now-state.ts)*rate)
+		state.ts = now
+	}
+	res := &Result{Limit: limit}
+	if state.value >= float64(n) {
+		state.value -= float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = msDuration(math.Ceil((float64(n) - state.value) / rate))
+	}
+	res.Remaining = int64(state.value)
+	res.ResetAfter = msDuration(math.Ceil((float64(limit) - state.value) / rate))
+	return res
+}
+
+func leakyBucket(state *memoryState, now, limit, period, n int64) *Result {
+	rate := float64(limit) / float64(period)
+	if now > state.ts {
+		state.value = math.Max(0, state.value-float64(now-state.ts)*rate)
+		state.ts = now
+	}
+	res := &Result{Limit: limit}
+	if state.value+float64(n) <= float64(limit) {
+		state.value += float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = msDuration(math.Ceil((state.value + float64(n) - float64(limit)) / rate))
+	}
+	res.Remaining = int64(float64(limit) - state.value)
+	res.ResetAfter = msDuration(math.Ceil(state.value / rate))
+	return res
+}
+
+func fixedWindow(state *memoryState, now, limit, period, n int64) *Result {
+	window := now / period
+	if state.ts/period != window {
+		state.value = 0
+	}
+	state.ts = now
+	res := &Result{Limit: limit}
+	reset := msDuration(float64((window+1)*period - now))
+	if int64(state.value)+n <= limit {
+		state.value += float64(n)
+		res.Allowed = true
+	} else {
+		res.RetryAfter = reset
+	}
+	res.Remaining = limit - int64(state.value)
+	res.ResetAfter = reset
+	return res
+}
+
+func slidingWindowLog(state *memoryState, now, limit, period, n int64) *Result {
+	start := sort.Search(len(state.log), func(i int) bool {
+		return state.log[i] > now-period
+	})
+	state.log = state.log[start:]
+	res := &Result{Limit: limit}
+	if int64(len(state.log))+n <= limit {
+		for i := int64(0); i < n; i++ {
+			state.log = append(state.log, now)
+		}
+		res.Allowed = true
+	} else if idx := int64(len(state.log)) + n - limit - 1; idx < int64(len(state.log)) {
+		// 需要等待最早的若干条记录滑出窗口
+		res.RetryAfter = msDuration(float64(state.log[idx] + period - now))
+	} else {
+		res.RetryAfter = msDuration(float64(period))
+	}
+	res.Remaining = limit - int64(len(state.log))
+	if len(state.log) > 0 {
+		res.ResetAfter = msDuration(float64(state.log[len(state.log)-1] + period - now))
+	}
+	return res
+}
+
+func msDuration(ms float64) time.Duration {
+	if ms < 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,197 @@
+package limiter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+// 所有脚本只操作一个 key，兼容集群模式；时间取 redis 服务端时间，避免多实例时钟不一致
+const luaNow = `
+if redis.replicate_commands then redis.replicate_commands() end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+`
+
+var tokenBucketScript = redisc.NewScript(luaNow + `
+local rate = limit / period
+local state = redis.call('HMGET', KEYS[1], 'v', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = limit
+	ts = now
+end
+if now > ts then
+	tokens = math.min(limit, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+local retry = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	retry = math.ceil((n - tokens) / rate)
+end
+redis.call('HMSET', KEYS[1], 'v', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], period + 1000)
+return {allowed, math.floor(tokens), retry, math.ceil((limit - tokens) / rate)}
+`)
+
+var leakyBucketScript = redisc.NewScript(luaNow + `
+local rate = limit / period
+local state = redis.call('HMGET', KEYS[1], 'v', 'ts')
+local level = tonumber(state[1])
+local ts = tonumber(state[2])
+if level == nil or ts == nil then
+	level = 0
+	ts = now
+end
+if now > ts then
+	level = math.max(0, level - (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+local retry = 0
+if level + n <= limit then
+	level = level + n
+	allowed = 1
+else
+	retry = math.ceil((level + n - limit) / rate)
+end
+redis.call('HMSET', KEYS[1], 'v', tostring(level), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], period + 1000)
+return {allowed, math.floor(limit - level), retry, math.ceil(level / rate)}
+`)
+
+var fixedWindowScript = redisc.NewScript(luaNow + `
+local window = math.floor(now / period)
+local state = redis.call('HMGET', KEYS[1], 'w', 'c')
+local count = tonumber(state[2])
+if tonumber(state[1]) ~= window or count == nil then
+	count = 0
+end
+local reset = (window + 1) * period - now
+local allowed = 0
+local retry = 0
+if count + n <= limit then
+	count = count + n
+	allowed = 1
+else
+	retry = reset
+end
+redis.call('HMSET', KEYS[1], 'w', window, 'c', count)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+return {allowed, limit - count, retry, reset}
+`)
+
+var slidingWindowLogScript = redisc.NewScript(luaNow + `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - period)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+local retry = 0
+if count + n <= limit then
+	for i = 1, n do
+		redis.call('ZADD', KEYS[1], now, now .. '-' .. ARGV[4] .. '-' .. i)
+	end
+	count = count + n
+	allowed = 1
+else
+	local idx = count + n - limit - 1
+	if idx < count then
+		local item = redis.call('ZRANGE', KEYS[1], idx, idx, 'WITHSCORES')
+		retry = tonumber(item[2]) + period - now
+	else
+		retry = period
+	end
+end
+local reset = 0
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if #last > 0 then
+	reset = tonumber(last[2]) + period - now
+end
+redis.call('PEXPIRE', KEYS[1], period + 1000)
+return {allowed, limit - count, retry, reset}
+`)
+
+var scripts = map[Algorithm]*redisc.Script{
+	TokenBucket:      tokenBucketScript,
+	LeakyBucket:      leakyBucketScript,
+	FixedWindow:      fixedWindowScript,
+	SlidingWindowLog: slidingWindowLogScript,
+}
+
+type scriptEvaluator interface {
+	RdbEvalScript(script *redisc.Script, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// RedisLimiter 基于 lua 脚本的分布式限流，判断和更新在一个脚本中原子完成
+type RedisLimiter struct {
+	cli    scriptEvaluator
+	rule   Rule
+	Prefix string
+}
+
+func NewRedisLimiter(cli *redisc.RedisCli, prefix string, rule Rule) (*RedisLimiter, error) {
+	//use example:
+	//l, _ := limiter.NewRedisLimiter(rdb, "limit:login:", limiter.Rule{Algorithm: limiter.SlidingWindowLog, Limit: 5, Period: time.Minute})
+	//res, err := l.Allow(ip)
+	return newRedisLimiter(cli, prefix, rule)
+}
+
+func NewRedisClusterLimiter(cli *redisc.RedisClusterCli, prefix string, rule Rule) (*RedisLimiter, error) {
+	return newRedisLimiter(cli, prefix, rule)
+}
+
+func newRedisLimiter(cli scriptEvaluator, prefix string, rule Rule) (*RedisLimiter, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		prefix = "limiter:"
+	}
+	return &RedisLimiter{cli: cli, rule: rule, Prefix: prefix}, nil
+}
+
+func (l *RedisLimiter) Allow(key string) (*Result, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *RedisLimiter) AllowN(key string, n int64) (*Result, error) {
+	args := []interface{}{l.rule.Limit, l.rule.Period.Milliseconds(), n}
+	if l.rule.Algorithm == SlidingWindowLog {
+		nonce := make([]byte, 8)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		args = append(args, hex.EncodeToString(nonce))
+	}
+	reply, err := l.cli.RdbEvalScript(scripts[l.rule.Algorithm], []string{l.Prefix + key}, args...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+	nums := make([]int64, 4)
+	for i, v := range values {
+		if nums[i], ok = v.(int64); !ok {
+			return nil, fmt.Errorf("unexpected rate limit reply: %v", reply)
+		}
+	}
+	return &Result{
+		Allowed:    nums[0] == 1,
+		Limit:      l.rule.Limit,
+		Remaining:  nums[1],
+		RetryAfter: time.Duration(nums[2]) * time.Millisecond,
+		ResetAfter: time.Duration(nums[3]) * time.Millisecond,
+	}, nil
+}
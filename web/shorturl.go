@@ -177,21 +177,34 @@ func (su *ShortUrl) ConvLongUrl(shortUrl string) (string, error) {
 	return longUrl, nil
 }
 
+// Char2Int Int2Char 的逆运算，低位在前，新代码请使用 Codec
 func Char2Int(code string, base uint64) (uint64, error) {
-	ss := strings.Split(code, "")
+	if base < 2 || base > uint64(len(BASE_CHAR)) {
+		return 0, errors.New("the value of base exceeds the maximum value")
+	}
 	var result uint64
-	for i := 0; i < len(ss); i++ {
-		n := strings.Index(BASE_STR, ss[i])
-		if n < 0 {
+	weight := uint64(1)
+	for i := 0; i < len(code); i++ {
+		n := strings.IndexByte(BASE_STR, code[i])
+		if n < 0 || uint64(n) >= base {
 			return 0, errors.New("url error, unknown character")
 		}
-		result = result + uint64(n)*uint64(math.Pow(float64(base), float64(i)))
+		if n > 0 && (weight == 0 || uint64(n) > (math.MaxUint64-result)/weight) {
+			return 0, ErrIdOverflow
+		}
+		result += uint64(n) * weight
+		// weight 溢出后置 0，之后只允许出现 0 值字符
+		if weight > math.MaxUint64/base {
+			weight = 0
+		} else {
+			weight *= base
+		}
 	}
 	return result, nil
 }
 
 func Int2Char(number, base uint64) (string, error) {
-	if base > uint64(len(BASE_CHAR)) {
+	if base < 2 || base > uint64(len(BASE_CHAR)) {
 		return "", errors.New("the value of base exceeds the maximum value")
 	}
 	var result []string
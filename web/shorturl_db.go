@@ -0,0 +1,195 @@
+package web
+
+import (
+	"errors"
+	"time"
+
+	"github.com/AbnerEarl/goutils/dbs"
+	"gorm.io/gorm"
+)
+
+type ShortUrlModel struct {
+	dbs.BaseModel
+	Code      string     `json:"code" gorm:"column:code;type:varchar(64);uniqueIndex;comment:'短码'"`
+	LongUrl   string     `json:"long_url" gorm:"column:long_url;type:text;comment:'原始地址'"`
+	Alias     bool       `json:"alias" gorm:"column:alias;comment:'是否自定义短码'"`
+	Disabled  bool       `json:"disabled" gorm:"column:disabled;comment:'是否禁用'"`
+	ExpiresAt *time.Time `json:"expires_at" gorm:"column:expires_at;null;comment:'过期时间'"`
+}
+
+func (m *ShortUrlModel) TableName() string {
+	return "short_url"
+}
+
+// ShortUrlSeqModel 用于生成自增 id
+type ShortUrlSeqModel struct {
+	Id uint64 `json:"id" gorm:"primary_key;column:id;comment:'主键ID'"`
+}
+
+func (m *ShortUrlSeqModel) TableName() string {
+	return "short_url_seq"
+}
+
+type ShortUrlClickModel struct {
+	Id        uint64    `json:"id" gorm:"primary_key;column:id;comment:'主键ID'"`
+	Code      string    `json:"code" gorm:"column:code;type:varchar(64);index;comment:'短码'"`
+	Referer   string    `json:"referer" gorm:"column:referer;null;comment:'来源'"`
+	UserAgent string    `json:"user_agent" gorm:"column:user_agent;null;type:text;comment:'UA'"`
+	Os        string    `json:"os" gorm:"column:os;null;comment:'操作系统'"`
+	Browser   string    `json:"browser" gorm:"column:browser;null;comment:'浏览器'"`
+	Ip        string    `json:"ip" gorm:"column:ip;null;comment:'IP'"`
+	ClickTime time.Time `json:"click_time" gorm:"column:click_time;comment:'访问时间'"`
+}
+
+func (m *ShortUrlClickModel) TableName() string {
+	return "short_url_click"
+}
+
+// DBShortUrlStore 基于 dbs 的短链存储，删除的短链 is_del 为 1
+type DBShortUrlStore struct {
+	db *dbs.DB
+}
+
+func NewDBShortUrlStore(db *dbs.DB) *DBShortUrlStore {
+	//use example:
+	//db.Migration([]interface{}{&web.ShortUrlModel{}, &web.ShortUrlSeqModel{}, &web.ShortUrlClickModel{}})
+	//store := web.NewDBShortUrlStore(db)
+	return &DBShortUrlStore{db: db}
+}
+
+func (s *DBShortUrlStore) NextId() (uint64, error) {
+	seq := &ShortUrlSeqModel{}
+	if err := s.db.DB.Create(seq).Error; err != nil {
+		return 0, err
+	}
+	return seq.Id, nil
+}
+
+func (s *DBShortUrlStore) Create(link *ShortLink) error {
+	var count int64
+	if err := s.db.Model(&ShortUrlModel{}).Where("code = ?", link.Code).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrShortCodeExists
+	}
+	model := &ShortUrlModel{Code: link.Code}
+	fillShortUrlModel(model, link)
+	model.CreatedAt = link.CreatedAt
+	model.UpdatedAt = link.CreatedAt
+	if err := s.db.DB.Create(model).Error; err != nil {
+		// 并发创建时由唯一索引保证不重复
+		if s.db.Model(&ShortUrlModel{}).Where("code = ?", link.Code).Count(&count).Error == nil && count > 0 {
+			return ErrShortCodeExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *DBShortUrlStore) Get(code string) (*ShortLink, error) {
+	model := &ShortUrlModel{}
+	err := s.db.Where("code = ?", code).First(model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrShortUrlNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	link := &ShortLink{
+		Code:      model.Code,
+		LongUrl:   model.LongUrl,
+		Alias:     model.Alias,
+		Disabled:  model.Disabled,
+		Deleted:   model.IsDel == 1,
+		CreatedAt: model.CreatedAt,
+	}
+	if model.ExpiresAt != nil {
+		link.ExpiresAt = *model.ExpiresAt
+	}
+	return link, nil
+}
+
+func (s *DBShortUrlStore) Update(link *ShortLink) error {
+	model := &ShortUrlModel{}
+	fillShortUrlModel(model, link)
+	updates := map[string]interface{}{
+		"long_url":   model.LongUrl,
+		"disabled":   model.Disabled,
+		"expires_at": model.ExpiresAt,
+		"is_del":     model.IsDel,
+		"deleted_at": model.DeletedAt,
+		"updated_at": time.Now(),
+	}
+	result := s.db.Model(&ShortUrlModel{}).Where("code = ?", link.Code).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrShortUrlNotFound
+	}
+	return nil
+}
+
+func (s *DBShortUrlStore) RecordClick(event *ClickEvent) error {
+	return s.db.DB.Create(&ShortUrlClickModel{
+		Code:      event.Code,
+		Referer:   event.Referer,
+		UserAgent: event.UserAgent,
+		Os:        event.Os,
+		Browser:   event.Browser,
+		Ip:        event.Ip,
+		ClickTime: event.Time,
+	}).Error
+}
+
+func (s *DBShortUrlStore) Stats(code string) (*ClickStats, error) {
+	stats := newClickStats(code)
+	for column, target := range map[string]map[string]uint64{
+		"referer": stats.Referers,
+		"os":      stats.Os,
+		"browser": stats.Browsers,
+	} {
+		var rows []struct {
+			Name  string
+			Count uint64
+		}
+		err := s.db.Model(&ShortUrlClickModel{}).
+			Select(column+" AS name, COUNT(*) AS count").
+			Where("code = ?", code).
+			Group(column).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			target[row.Name] = row.Count
+			if column == "os" {
+				stats.Clicks += row.Count
+			}
+		}
+	}
+	if stats.Clicks > 0 {
+		last := &ShortUrlClickModel{}
+		if err := s.db.Where("code = ?", code).Order("id desc").First(last).Error; err != nil {
+			return nil, err
+		}
+		stats.LastClickAt = last.ClickTime
+	}
+	return stats, nil
+}
+
+func fillShortUrlModel(model *ShortUrlModel, link *ShortLink) {
+	model.LongUrl = link.LongUrl
+	model.Alias = link.Alias
+	model.Disabled = link.Disabled
+	if !link.ExpiresAt.IsZero() {
+		expiresAt := link.ExpiresAt
+		model.ExpiresAt = &expiresAt
+	}
+	if link.Deleted {
+		now := time.Now()
+		model.IsDel = 1
+		model.DeletedAt = &now
+	}
+}
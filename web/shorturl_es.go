@@ -0,0 +1,258 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+// EsTransport esc.EsClient7 和 esc.EsClient8 都实现了该接口
+type EsTransport interface {
+	Perform(req *http.Request) (*http.Response, error)
+}
+
+// EsShortUrlStore 基于 elasticsearch 的短链存储，短码作为文档 id，访问记录单独一个索引
+type EsShortUrlStore struct {
+	es         EsTransport
+	LinkIndex  string
+	ClickIndex string
+	NextIdFunc func() (uint64, error)
+}
+
+func NewEsShortUrlStore(es EsTransport, nextId func() (uint64, error)) *EsShortUrlStore {
+	//use example:
+	//store := web.NewEsShortUrlStore(es7, snowflake.NextId)
+	//err := store.CreateIndices()
+	return &EsShortUrlStore{
+		es:         es,
+		LinkIndex:  SHORT_URL_INFO_INDEX,
+		ClickIndex: SHORT_URL_INFO_INDEX + "_click",
+		NextIdFunc: nextId,
+	}
+}
+
+// NewRedisEsShortUrlStore 使用 GenAutoIdErr 生成 id 的 elasticsearch 存储，
+// redis 不可用时返回错误，不会只用随机数生成可能重复的 id
+func NewRedisEsShortUrlStore(es EsTransport, cli *redisc.RedisCli) *EsShortUrlStore {
+	return NewEsShortUrlStore(es, func() (uint64, error) {
+		return GenAutoIdErr(cli)
+	})
+}
+
+// CreateIndices 创建索引，统计用到的字段需要是 keyword 类型
+func (s *EsShortUrlStore) CreateIndices() error {
+	mappings := map[string]map[string]interface{}{
+		s.LinkIndex: {
+			"code":       map[string]string{"type": "keyword"},
+			"long_url":   map[string]string{"type": "keyword", "index": "false"},
+			"expires_at": map[string]string{"type": "date"},
+			"created_at": map[string]string{"type": "date"},
+		},
+		s.ClickIndex: {
+			"code":       map[string]string{"type": "keyword"},
+			"referer":    map[string]string{"type": "keyword"},
+			"user_agent": map[string]string{"type": "text"},
+			"os":         map[string]string{"type": "keyword"},
+			"browser":    map[string]string{"type": "keyword"},
+			"ip":         map[string]string{"type": "keyword"},
+			"time":       map[string]string{"type": "date"},
+		},
+	}
+	for index, properties := range mappings {
+		body := map[string]interface{}{"mappings": map[string]interface{}{"properties": properties}}
+		status, data, err := s.do(http.MethodPut, "/"+index, body)
+		if err != nil {
+			return err
+		}
+		// 索引已存在
+		if status == http.StatusBadRequest && bytes.Contains(data, []byte("resource_already_exists_exception")) {
+			continue
+		}
+		if status >= 300 {
+			return esError(status, data)
+		}
+	}
+	return nil
+}
+
+func (s *EsShortUrlStore) NextId() (uint64, error) {
+	if s.NextIdFunc == nil {
+		return 0, errors.New("the id generator is not initialized")
+	}
+	return s.NextIdFunc()
+}
+
+func (s *EsShortUrlStore) Create(link *ShortLink) error {
+	status, data, err := s.do(http.MethodPut, s.docPath("_create", link.Code)+"?refresh=true", link)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusConflict {
+		return ErrShortCodeExists
+	}
+	if status >= 300 {
+		return esError(status, data)
+	}
+	return nil
+}
+
+func (s *EsShortUrlStore) Get(code string) (*ShortLink, error) {
+	status, data, err := s.do(http.MethodGet, s.docPath("_doc", code), nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, ErrShortUrlNotFound
+	}
+	if status >= 300 {
+		return nil, esError(status, data)
+	}
+	var doc struct {
+		Found  bool       `json:"found"`
+		Source *ShortLink `json:"_source"`
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if !doc.Found || doc.Source == nil {
+		return nil, ErrShortUrlNotFound
+	}
+	return doc.Source, nil
+}
+
+func (s *EsShortUrlStore) Update(link *ShortLink) error {
+	body := map[string]interface{}{"doc": link}
+	status, data, err := s.do(http.MethodPost, s.docPath("_update", link.Code)+"?refresh=true", body)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		return ErrShortUrlNotFound
+	}
+	if status >= 300 {
+		return esError(status, data)
+	}
+	return nil
+}
+
+func (s *EsShortUrlStore) RecordClick(event *ClickEvent) error {
+	status, data, err := s.do(http.MethodPost, "/"+s.ClickIndex+"/_doc", event)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return esError(status, data)
+	}
+	return nil
+}
+
+func (s *EsShortUrlStore) Stats(code string) (*ClickStats, error) {
+	terms := func(field string) map[string]interface{} {
+		return map[string]interface{}{"terms": map[string]interface{}{"field": field, "size": 100}}
+	}
+	body := map[string]interface{}{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            map[string]interface{}{"term": map[string]interface{}{"code": code}},
+		"aggs": map[string]interface{}{
+			"referer": terms("referer"),
+			"os":      terms("os"),
+			"browser": terms("browser"),
+			"last":    map[string]interface{}{"max": map[string]interface{}{"field": "time"}},
+		},
+	}
+	status, data, err := s.do(http.MethodPost, "/"+s.ClickIndex+"/_search", body)
+	if err != nil {
+		return nil, err
+	}
+	stats := newClickStats(code)
+	// 还没有访问记录时索引可能不存在
+	if status == http.StatusNotFound {
+		return stats, nil
+	}
+	if status >= 300 {
+		return nil, esError(status, data)
+	}
+	type bucket struct {
+		Key      string `json:"key"`
+		DocCount uint64 `json:"doc_count"`
+	}
+	var res struct {
+		Hits struct {
+			Total struct {
+				Value uint64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Referer struct {
+				Buckets []bucket `json:"buckets"`
+			} `json:"referer"`
+			Os struct {
+				Buckets []bucket `json:"buckets"`
+			} `json:"os"`
+			Browser struct {
+				Buckets []bucket `json:"buckets"`
+			} `json:"browser"`
+			Last struct {
+				Value *float64 `json:"value"`
+			} `json:"last"`
+		} `json:"aggregations"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	stats.Clicks = res.Hits.Total.Value
+	for _, b := range res.Aggregations.Referer.Buckets {
+		stats.Referers[b.Key] = b.DocCount
+	}
+	for _, b := range res.Aggregations.Os.Buckets {
+		stats.Os[b.Key] = b.DocCount
+	}
+	for _, b := range res.Aggregations.Browser.Buckets {
+		stats.Browsers[b.Key] = b.DocCount
+	}
+	if res.Aggregations.Last.Value != nil {
+		stats.LastClickAt = time.UnixMilli(int64(*res.Aggregations.Last.Value))
+	}
+	return stats, nil
+}
+
+func (s *EsShortUrlStore) docPath(api, code string) string {
+	return "/" + s.LinkIndex + "/" + api + "/" + url.PathEscape(code)
+}
+
+func (s *EsShortUrlStore) do(method, path string, body interface{}) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.es.Perform(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+func esError(status int, data []byte) error {
+	return fmt.Errorf("elasticsearch error, status: %d, body: %s", status, data)
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/AbnerEarl/goutils/gins"
+)
+
+type shortUrlReq struct {
+	Code     string `args:"code"`
+	LongUrl  string `args:"long_url"`
+	Alias    string `args:"alias"`
+	Ttl      int64  `args:"ttl" valid:"min=0"` // 有效期，单位秒，0 表示永不过期
+	Disabled *bool  `args:"disabled"`
+}
+
+// RedirectHandler 跳转到原始地址并记录访问，param 为路由中短码的参数名
+//
+//	server.GET("/d/:code", svc.RedirectHandler("code"))
+func (s *ShortUrlService) RedirectHandler(param string) gins.HandlerFunc {
+	return func(c *gins.Context) {
+		link, err := s.Click(c.Param(param), c.Request)
+		if err != nil {
+			status := http.StatusInternalServerError
+			errno := gins.NewErr(gins.InternalError, err)
+			if errors.Is(err, ErrShortUrlNotFound) {
+				status = http.StatusNotFound
+				errno = gins.NewErr(gins.ParamError, err)
+			} else if errors.Is(err, ErrShortUrlExpired) || errors.Is(err, ErrShortUrlDisabled) {
+				status = http.StatusGone
+				errno = gins.NewErr(gins.ParamError, err)
+			}
+			c.AbortWithStatusJSON(status, gins.Response{Errno: errno})
+			return
+		}
+		c.Redirect(http.StatusFound, link.LongUrl)
+	}
+}
+
+// ManageHandler 短链管理接口，需要放在 gins.Args 之后，按请求方法区分操作：
+// POST 创建短链（long_url、alias、ttl），GET 查询短链和访问统计（code），
+// PUT 修改状态（code、disabled、ttl），DELETE 删除短链（code）
+//
+//	server.ANY("/api/v1/short_url", gins.Args(), svc.ManageHandler())
+func (s *ShortUrlService) ManageHandler() gins.HandlerFunc {
+	return func(c *gins.Context) {
+		var req shortUrlReq
+		if err := c.BindArgs(&req); err != nil {
+			gins.SendResponse(c, err, nil)
+			return
+		}
+		if c.Request.Method != http.MethodPost && req.Code == "" {
+			gins.SendResponse(c, gins.NewErr(gins.ParamError, errors.New("the code is required")), nil)
+			return
+		}
+		var err error
+		var data interface{}
+		switch c.Request.Method {
+		case http.MethodPost:
+			var link *ShortLink
+			var shortUrl string
+			link, shortUrl, err = s.Shorten(req.LongUrl, req.Alias, time.Duration(req.Ttl)*time.Second)
+			if err == nil {
+				data = map[string]interface{}{"link": link, "short_url": shortUrl}
+			}
+		case http.MethodGet:
+			var link *ShortLink
+			var stats *ClickStats
+			if link, err = s.Info(req.Code); err == nil {
+				if stats, err = s.Stats(req.Code); err == nil {
+					data = map[string]interface{}{"link": link, "short_url": s.BaseUrl + link.Code, "stats": stats}
+				}
+			}
+		case http.MethodPut:
+			if req.Disabled != nil {
+				err = s.SetDisabled(req.Code, *req.Disabled)
+			}
+			if err == nil && hasArg(c, "ttl") {
+				expiresAt := time.Time{}
+				if req.Ttl > 0 {
+					expiresAt = time.Now().Add(time.Duration(req.Ttl) * time.Second)
+				}
+				err = s.SetExpiresAt(req.Code, expiresAt)
+			}
+		case http.MethodDelete:
+			err = s.Delete(req.Code)
+		default:
+			c.AbortWithStatus(http.StatusMethodNotAllowed)
+			return
+		}
+		gins.SendResponse(c, shortUrlErr(err), data)
+	}
+}
+
+func hasArg(c *gins.Context, key string) bool {
+	val, ok := c.Get(key)
+	return ok && val != nil && val != ""
+}
+
+func shortUrlErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, e := range []error{ErrShortCodeExists, ErrShortUrlNotFound, ErrShortAlias, ErrLongUrl} {
+		if errors.Is(err, e) {
+			return gins.NewErr(gins.ParamError, err)
+		}
+	}
+	return gins.NewErr(gins.InternalError, err)
+}
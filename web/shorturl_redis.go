@@ -0,0 +1,142 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisShortUrlStore 只依赖 redis 的短链存储，每个操作只涉及一个 key，兼容集群模式
+type RedisShortUrlStore struct {
+	cli    redis.Cmdable
+	Prefix string
+}
+
+func NewRedisShortUrlStore(cli *redisc.RedisCli, prefix string) *RedisShortUrlStore {
+	return newRedisShortUrlStore(cli.Client, prefix)
+}
+
+func NewRedisClusterShortUrlStore(cli *redisc.RedisClusterCli, prefix string) *RedisShortUrlStore {
+	return newRedisShortUrlStore(cli.ClusterClient, prefix)
+}
+
+func newRedisShortUrlStore(cli redis.Cmdable, prefix string) *RedisShortUrlStore {
+	if prefix == "" {
+		prefix = "short_url:"
+	}
+	return &RedisShortUrlStore{cli: cli, Prefix: prefix}
+}
+
+func (s *RedisShortUrlStore) NextId() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	id, err := s.cli.Incr(ctx, s.Prefix+"id").Result()
+	return uint64(id), err
+}
+
+func (s *RedisShortUrlStore) Create(link *ShortLink) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	ok, err := s.cli.SetNX(ctx, s.Prefix+"link:"+link.Code, data, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrShortCodeExists
+	}
+	return nil
+}
+
+func (s *RedisShortUrlStore) Get(code string) (*ShortLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	data, err := s.cli.Get(ctx, s.Prefix+"link:"+code).Result()
+	if err == redis.Nil {
+		return nil, ErrShortUrlNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	link := &ShortLink{}
+	if err = json.Unmarshal([]byte(data), link); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *RedisShortUrlStore) Update(link *ShortLink) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	ok, err := s.cli.SetXX(ctx, s.Prefix+"link:"+link.Code, data, redis.KeepTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrShortUrlNotFound
+	}
+	return nil
+}
+
+// RecordClick 访问统计保存在一个 hash 中: total、last、ref:<referer>、os:<os>、browser:<browser>
+func (s *RedisShortUrlStore) RecordClick(event *ClickEvent) error {
+	key := s.Prefix + "clicks:" + event.Code
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	_, err := s.cli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, "total", 1)
+		pipe.HSet(ctx, key, "last", event.Time.Unix())
+		pipe.HIncrBy(ctx, key, "ref:"+event.Referer, 1)
+		pipe.HIncrBy(ctx, key, "os:"+event.Os, 1)
+		pipe.HIncrBy(ctx, key, "browser:"+event.Browser, 1)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisShortUrlStore) Stats(code string) (*ClickStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	values, err := s.cli.HGetAll(ctx, s.Prefix+"clicks:"+code).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := newClickStats(code)
+	for field, value := range values {
+		n, _ := strconv.ParseUint(value, 10, 64)
+		switch {
+		case field == "total":
+			stats.Clicks = n
+		case field == "last":
+			stats.LastClickAt = time.Unix(int64(n), 0)
+		case strings.HasPrefix(field, "ref:"):
+			stats.Referers[strings.TrimPrefix(field, "ref:")] = n
+		case strings.HasPrefix(field, "os:"):
+			stats.Os[strings.TrimPrefix(field, "os:")] = n
+		case strings.HasPrefix(field, "browser:"):
+			stats.Browsers[strings.TrimPrefix(field, "browser:")] = n
+		}
+	}
+	return stats, nil
+}
+
+func newClickStats(code string) *ClickStats {
+	return &ClickStats{
+		Code:     code,
+		Referers: make(map[string]uint64),
+		Os:       make(map[string]uint64),
+		Browsers: make(map[string]uint64),
+	}
+}
@@ -0,0 +1,314 @@
+package web
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/httpc"
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+var (
+	ErrShortCodeExists  = errors.New("the short code already exists")
+	ErrShortUrlNotFound = errors.New("the short url does not exist")
+	ErrShortUrlExpired  = errors.New("the short url has expired")
+	ErrShortUrlDisabled = errors.New("the short url has been disabled")
+	ErrShortAlias       = errors.New("the alias may only contain letters, digits, '_' and '-', and must be 3 to 64 characters long")
+	ErrLongUrl          = errors.New("the long url must start with http:// or https://")
+)
+
+var aliasReg = regexp.MustCompile(`^[0-9A-Za-z_-]{3,64}$`)
+
+type ShortLink struct {
+	Code      string    `json:"code"`
+	LongUrl   string    `json:"long_url"`
+	Alias     bool      `json:"alias"`
+	Disabled  bool      `json:"disabled"`
+	Deleted   bool      `json:"deleted"`
+	ExpiresAt time.Time `json:"expires_at"` // 零值表示永不过期
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (l *ShortLink) Expired() bool {
+	return !l.ExpiresAt.IsZero() && time.Now().After(l.ExpiresAt)
+}
+
+type ClickEvent struct {
+	Code      string    `json:"code"`
+	Referer   string    `json:"referer"`
+	UserAgent string    `json:"user_agent"`
+	Os        string    `json:"os"`
+	Browser   string    `json:"browser"`
+	Ip        string    `json:"ip"`
+	Time      time.Time `json:"time"`
+}
+
+type ClickStats struct {
+	Code        string            `json:"code"`
+	Clicks      uint64            `json:"clicks"`
+	Referers    map[string]uint64 `json:"referers"`
+	Os          map[string]uint64 `json:"os"`
+	Browsers    map[string]uint64 `json:"browsers"`
+	LastClickAt time.Time         `json:"last_click_at"`
+}
+
+// ShortUrlStore 短链存储，删除的短链只做标记，短码不会被再次使用
+type ShortUrlStore interface {
+	NextId() (uint64, error)
+	// Create 短码已存在（包括已删除的）时返回 ErrShortCodeExists
+	Create(link *ShortLink) error
+	// Get 包含已删除的短链，不存在时返回 ErrShortUrlNotFound
+	Get(code string) (*ShortLink, error)
+	Update(link *ShortLink) error
+	RecordClick(event *ClickEvent) error
+	Stats(code string) (*ClickStats, error)
+}
+
+type cachedLink struct {
+	link     *ShortLink
+	expireAt time.Time
+}
+
+// ShortUrlService 短链服务，查询时先读缓存，未命中再读存储
+type ShortUrlService struct {
+	BaseUrl string
+	Store   ShortUrlStore
+	// CacheTTL 本地缓存时间，0 表示不缓存。本地缓存只在当前进程内，修改时也只删除当前进程的缓存，
+	// 多实例部署时其他实例禁用、删除短链或修改过期时间后，本实例最多 CacheTTL 后才能看到，需要及时生效时使用 SharedCache
+	CacheTTL  time.Duration
+	CacheSize int
+	// SharedCache 多实例共享的 redis 缓存，设置后代替本地缓存，修改短链时删除缓存并通过 pub/sub 通知所有实例
+	SharedCache *redisc.Cache[ShortLink]
+	Encode      func(id uint64) (string, error)
+
+	lock  sync.RWMutex
+	cache map[string]*cachedLink
+}
+
+func NewShortUrlService(baseUrl string, store ShortUrlStore) *ShortUrlService {
+	//use example:
+	//svc := web.NewShortUrlService("http://127.0.0.1:8080/d/", web.NewRedisShortUrlStore(rdb, ""))
+	//link, shortUrl, err := svc.Shorten("https://example.com/a/very/long/url", "", 24*time.Hour)
+	//server.GET("/d/:code", svc.RedirectHandler("code"))
+	//svc.SharedCache, err = redisc.NewCache[web.ShortLink](rdb, "cache:short_url:", redisc.WithNegativeTTL(time.Minute), redisc.WithLocalCache(10000, time.Minute))
+	return &ShortUrlService{
+		BaseUrl:   baseUrl,
+		Store:     store,
+		CacheTTL:  time.Minute,
+		CacheSize: 10000,
+		Encode: func(id uint64) (string, error) {
+			return Int2Char(id, 62)
+		},
+		cache: make(map[string]*cachedLink),
+	}
+}
+
+// Shorten 生成短链，alias 不为空时使用自定义短码，ttl 为 0 表示永不过期
+func (s *ShortUrlService) Shorten(longUrl, alias string, ttl time.Duration) (*ShortLink, string, error) {
+	if !strings.HasPrefix(longUrl, "http://") && !strings.HasPrefix(longUrl, "https://") {
+		return nil, "", ErrLongUrl
+	}
+	link := &ShortLink{LongUrl: longUrl, CreatedAt: time.Now()}
+	if ttl > 0 {
+		link.ExpiresAt = link.CreatedAt.Add(ttl)
+	}
+	if alias != "" {
+		if !aliasReg.MatchString(alias) {
+			return nil, "", ErrShortAlias
+		}
+		link.Code = alias
+		link.Alias = true
+		if err := s.Store.Create(link); err != nil {
+			return nil, "", err
+		}
+		if err := s.invalidate(link.Code); err != nil {
+			return nil, "", err
+		}
+		return link, s.BaseUrl + link.Code, nil
+	}
+	// 自动生成的短码可能与自定义短码冲突，冲突时换下一个 id
+	var err error
+	for i := 0; i < 3; i++ {
+		var id uint64
+		if id, err = s.Store.NextId(); err != nil {
+			return nil, "", err
+		}
+		if link.Code, err = s.Encode(id); err != nil {
+			return nil, "", err
+		}
+		if err = s.Store.Create(link); err == nil {
+			if err = s.invalidate(link.Code); err != nil {
+				return nil, "", err
+			}
+			return link, s.BaseUrl + link.Code, nil
+		} else if !errors.Is(err, ErrShortCodeExists) {
+			return nil, "", err
+		}
+	}
+	return nil, "", err
+}
+
+// Resolve 返回可用的短链，已删除、已禁用或已过期的短链返回对应的错误
+func (s *ShortUrlService) Resolve(code string) (*ShortLink, error) {
+	link, err := s.load(code)
+	if err != nil {
+		return nil, err
+	}
+	if link.Deleted {
+		return nil, ErrShortUrlNotFound
+	}
+	if link.Disabled {
+		return nil, ErrShortUrlDisabled
+	}
+	if link.Expired() {
+		return nil, ErrShortUrlExpired
+	}
+	return link, nil
+}
+
+// Info 返回短链信息，包括已禁用和已过期的
+func (s *ShortUrlService) Info(code string) (*ShortLink, error) {
+	link, err := s.Store.Get(code)
+	if err != nil {
+		return nil, err
+	}
+	if link.Deleted {
+		return nil, ErrShortUrlNotFound
+	}
+	return link, nil
+}
+
+func (s *ShortUrlService) SetDisabled(code string, disabled bool) error {
+	return s.modify(code, func(link *ShortLink) {
+		link.Disabled = disabled
+	})
+}
+
+// SetExpiresAt 修改过期时间，零值表示永不过期
+func (s *ShortUrlService) SetExpiresAt(code string, expiresAt time.Time) error {
+	return s.modify(code, func(link *ShortLink) {
+		link.ExpiresAt = expiresAt
+	})
+}
+
+// Delete 删除短链，短码保留不再分配，避免旧的短链被指向新的地址
+func (s *ShortUrlService) Delete(code string) error {
+	return s.modify(code, func(link *ShortLink) {
+		link.Deleted = true
+	})
+}
+
+// Click 解析短链并记录访问，记录失败不影响跳转
+func (s *ShortUrlService) Click(code string, r *http.Request) (*ShortLink, error) {
+	link, err := s.Resolve(code)
+	if err != nil {
+		return nil, err
+	}
+	userAgent := r.UserAgent()
+	ip := r.Header.Get("X-Real-IP")
+	if ip == "" {
+		ip = r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			ip = host
+		}
+	}
+	_ = s.Store.RecordClick(&ClickEvent{
+		Code:      code,
+		Referer:   r.Referer(),
+		UserAgent: userAgent,
+		Os:        httpc.GetOsName(userAgent),
+		Browser:   httpc.GetBrowserName(userAgent),
+		Ip:        ip,
+		Time:      time.Now(),
+	})
+	return link, nil
+}
+
+func (s *ShortUrlService) Stats(code string) (*ClickStats, error) {
+	if _, err := s.Info(code); err != nil {
+		return nil, err
+	}
+	return s.Store.Stats(code)
+}
+
+func (s *ShortUrlService) modify(code string, fn func(link *ShortLink)) error {
+	link, err := s.Store.Get(code)
+	if err != nil {
+		return err
+	}
+	if link.Deleted {
+		return ErrShortUrlNotFound
+	}
+	fn(link)
+	if err = s.Store.Update(link); err != nil {
+		return err
+	}
+	return s.invalidate(code)
+}
+
+// invalidate 删除短码的缓存，SharedCache 会缓存短码不存在的结果，所以创建后也需要删除
+func (s *ShortUrlService) invalidate(code string) error {
+	if s.SharedCache != nil {
+		return s.SharedCache.Delete(code)
+	}
+	s.lock.Lock()
+	delete(s.cache, code)
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *ShortUrlService) load(code string) (*ShortLink, error) {
+	if s.SharedCache != nil {
+		link, err := s.SharedCache.GetOrLoad(code, func() (ShortLink, error) {
+			link, err := s.Store.Get(code)
+			if errors.Is(err, ErrShortUrlNotFound) {
+				return ShortLink{}, redisc.ErrNotFound
+			}
+			if err != nil {
+				return ShortLink{}, err
+			}
+			return *link, nil
+		})
+		if errors.Is(err, redisc.ErrNotFound) {
+			return nil, ErrShortUrlNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &link, nil
+	}
+	if s.CacheTTL <= 0 {
+		return s.Store.Get(code)
+	}
+	now := time.Now()
+	s.lock.RLock()
+	item, ok := s.cache[code]
+	s.lock.RUnlock()
+	if ok && now.Before(item.expireAt) {
+		return item.link, nil
+	}
+	link, err := s.Store.Get(code)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	if len(s.cache) >= s.CacheSize {
+		for k, v := range s.cache {
+			if now.After(v.expireAt) {
+				delete(s.cache, k)
+			}
+		}
+		// 仍然超过容量时清空，避免无限增长
+		if len(s.cache) >= s.CacheSize {
+			s.cache = make(map[string]*cachedLink)
+		}
+	}
+	s.cache[code] = &cachedLink{link: link, expireAt: now.Add(s.CacheTTL)}
+	s.lock.Unlock()
+	return link, nil
+}
@@ -0,0 +1,192 @@
+package web
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/AbnerEarl/goutils/machine"
+)
+
+var (
+	ErrSnowflakeBits     = errors.New("the sum of timestamp, worker and sequence bits must not exceed 63")
+	ErrWorkerIdRange     = errors.New("the worker id exceeds the range of worker bits")
+	ErrClockBackwards    = errors.New("the clock moved backwards beyond the tolerance")
+	ErrTimestampOverflow = errors.New("the timestamp exceeds the range of timestamp bits")
+	ErrWorkerLeaseLost   = errors.New("the worker id lease has been lost")
+)
+
+// DefaultSnowflakeEpoch 2024-01-01 00:00:00 UTC
+var DefaultSnowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type SnowflakeOption func(s *Snowflake)
+
+// WithSnowflakeBits 时间戳、机器号、序列号的位数，总和不超过 63，默认 41/10/12
+func WithSnowflakeBits(timeBits, workerBits, seqBits uint) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.timeBits, s.workerBits, s.seqBits = timeBits, workerBits, seqBits
+	}
+}
+
+// WithSnowflakeEpoch 时间戳的起始时间，生成 id 后不能再修改
+func WithSnowflakeEpoch(epoch time.Time) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.epoch = epoch
+	}
+}
+
+// WithTimeUnit 时间戳的单位，默认毫秒
+func WithTimeUnit(unit time.Duration) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.unit = unit
+	}
+}
+
+// WithWorkerId 指定机器号
+func WithWorkerId(workerId uint64) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.workerId = workerId
+	}
+}
+
+// WithMachineWorkerId 使用 machine.LinuxMachineMustID 计算机器号，机器较多时可能重复，建议使用 WithWorkerLease
+func WithMachineWorkerId() SnowflakeOption {
+	return func(s *Snowflake) {
+		sum := md5.Sum([]byte(machine.LinuxMachineMustID()))
+		s.workerId = binary.BigEndian.Uint64(sum[:8])
+		s.hashWorker = true
+	}
+}
+
+// WithWorkerLease 使用从 redis 租用的机器号，租约丢失后不再生成 id
+func WithWorkerLease(lease *WorkerLease) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.workerId = lease.Id()
+		s.lease = lease
+	}
+}
+
+// WithMaxBackwards 时钟回拨不超过该值时等待时钟追上，超过时返回 ErrClockBackwards，默认 5 秒
+func WithMaxBackwards(d time.Duration) SnowflakeOption {
+	return func(s *Snowflake) {
+		s.maxBackwards = d
+	}
+}
+
+type SnowflakeId struct {
+	Time     time.Time `json:"time"`
+	WorkerId uint64    `json:"worker_id"`
+	Sequence uint64    `json:"sequence"`
+}
+
+// Snowflake 本地生成按时间递增的 id，状态保存在一个 uint64 中，通过 CAS 更新，不需要加锁
+type Snowflake struct {
+	timeBits     uint
+	workerBits   uint
+	seqBits      uint
+	epoch        time.Time
+	unit         time.Duration
+	workerId     uint64
+	hashWorker   bool
+	lease        *WorkerLease
+	maxBackwards time.Duration
+	// 高位为上次的时间戳，低 seqBits 位为序列号
+	state uint64
+}
+
+func NewSnowflake(opts ...SnowflakeOption) (*Snowflake, error) {
+	//use example:
+	//lease, err := web.LeaseWorkerId(rdb, "snowflake:", 10, 30*time.Second)
+	//sf, err := web.NewSnowflake(web.WithWorkerLease(lease))
+	//id, err := sf.NextId()
+	//info := sf.Decode(id)
+	s := &Snowflake{
+		timeBits:     41,
+		workerBits:   10,
+		seqBits:      12,
+		epoch:        DefaultSnowflakeEpoch,
+		unit:         time.Millisecond,
+		maxBackwards: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.timeBits == 0 || s.seqBits == 0 || s.timeBits+s.workerBits+s.seqBits > 63 {
+		return nil, ErrSnowflakeBits
+	}
+	if s.unit <= 0 {
+		s.unit = time.Millisecond
+	}
+	if s.hashWorker {
+		s.workerId &= 1<<s.workerBits - 1
+	}
+	if s.workerId >= 1<<s.workerBits {
+		return nil, ErrWorkerIdRange
+	}
+	return s, nil
+}
+
+func (s *Snowflake) WorkerId() uint64 {
+	return s.workerId
+}
+
+func (s *Snowflake) NextId() (uint64, error) {
+	if s.lease != nil && s.lease.Lost() {
+		return 0, ErrWorkerLeaseLost
+	}
+	seqMask := uint64(1)<<s.seqBits - 1
+	for {
+		old := atomic.LoadUint64(&s.state)
+		last, seq := old>>s.seqBits, old&seqMask
+		now, err := s.timestamp()
+		if err != nil {
+			return 0, err
+		}
+		if now < last {
+			backwards := time.Duration(last-now) * s.unit
+			if backwards > s.maxBackwards {
+				return 0, fmt.Errorf("%w: %v", ErrClockBackwards, backwards)
+			}
+			time.Sleep(backwards)
+			continue
+		}
+		var next uint64
+		if now > last {
+			next = now << s.seqBits
+		} else if seq < seqMask {
+			next = old + 1
+		} else {
+			// 当前时间单位内的序列号已用完，等待下一个时间单位
+			runtime.Gosched()
+			continue
+		}
+		if atomic.CompareAndSwapUint64(&s.state, old, next) {
+			return next>>s.seqBits<<(s.workerBits+s.seqBits) | s.workerId<<s.seqBits | next&seqMask, nil
+		}
+	}
+}
+
+func (s *Snowflake) Decode(id uint64) SnowflakeId {
+	ts := id >> (s.workerBits + s.seqBits)
+	return SnowflakeId{
+		Time:     s.epoch.Add(time.Duration(ts) * s.unit),
+		WorkerId: id >> s.seqBits & (1<<s.workerBits - 1),
+		Sequence: id & (1<<s.seqBits - 1),
+	}
+}
+
+func (s *Snowflake) timestamp() (uint64, error) {
+	elapsed := time.Since(s.epoch)
+	if elapsed < 0 {
+		return 0, ErrClockBackwards
+	}
+	ts := uint64(elapsed / s.unit)
+	if ts >= 1<<s.timeBits {
+		return 0, ErrTimestampOverflow
+	}
+	return ts, nil
+}
@@ -0,0 +1,236 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+)
+
+var ErrNoWorkerId = errors.New("all worker ids are in use")
+
+var (
+	leaseAcquireScript = redisc.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+	leaseRenewScript = redisc.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+	leaseReleaseScript = redisc.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+)
+
+type redisEvaluator interface {
+	RdbEvalScript(script *redisc.Script, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// WorkerLease 从 redis 租用的机器号，后台按 ttl/3 续期，续期失败且超过 ttl 后视为丢失
+type WorkerLease struct {
+	cli     redisEvaluator
+	key     string
+	token   string
+	id      uint64
+	ttl     time.Duration
+	lost    int32
+	expires int64
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// LeaseWorkerId 从 [0, 2^bits) 中随机选择起点依次尝试占用，每个机器号对应一个 key，兼容集群模式
+func LeaseWorkerId(cli *redisc.RedisCli, prefix string, bits uint, ttl time.Duration) (*WorkerLease, error) {
+	return leaseWorkerId(cli, prefix, bits, ttl)
+}
+
+func LeaseWorkerIdByClu(cli *redisc.RedisClusterCli, prefix string, bits uint, ttl time.Duration) (*WorkerLease, error) {
+	return leaseWorkerId(cli, prefix, bits, ttl)
+}
+
+func leaseWorkerId(cli redisEvaluator, prefix string, bits uint, ttl time.Duration) (*WorkerLease, error) {
+	if bits == 0 || bits > 20 {
+		return nil, ErrWorkerIdRange
+	}
+	if prefix == "" {
+		prefix = "snowflake:worker:"
+	}
+	if ttl < 3*time.Second {
+		ttl = 3 * time.Second
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(nonce)
+	total := uint64(1) << bits
+	start, err := rand.Int(rand.Reader, new(big.Int).SetUint64(total))
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < total; i++ {
+		id := (start.Uint64() + i) % total
+		key := prefix + strconv.FormatUint(id, 10)
+		acquiredAt := time.Now()
+		reply, err := cli.RdbEvalScript(leaseAcquireScript, []string{key}, token, ttl.Milliseconds())
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := reply.(int64); n == 1 {
+			lease := &WorkerLease{
+				cli:     cli,
+				key:     key,
+				token:   token,
+				id:      id,
+				ttl:     ttl,
+				expires: acquiredAt.Add(ttl).UnixNano(),
+				stop:    make(chan struct{}),
+			}
+			go lease.keepalive()
+			return lease, nil
+		}
+	}
+	return nil, ErrNoWorkerId
+}
+
+func (l *WorkerLease) Id() uint64 {
+	return l.id
+}
+
+// Lost 续期被拒绝（key 已被其他实例占用），或者超过 ttl 没有续期成功
+func (l *WorkerLease) Lost() bool {
+	return atomic.LoadInt32(&l.lost) == 1 || time.Now().UnixNano() > atomic.LoadInt64(&l.expires)
+}
+
+// Close 停止续期并释放机器号
+func (l *WorkerLease) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.stop)
+		atomic.StoreInt32(&l.lost, 1)
+		_, err = l.cli.RdbEvalScript(leaseReleaseScript, []string{l.key}, l.token)
+	})
+	return err
+}
+
+func (l *WorkerLease) keepalive() {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			renewAt := time.Now()
+			reply, err := l.cli.RdbEvalScript(leaseRenewScript, []string{l.key}, l.token, l.ttl.Milliseconds())
+			if err != nil {
+				continue
+			}
+			if n, _ := reply.(int64); n == 1 {
+				atomic.StoreInt64(&l.expires, renewAt.Add(l.ttl).UnixNano())
+			} else {
+				atomic.StoreInt32(&l.lost, 1)
+				return
+			}
+		}
+	}
+}
+
+type incrByClient interface {
+	RdbIncrBy(key string, value int64) (int64, error)
+}
+
+// SegmentAllocator 每次用 RdbIncrBy 从 redis 预留一段 id，在本地依次分配，
+// 当前号段剩余不足 20% 时在后台预取下一段，id 全局唯一且趋势递增，重启后未用完的号段会被跳过
+type SegmentAllocator struct {
+	cli  incrByClient
+	key  string
+	step int64
+
+	lock     sync.Mutex
+	cur      uint64
+	end      uint64
+	next     [2]uint64 // 预取的号段 [start, end]
+	hasNext  bool
+	fetching bool
+	err      error
+}
+
+// NewSegmentAllocator cli 可以是 *redisc.RedisCli 或 *redisc.RedisClusterCli
+func NewSegmentAllocator(cli incrByClient, key string, step int64) *SegmentAllocator {
+	//use example:
+	//alloc := web.NewSegmentAllocator(rdb, "id:order", 1000)
+	//id, err := alloc.NextId()
+	if key == "" {
+		key = AUTO_ID_GENERATOR_COUNTER_KEY
+	}
+	if step < 1 {
+		step = 1000
+	}
+	return &SegmentAllocator{cli: cli, key: key, step: step}
+}
+
+func (a *SegmentAllocator) NextId() (uint64, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.cur >= a.end {
+		if a.hasNext {
+			a.cur, a.end = a.next[0], a.next[1]+1
+			a.hasNext = false
+		} else {
+			start, end, err := a.fetch()
+			if err != nil {
+				return 0, err
+			}
+			a.cur, a.end = start, end+1
+		}
+	}
+	id := a.cur
+	a.cur++
+	if !a.hasNext && !a.fetching && (a.end-a.cur)*5 < uint64(a.step) {
+		a.fetching = true
+		go a.prefetch()
+	}
+	return id, nil
+}
+
+func (a *SegmentAllocator) prefetch() {
+	start, end, err := a.fetch()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.fetching = false
+	a.err = err
+	if err == nil {
+		a.next = [2]uint64{start, end}
+		a.hasNext = true
+	}
+}
+
+// LastError 最近一次后台预取的错误
+func (a *SegmentAllocator) LastError() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.err
+}
+
+func (a *SegmentAllocator) fetch() (uint64, uint64, error) {
+	end, err := a.cli.RdbIncrBy(a.key, a.step)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(end - a.step + 1), uint64(end), nil
+}
@@ -0,0 +1,156 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAttestation       = errors.New("the attestation verification failed")
+	ErrAttestationFormat = errors.New("unsupported attestation format")
+)
+
+// 证明类型
+const (
+	AttestationNone  = "none"
+	AttestationSelf  = "self"
+	AttestationBasic = "basic"
+)
+
+// oidAAGUID 证书中的 id-fido-gen-ce-aaguid 扩展
+var oidAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// AttestationObject 注册时认证器返回的 attestationObject
+type AttestationObject struct {
+	Format   string
+	AttStmt  map[interface{}]interface{}
+	AuthData *AuthenticatorData
+}
+
+// ParseAttestationObject 解析 CBOR 编码的 attestationObject
+func ParseAttestationObject(data []byte) (*AttestationObject, error) {
+	v, rest, err := cborDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrCbor)
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrCbor)
+	}
+	format, _ := m["fmt"].(string)
+	stmt, _ := m["attStmt"].(map[interface{}]interface{})
+	raw, _ := m["authData"].([]byte)
+	if format == "" || stmt == nil || raw == nil {
+		return nil, fmt.Errorf("%w: missing fmt, attStmt or authData", ErrCbor)
+	}
+	authData, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if authData.Flags&FlagAttestedData == 0 {
+		return nil, fmt.Errorf("%w: missing attested credential data", ErrAuthenticatorData)
+	}
+	return &AttestationObject{Format: format, AttStmt: stmt, AuthData: authData}, nil
+}
+
+// Verify 按格式验证证明语句，支持 none 和 packed，返回证明类型和证书链，
+// 证书链是否可信（根证书、FIDO MDS）由调用方根据业务决定
+func (o *AttestationObject) Verify(clientDataHash []byte) (string, []*x509.Certificate, error) {
+	switch o.Format {
+	case "none":
+		if len(o.AttStmt) != 0 {
+			return "", nil, fmt.Errorf("%w: none attestation must have an empty statement", ErrAttestation)
+		}
+		return AttestationNone, nil, nil
+	case "packed":
+		return o.verifyPacked(clientDataHash)
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrAttestationFormat, o.Format)
+}
+
+func (o *AttestationObject) verifyPacked(clientDataHash []byte) (string, []*x509.Certificate, error) {
+	alg, ok := cborInt(o.AttStmt, "alg")
+	if !ok {
+		return "", nil, fmt.Errorf("%w: missing alg", ErrAttestation)
+	}
+	sig, ok := cborBytes(o.AttStmt, "sig")
+	if !ok {
+		return "", nil, fmt.Errorf("%w: missing sig", ErrAttestation)
+	}
+	signed := append(append([]byte{}, o.AuthData.Raw...), clientDataHash...)
+	x5c, hasX5c := o.AttStmt["x5c"]
+	if !hasX5c {
+		// 自证明：使用凭证私钥签名
+		key, err := ParsePublicKey(o.AuthData.PublicKey)
+		if err != nil {
+			return "", nil, err
+		}
+		if COSEAlgorithm(alg) != key.Algorithm {
+			return "", nil, fmt.Errorf("%w: alg does not match the credential public key", ErrAttestation)
+		}
+		if err = key.Verify(signed, sig); err != nil {
+			return "", nil, err
+		}
+		return AttestationSelf, nil, nil
+	}
+	items, ok := x5c.([]interface{})
+	if !ok || len(items) == 0 {
+		return "", nil, fmt.Errorf("%w: invalid x5c", ErrAttestation)
+	}
+	certs := make([]*x509.Certificate, 0, len(items))
+	for _, item := range items {
+		der, ok := item.([]byte)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: invalid x5c", ErrAttestation)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: %v", ErrAttestation, err)
+		}
+		certs = append(certs, cert)
+	}
+	sigAlg, ok := COSEAlgorithm(alg).x509()
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrAlgorithm, COSEAlgorithm(alg))
+	}
+	if err := certs[0].CheckSignature(sigAlg, signed, sig); err != nil {
+		return "", nil, ErrSignature
+	}
+	if err := checkPackedCert(certs[0], o.AuthData.AAGUID); err != nil {
+		return "", nil, err
+	}
+	return AttestationBasic, certs, nil
+}
+
+// checkPackedCert 证书要求见 WebAuthn §8.2.1
+func checkPackedCert(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return fmt.Errorf("%w: certificate version must be 3", ErrAttestation)
+	}
+	subject := cert.Subject
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || subject.CommonName == "" {
+		return fmt.Errorf("%w: certificate subject is incomplete", ErrAttestation)
+	}
+	if len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return fmt.Errorf("%w: certificate OU must be Authenticator Attestation", ErrAttestation)
+	}
+	if cert.IsCA {
+		return fmt.Errorf("%w: certificate must not be a CA", ErrAttestation)
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidAAGUID) {
+			continue
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(ext.Value, &value); err != nil || !bytes.Equal(value, aaguid) {
+			return fmt.Errorf("%w: certificate aaguid does not match", ErrAttestation)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrAuthenticatorData = errors.New("invalid authenticator data")
+
+// 认证器数据标志位
+const (
+	FlagUserPresent    byte = 0x01
+	FlagUserVerified   byte = 0x04
+	FlagBackupEligible byte = 0x08
+	FlagBackupState    byte = 0x10
+	FlagAttestedData   byte = 0x40
+	FlagExtensionData  byte = 0x80
+)
+
+// AuthenticatorData 认证器数据，结构为 rpIdHash(32) | flags(1) | signCount(4) | attestedCredentialData | extensions
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// 以下字段只在注册时存在（FlagAttestedData）
+	AAGUID       []byte
+	CredentialId []byte
+	// PublicKey COSE_Key 原始数据
+	PublicKey  []byte
+	Extensions map[interface{}]interface{}
+	Raw        []byte
+}
+
+func (a *AuthenticatorData) UserPresent() bool {
+	return a.Flags&FlagUserPresent != 0
+}
+
+func (a *AuthenticatorData) UserVerified() bool {
+	return a.Flags&FlagUserVerified != 0
+}
+
+func (a *AuthenticatorData) BackupEligible() bool {
+	return a.Flags&FlagBackupEligible != 0
+}
+
+func (a *AuthenticatorData) BackupState() bool {
+	return a.Flags&FlagBackupState != 0
+}
+
+// ParseAuthenticatorData 解析认证器数据，COSE 公钥的长度需要通过 CBOR 解码得到
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: too short", ErrAuthenticatorData)
+	}
+	a := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+		Raw:       data,
+	}
+	rest := data[37:]
+	if a.Flags&FlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrAuthenticatorData)
+		}
+		a.AAGUID = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if n == 0 || n > 1023 || len(rest) < n {
+			return nil, fmt.Errorf("%w: invalid credential id length", ErrAuthenticatorData)
+		}
+		a.CredentialId = rest[:n]
+		rest = rest[n:]
+		_, after, err := cborDecode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAuthenticatorData, err)
+		}
+		a.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if a.Flags&FlagExtensionData != 0 {
+		v, after, err := cborDecode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAuthenticatorData, err)
+		}
+		ext, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: extensions is not a map", ErrAuthenticatorData)
+		}
+		a.Extensions = ext
+		rest = after
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrAuthenticatorData)
+	}
+	return a, nil
+}
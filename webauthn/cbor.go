@@ -0,0 +1,232 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrCbor = errors.New("invalid cbor data")
+
+// cborMaxDepth 防止恶意数据嵌套过深
+const cborMaxDepth = 16
+
+// cborDecode 解码 WebAuthn 用到的 CBOR 子集（RFC 8949）：整数、字节串、文本、数组、映射、布尔和 null，
+// 整数统一返回 int64，映射返回 map[interface{}]interface{}，同时返回未解码的剩余数据
+func cborDecode(data []byte) (interface{}, []byte, error) {
+	return cborDecodeItem(data, 0)
+}
+
+func cborDecodeItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: nesting too deep", ErrCbor)
+	}
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end of data", ErrCbor)
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data[1:], nil
+		case 21:
+			return true, data[1:], nil
+		case 22, 23:
+			return nil, data[1:], nil
+		}
+		return nil, nil, fmt.Errorf("%w: unsupported simple value %d", ErrCbor, info)
+	}
+	n, rest, err := cborArgument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrCbor)
+		}
+		return int64(n), rest, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrCbor)
+		}
+		return -1 - int64(n), rest, nil
+	case 2, 3:
+		if n > uint64(len(rest)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of data", ErrCbor)
+		}
+		if major == 2 {
+			return append([]byte(nil), rest[:n]...), rest[n:], nil
+		}
+		return string(rest[:n]), rest[n:], nil
+	case 4:
+		if n > uint64(len(rest)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of data", ErrCbor)
+		}
+		items := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var item interface{}
+			if item, rest, err = cborDecodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rest, nil
+	case 5:
+		if n > uint64(len(rest)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end of data", ErrCbor)
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			var key, val interface{}
+			if key, rest, err = cborDecodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key type", ErrCbor)
+			}
+			if _, ok := m[key]; ok {
+				return nil, nil, fmt.Errorf("%w: duplicate map key", ErrCbor)
+			}
+			if val, rest, err = cborDecodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			m[key] = val
+		}
+		return m, rest, nil
+	}
+	return nil, nil, fmt.Errorf("%w: unsupported major type %d", ErrCbor, major)
+}
+
+// cborArgument 读取头部的长度或数值，不支持不定长编码
+func cborArgument(data []byte) (uint64, []byte, error) {
+	info := data[0] & 0x1f
+	data = data[1:]
+	size := 0
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, fmt.Errorf("%w: indefinite length is not supported", ErrCbor)
+	}
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: unexpected end of data", ErrCbor)
+	}
+	var n uint64
+	switch size {
+	case 1:
+		n = uint64(data[0])
+	case 2:
+		n = uint64(binary.BigEndian.Uint16(data))
+	case 4:
+		n = uint64(binary.BigEndian.Uint32(data))
+	case 8:
+		n = binary.BigEndian.Uint64(data)
+	}
+	return n, data[size:], nil
+}
+
+// cborPair 有序的映射项，编码时按顺序输出，用于生成确定的测试数据
+type cborPair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// cborEncode 编码 cborDecode 支持的类型，映射使用 []cborPair 保持顺序
+func cborEncode(v interface{}) ([]byte, error) {
+	return cborAppend(nil, v)
+}
+
+func cborAppend(buf []byte, v interface{}) ([]byte, error) {
+	var err error
+	switch val := v.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if val {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case int:
+		return cborAppend(buf, int64(val))
+	case int64:
+		if val < 0 {
+			return cborAppendHead(buf, 1, uint64(-1-val)), nil
+		}
+		return cborAppendHead(buf, 0, uint64(val)), nil
+	case uint32:
+		return cborAppendHead(buf, 0, uint64(val)), nil
+	case []byte:
+		return append(cborAppendHead(buf, 2, uint64(len(val))), val...), nil
+	case string:
+		return append(cborAppendHead(buf, 3, uint64(len(val))), val...), nil
+	case []interface{}:
+		buf = cborAppendHead(buf, 4, uint64(len(val)))
+		for _, item := range val {
+			if buf, err = cborAppend(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case []cborPair:
+		buf = cborAppendHead(buf, 5, uint64(len(val)))
+		for _, pair := range val {
+			if buf, err = cborAppend(buf, pair.Key); err != nil {
+				return nil, err
+			}
+			if buf, err = cborAppend(buf, pair.Value); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported type %T", ErrCbor, v)
+}
+
+func cborAppendHead(buf []byte, major byte, n uint64) []byte {
+	head := major << 5
+	switch {
+	case n < 24:
+		return append(buf, head|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, head|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(buf, head|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(buf, head|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return append(append(buf, head|27), b...)
+}
+
+// cborInt 从解码后的映射中读取整数，key 为 int 时按 int64 查找
+func cborInt(m map[interface{}]interface{}, key interface{}) (int64, bool) {
+	v, ok := m[cborKey(key)].(int64)
+	return v, ok
+}
+
+// cborBytes 从解码后的映射中读取字节串
+func cborBytes(m map[interface{}]interface{}, key interface{}) ([]byte, bool) {
+	v, ok := m[cborKey(key)].([]byte)
+	return v, ok
+}
+
+func cborKey(key interface{}) interface{} {
+	if k, ok := key.(int); ok {
+		return int64(k)
+	}
+	return key
+}
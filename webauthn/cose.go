@@ -0,0 +1,210 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	ErrPublicKey = errors.New("invalid or unsupported credential public key")
+	ErrAlgorithm = errors.New("unsupported cose algorithm")
+	ErrSignature = errors.New("the signature verification failed")
+)
+
+// COSEAlgorithm COSE 算法标识（RFC 8152），即 pubKeyCredParams 中的 alg
+type COSEAlgorithm int64
+
+const (
+	AlgES256 COSEAlgorithm = -7
+	AlgEdDSA COSEAlgorithm = -8
+	AlgES384 COSEAlgorithm = -35
+	AlgRS256 COSEAlgorithm = -257
+)
+
+func (a COSEAlgorithm) String() string {
+	switch a {
+	case AlgES256:
+		return "ES256"
+	case AlgEdDSA:
+		return "EdDSA"
+	case AlgES384:
+		return "ES384"
+	case AlgRS256:
+		return "RS256"
+	}
+	return fmt.Sprintf("COSEAlgorithm(%d)", int64(a))
+}
+
+// x509 证书验签使用的算法
+func (a COSEAlgorithm) x509() (x509.SignatureAlgorithm, bool) {
+	switch a {
+	case AlgES256:
+		return x509.ECDSAWithSHA256, true
+	case AlgEdDSA:
+		return x509.PureEd25519, true
+	case AlgES384:
+		return x509.ECDSAWithSHA384, true
+	case AlgRS256:
+		return x509.SHA256WithRSA, true
+	}
+	return x509.UnknownSignatureAlgorithm, false
+}
+
+// COSE 密钥参数标签
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvP384    = 2
+	coseCrvEd25519 = 6
+)
+
+// PublicKey 凭证公钥，Key 为 *ecdsa.PublicKey、*rsa.PublicKey 或 ed25519.PublicKey
+type PublicKey struct {
+	Algorithm COSEAlgorithm
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey 解析 COSE_Key 格式的公钥，即 Credential.PublicKey 中保存的数据
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	v, rest, err := cborDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrPublicKey)
+	}
+	return parsePublicKey(v)
+}
+
+func parsePublicKey(v interface{}) (*PublicKey, error) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrPublicKey
+	}
+	kty, _ := cborInt(m, coseKty)
+	alg, ok := cborInt(m, coseAlg)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing alg", ErrPublicKey)
+	}
+	key := &PublicKey{Algorithm: COSEAlgorithm(alg)}
+	switch key.Algorithm {
+	case AlgES256, AlgES384:
+		crv, _ := cborInt(m, coseCrv)
+		x, _ := cborBytes(m, coseX)
+		y, _ := cborBytes(m, coseY)
+		curve, size := elliptic.P256(), 32
+		if key.Algorithm == AlgES384 {
+			curve, size = elliptic.P384(), 48
+		}
+		if kty != coseKtyEC2 || (key.Algorithm == AlgES256 && crv != coseCrvP256) ||
+			(key.Algorithm == AlgES384 && crv != coseCrvP384) || len(x) != size || len(y) != size {
+			return nil, ErrPublicKey
+		}
+		px, py := elliptic.Unmarshal(curve, append(append([]byte{4}, x...), y...))
+		if px == nil {
+			return nil, fmt.Errorf("%w: point is not on curve", ErrPublicKey)
+		}
+		key.Key = &ecdsa.PublicKey{Curve: curve, X: px, Y: py}
+	case AlgEdDSA:
+		crv, _ := cborInt(m, coseCrv)
+		x, _ := cborBytes(m, coseX)
+		if kty != coseKtyOKP || crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrPublicKey
+		}
+		key.Key = ed25519.PublicKey(x)
+	case AlgRS256:
+		n, _ := cborBytes(m, coseN)
+		e, _ := cborBytes(m, coseE)
+		if kty != coseKtyRSA || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrPublicKey
+		}
+		exp := new(big.Int).SetBytes(e)
+		key.Key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, key.Algorithm)
+	}
+	return key, nil
+}
+
+// Verify 验证签名，ECDSA 签名为 ASN.1 DER 格式
+func (k *PublicKey) Verify(data, sig []byte) error {
+	ok := false
+	switch key := k.Key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Algorithm == AlgES384 {
+			sum := sha512.Sum384(data)
+			ok = ecdsa.VerifyASN1(key, sum[:], sig)
+		} else {
+			sum := sha256.Sum256(data)
+			ok = ecdsa.VerifyASN1(key, sum[:], sig)
+		}
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(data)
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	default:
+		return ErrPublicKey
+	}
+	if !ok {
+		return ErrSignature
+	}
+	return nil
+}
+
+// MarshalPublicKey 把公钥编码为 COSE_Key，用于生成测试数据
+func MarshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		alg, crv, size := AlgES256, coseCrvP256, 32
+		switch key.Curve {
+		case elliptic.P256():
+		case elliptic.P384():
+			alg, crv, size = AlgES384, coseCrvP384, 48
+		default:
+			return nil, ErrPublicKey
+		}
+		return cborEncode([]cborPair{
+			{coseKty, coseKtyEC2},
+			{coseAlg, int64(alg)},
+			{coseCrv, crv},
+			{coseX, key.X.FillBytes(make([]byte, size))},
+			{coseY, key.Y.FillBytes(make([]byte, size))},
+		})
+	case ed25519.PublicKey:
+		return cborEncode([]cborPair{
+			{coseKty, coseKtyOKP},
+			{coseAlg, int64(AlgEdDSA)},
+			{coseCrv, coseCrvEd25519},
+			{coseX, []byte(key)},
+		})
+	case *rsa.PublicKey:
+		return cborEncode([]cborPair{
+			{coseKty, coseKtyRSA},
+			{coseAlg, int64(AlgRS256)},
+			{coseN, key.N.Bytes()},
+			{coseE, big.NewInt(int64(key.E)).Bytes()},
+		})
+	}
+	return nil, ErrPublicKey
+}
@@ -0,0 +1,179 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Fixture 录制的一次注册和登录，保存为 JSON 后可以离线回放。
+// Registration 和 Assertion 即 Finish 接口收到的 credential，挑战从 clientDataJSON 中取得，
+// 所以只要记录 rp id、origin、用户和两次请求体即可。
+// 只录制了登录时，PublicKey 为注册时保存的凭证公钥，回放前直接写入凭证存储
+type Fixture struct {
+	// Name 数据来源，如认证器型号
+	Name   string `json:"name"`
+	RPId   string `json:"rp_id"`
+	Origin string `json:"origin"`
+	UserId string `json:"user_id,omitempty"`
+	// UserHandle 用户 id 不是文本时使用 base64url 编码的 user handle 代替 UserId
+	UserHandle string `json:"user_handle,omitempty"`
+	UserName   string `json:"user_name,omitempty"`
+	// AttestationType 注册后期望的证明类型，为空时不校验
+	AttestationType string               `json:"attestation_type,omitempty"`
+	Registration    *AttestationResponse `json:"registration,omitempty"`
+	// PublicKey COSE 格式的凭证公钥，base64url 编码，没有 Registration 时使用
+	PublicKey string             `json:"public_key,omitempty"`
+	Assertion *AssertionResponse `json:"assertion,omitempty"`
+}
+
+// LoadFixture 读取 JSON 格式的 Fixture
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err = json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if f.Registration == nil && (f.Assertion == nil || f.PublicKey == "") {
+		return nil, fmt.Errorf("%s: the registration, or the assertion and public key are required", path)
+	}
+	if _, err = f.user(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f, nil
+}
+
+// RelyingParty 返回和录制时一致的依赖方，凭证保存在内存中
+func (f *Fixture) RelyingParty() *RelyingParty {
+	return NewRelyingParty(f.RPId, f.Name, []string{f.Origin}, NewMemoryCredentialStore())
+}
+
+// Replay 使用 FixedChallenge 依次回放注册和登录，返回最后一次仪式的凭证，
+// 只回放注册时使用 Register，只回放登录时使用 Login
+func (f *Fixture) Replay(rp *RelyingParty) (*Credential, error) {
+	var cred *Credential
+	var err error
+	if f.Registration != nil {
+		cred, err = f.Register(rp)
+	} else {
+		cred, err = f.Import(rp)
+	}
+	if err != nil || f.Assertion == nil {
+		return cred, err
+	}
+	return f.Login(rp)
+}
+
+// Register 回放注册：BeginRegistration 后把录制的响应交给 FinishRegistration
+func (f *Fixture) Register(rp *RelyingParty) (*Credential, error) {
+	if f.Registration == nil {
+		return nil, fmt.Errorf("%w: the fixture has no registration", ErrAttestation)
+	}
+	userId, err := f.user()
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := fixtureChallenge(f.Registration.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	defer func(c func() ([]byte, error)) { rp.Challenge = c }(rp.Challenge)
+	rp.Challenge = FixedChallenge(challenge)
+	_, sessionId, err := rp.BeginRegistration(User{Id: userId, Name: f.UserName})
+	if err != nil {
+		return nil, err
+	}
+	cred, err := rp.FinishRegistration(userId, sessionId, f.Registration)
+	if err != nil {
+		return nil, err
+	}
+	if f.AttestationType != "" && cred.AttestationType != f.AttestationType {
+		return nil, fmt.Errorf("%w: expect %s attestation, got %s", ErrAttestation, f.AttestationType, cred.AttestationType)
+	}
+	return cred, nil
+}
+
+// Import 把 PublicKey 和 Assertion 的凭证 id 作为已注册的凭证写入存储，用于只录制了登录的数据
+func (f *Fixture) Import(rp *RelyingParty) (*Credential, error) {
+	if f.Assertion == nil || f.PublicKey == "" {
+		return nil, fmt.Errorf("%w: the fixture has no public key", ErrPublicKey)
+	}
+	userId, err := f.user()
+	if err != nil {
+		return nil, err
+	}
+	id, err := decodeBase64URL(f.Assertion.RawId)
+	if err != nil || len(id) == 0 {
+		return nil, ErrCredentialNotAllowed
+	}
+	raw, err := decodeBase64URL(f.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPublicKey, err)
+	}
+	key, err := ParsePublicKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	cred := &Credential{
+		Id:        id,
+		UserId:    userId,
+		PublicKey: raw,
+		Algorithm: key.Algorithm,
+		CreatedAt: time.Now(),
+	}
+	if err = rp.Credentials.Create(cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// Login 回放登录：BeginLogin 后把录制的响应交给 FinishLogin，凭证需要已经注册
+func (f *Fixture) Login(rp *RelyingParty) (*Credential, error) {
+	if f.Assertion == nil {
+		return nil, fmt.Errorf("%w: the fixture has no assertion", ErrClientData)
+	}
+	userId, err := f.user()
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := fixtureChallenge(f.Assertion.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	defer func(c func() ([]byte, error)) { rp.Challenge = c }(rp.Challenge)
+	rp.Challenge = FixedChallenge(challenge)
+	_, sessionId, err := rp.BeginLogin(userId)
+	if err != nil {
+		return nil, err
+	}
+	return rp.FinishLogin(sessionId, f.Assertion)
+}
+
+// user 返回 UserId，设置了 UserHandle 时使用解码后的 user handle
+func (f *Fixture) user() (string, error) {
+	if f.UserHandle == "" {
+		return f.UserId, nil
+	}
+	handle, err := decodeBase64URL(f.UserHandle)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUserHandle, err)
+	}
+	return string(handle), nil
+}
+
+// fixtureChallenge 取得 clientDataJSON 中的挑战
+func fixtureChallenge(encoded string) (string, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrClientData, err)
+	}
+	var data clientData
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrClientData, err)
+	}
+	return data.Challenge, nil
+}
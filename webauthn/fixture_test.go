@@ -0,0 +1,293 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var (
+	update     = flag.Bool("update", false, "regenerate the fixtures in testdata with the virtual authenticator")
+	updateOnce sync.Once
+)
+
+// recordFixture 使用软件认证器完成一次注册和登录并记录下来
+func recordFixture(name, attestation string, signCount uint32) (*Fixture, error) {
+	auth, err := NewVirtualAuthenticator()
+	if err != nil {
+		return nil, err
+	}
+	auth.Attestation = attestation
+	auth.SignCount = signCount
+	f := &Fixture{Name: name, RPId: "example.com", Origin: "https://example.com", UserId: "user-1", UserName: "john@example.com"}
+	rp := f.RelyingParty()
+	opts, sessionId, err := rp.BeginRegistration(User{Id: f.UserId, Name: f.UserName})
+	if err != nil {
+		return nil, err
+	}
+	if f.Registration, err = auth.Register(opts, f.Origin); err != nil {
+		return nil, err
+	}
+	cred, err := rp.FinishRegistration(f.UserId, sessionId, f.Registration)
+	if err != nil {
+		return nil, err
+	}
+	f.AttestationType = cred.AttestationType
+	ropts, sessionId, err := rp.BeginLogin(f.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if f.Assertion, err = auth.Login(ropts, f.Origin); err != nil {
+		return nil, err
+	}
+	if _, err = rp.FinishLogin(sessionId, f.Assertion); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func updateFixtures(t *testing.T) {
+	fixtures := []struct {
+		file, name, attestation string
+		signCount               uint32
+	}{
+		{"virtual-none.json", "virtual authenticator, none attestation, no signature counter", "none", 0},
+		{"virtual-packed.json", "virtual authenticator, packed self attestation, ES256", "packed", 1},
+	}
+	for _, item := range fixtures {
+		f, err := recordFixture(item.name, item.attestation, item.signCount)
+		if err != nil {
+			t.Fatalf("%s: %v", item.file, err)
+		}
+		data, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(filepath.Join("testdata", item.file), append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func loadFixtures(t *testing.T) map[string]*Fixture {
+	if *update {
+		updateOnce.Do(func() { updateFixtures(t) })
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures in testdata, run go test -update")
+	}
+	fixtures := make(map[string]*Fixture, len(files))
+	for _, file := range files {
+		f, err := LoadFixture(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixtures[filepath.Base(file)] = f
+	}
+	return fixtures
+}
+
+// realFixtures 真实认证器录制的数据和期望的凭证算法
+var realFixtures = map[string]COSEAlgorithm{
+	"real-packed-x5c-es256.json":  AlgES256,
+	"real-packed-x5c-solo2.json":  AlgEdDSA,
+	"real-touchid-assertion.json": AlgES256,
+}
+
+func TestFixtureReplay(t *testing.T) {
+	fixtures := loadFixtures(t)
+	for file := range realFixtures {
+		if fixtures[file] == nil {
+			t.Errorf("missing testdata/%s", file)
+		}
+	}
+	for file, f := range fixtures {
+		cred, err := f.Replay(f.RelyingParty())
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		userId, _ := f.user()
+		if cred.UserId != userId {
+			t.Errorf("%s: user id %q, want %q", file, cred.UserId, userId)
+		}
+		if alg, ok := realFixtures[file]; ok && cred.Algorithm != alg {
+			t.Errorf("%s: algorithm %s, want %s", file, cred.Algorithm, alg)
+		}
+	}
+}
+
+func TestFixturePackedX5c(t *testing.T) {
+	for file, f := range loadFixtures(t) {
+		if f.Registration == nil || f.AttestationType != AttestationBasic {
+			continue
+		}
+		raw, err := decodeBase64URL(f.Registration.Response.AttestationObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clientDataJSON, err := decodeBase64URL(f.Registration.Response.ClientDataJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := sha256.Sum256(clientDataJSON)
+		att, err := ParseAttestationObject(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		attType, certs, err := att.Verify(hash[:])
+		if err != nil || attType != AttestationBasic || len(certs) == 0 {
+			t.Fatalf("%s: got %s, %d certificates, %v", file, attType, len(certs), err)
+		}
+		if err = checkPackedCert(certs[0], att.AuthData.AAGUID); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+		if hasAAGUIDExtension(certs[0]) {
+			if err = checkPackedCert(certs[0], make([]byte, 16)); !errors.Is(err, ErrAttestation) {
+				t.Errorf("%s: other aaguid: got %v, want %v", file, err, ErrAttestation)
+			}
+		}
+
+		// 其他客户端数据的签名
+		other := sha256.Sum256([]byte("other client data"))
+		if _, _, err = att.Verify(other[:]); !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: other client data: got %v, want %v", file, err, ErrSignature)
+		}
+		sig := append([]byte{}, att.AttStmt["sig"].([]byte)...)
+		sig[len(sig)/2] ^= 0xff
+		att.AttStmt["sig"] = sig
+		if _, _, err = att.Verify(hash[:]); !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: tampered signature: got %v, want %v", file, err, ErrSignature)
+		}
+	}
+}
+
+func hasAAGUIDExtension(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidAAGUID) {
+			return true
+		}
+	}
+	return false
+}
+
+// assertionCounter 取得断言中的签名计数器
+func assertionCounter(t *testing.T, f *Fixture) uint32 {
+	raw, err := decodeBase64URL(f.Assertion.Response.AuthenticatorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authData.SignCount
+}
+
+// registered 回放注册或导入公钥，使凭证处于登录前的状态
+func registered(t *testing.T, f *Fixture, rp *RelyingParty) *Credential {
+	var cred *Credential
+	var err error
+	if f.Registration != nil {
+		cred, err = f.Register(rp)
+	} else {
+		cred, err = f.Import(rp)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cred
+}
+
+func TestFixtureCounter(t *testing.T) {
+	for file, f := range loadFixtures(t) {
+		if f.Assertion == nil || assertionCounter(t, f) == 0 {
+			continue
+		}
+		counter := assertionCounter(t, f)
+
+		// 同一个断言再次登录，计数器没有增长
+		rp := f.RelyingParty()
+		cred, err := f.Replay(rp)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if cred.SignCount != counter {
+			t.Fatalf("%s: sign count %d, want %d", file, cred.SignCount, counter)
+		}
+		if _, err = f.Login(rp); !errors.Is(err, ErrCloneDetected) {
+			t.Errorf("%s: replayed assertion: got %v, want %v", file, err, ErrCloneDetected)
+		}
+
+		// 保存的计数器比断言中的大，计数器回退
+		rp = f.RelyingParty()
+		cred = registered(t, f, rp)
+		cred.SignCount = counter + 1
+		if err = rp.Credentials.Update(cred); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Login(rp); !errors.Is(err, ErrCloneDetected) {
+			t.Errorf("%s: counter went backwards: got %v, want %v", file, err, ErrCloneDetected)
+		}
+		stored, err := rp.Credentials.Get(cred.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stored.CloneWarning {
+			t.Errorf("%s: the credential is not marked as cloned", file)
+		}
+		// 标记后即使计数器正常也拒绝登录
+		stored.SignCount = 0
+		if err = rp.Credentials.Update(stored); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Login(rp); !errors.Is(err, ErrCloneDetected) {
+			t.Errorf("%s: cloned credential: got %v, want %v", file, err, ErrCloneDetected)
+		}
+	}
+}
+
+func TestFixtureReplayRejected(t *testing.T) {
+	for file, f := range loadFixtures(t) {
+		rp := f.RelyingParty()
+		rp.Origins = []string{"https://evil.example.com"}
+		if _, err := f.Replay(rp); !errors.Is(err, ErrOrigin) {
+			t.Errorf("%s: other origin: got %v, want %v", file, err, ErrOrigin)
+		}
+
+		rp = f.RelyingParty()
+		rp.Id = "evil.example.com"
+		if _, err := f.Replay(rp); !errors.Is(err, ErrRPIDHash) {
+			t.Errorf("%s: other rp id: got %v, want %v", file, err, ErrRPIDHash)
+		}
+
+		if f.Assertion == nil {
+			continue
+		}
+		rp = f.RelyingParty()
+		registered(t, f, rp)
+		tampered := *f
+		assertion := *f.Assertion
+		sig, err := base64.RawURLEncoding.DecodeString(assertion.Response.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig[len(sig)-1] ^= 0xff
+		assertion.Response.Signature = base64.RawURLEncoding.EncodeToString(sig)
+		tampered.Assertion = &assertion
+		if _, err = tampered.Login(rp); !errors.Is(err, ErrSignature) {
+			t.Errorf("%s: tampered signature: got %v, want %v", file, err, ErrSignature)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package webauthn
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AbnerEarl/goutils/gins"
+)
+
+type registerFinishReq struct {
+	SessionId  string              `json:"session_id"`
+	Credential AttestationResponse `json:"credential"`
+}
+
+type loginBeginReq struct {
+	UserId string `json:"user_id"`
+}
+
+type loginFinishReq struct {
+	SessionId  string            `json:"session_id"`
+	Credential AssertionResponse `json:"credential"`
+}
+
+// UserFunc 从请求中取得当前登录的用户，注册接口需要放在登录鉴权之后
+type UserFunc func(c *gins.Context) (*User, error)
+
+// RegisterBeginHandler 返回 session_id 和 public_key（CreationOptions）
+//
+//	server.POST("/api/v1/webauthn/register/begin", gins.TokenAuth(issuer), rp.RegisterBeginHandler(currentUser))
+func (rp *RelyingParty) RegisterBeginHandler(user UserFunc) gins.HandlerFunc {
+	return func(c *gins.Context) {
+		u, err := user(c)
+		if err != nil {
+			sendError(c, err)
+			return
+		}
+		opts, sessionId, err := rp.BeginRegistration(*u)
+		if err != nil {
+			sendError(c, err)
+			return
+		}
+		gins.SendResponse(c, nil, map[string]interface{}{"session_id": sessionId, "public_key": opts})
+	}
+}
+
+// RegisterFinishHandler 请求体为 {"session_id": "...", "credential": PublicKeyCredential.toJSON()}，返回保存的凭证
+//
+//	server.POST("/api/v1/webauthn/register/finish", gins.TokenAuth(issuer), rp.RegisterFinishHandler(currentUser))
+func (rp *RelyingParty) RegisterFinishHandler(user UserFunc) gins.HandlerFunc {
+	return func(c *gins.Context) {
+		u, err := user(c)
+		if err != nil {
+			sendError(c, err)
+			return
+		}
+		var req registerFinishReq
+		if err = c.ShouldBindJSON(&req); err != nil {
+			gins.SendResponse(c, gins.NewErr(gins.ParamError, err), nil)
+			return
+		}
+		cred, err := rp.FinishRegistration(u.Id, req.SessionId, &req.Credential)
+		if err != nil {
+			sendError(c, err)
+			return
+		}
+		gins.SendResponse(c, nil, cred)
+	}
+}
+
+// LoginBeginHandler 请求体可以为空，为空时使用通行密钥登录，也可以传入 {"user_id": "..."} 只允许该用户的凭证
+//
+//	server.POST("/api/v1/webauthn/login/begin", rp.LoginBeginHandler())
+func (rp *RelyingParty) LoginBeginHandler() gins.HandlerFunc {
+	return func(c *gins.Context) {
+		var req loginBeginReq
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				gins.SendResponse(c, gins.NewErr(gins.ParamError, err), nil)
+				return
+			}
+		}
+		opts, sessionId, err := rp.BeginLogin(req.UserId)
+		if err != nil {
+			sendError(c, err)
+			return
+		}
+		gins.SendResponse(c, nil, map[string]interface{}{"session_id": sessionId, "public_key": opts})
+	}
+}
+
+// LoginFinishHandler 验证成功后调用 success 签发登录凭证（如 jwt），success 为空时返回 user_id
+//
+//	server.POST("/api/v1/webauthn/login/finish", rp.LoginFinishHandler(func(c *gins.Context, cred *webauthn.Credential) {
+//		pair, err := issuer.IssuePair(cred.UserId)
+//		gins.SendResponse(c, err, pair)
+//	}))
+func (rp *RelyingParty) LoginFinishHandler(success func(c *gins.Context, cred *Credential)) gins.HandlerFunc {
+	return func(c *gins.Context) {
+		var req loginFinishReq
+		if err := c.ShouldBindJSON(&req); err != nil {
+			gins.SendResponse(c, gins.NewErr(gins.ParamError, err), nil)
+			return
+		}
+		cred, err := rp.FinishLogin(req.SessionId, &req.Credential)
+		if err != nil {
+			sendError(c, err)
+			return
+		}
+		if success != nil {
+			success(c, cred)
+			return
+		}
+		gins.SendResponse(c, nil, map[string]interface{}{"user_id": cred.UserId})
+	}
+}
+
+// verifyErrors 客户端数据校验失败的错误，其余错误按服务器内部错误处理
+var verifyErrors = []error{
+	ErrCbor, ErrPublicKey, ErrAlgorithm, ErrSignature, ErrAuthenticatorData, ErrAttestation, ErrAttestationFormat,
+	ErrClientData, ErrChallenge, ErrOrigin, ErrRPIDHash, ErrUserPresence, ErrUserVerification,
+	ErrCredentialNotAllowed, ErrUserId, ErrUserHandle, ErrSessionUser, ErrSessionNotFound,
+	ErrCredentialNotFound, ErrCredentialExists,
+}
+
+func sendError(c *gins.Context, err error) {
+	if errors.Is(err, ErrCloneDetected) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gins.Response{Errno: gins.NewErr(gins.ErrForbidden, err)})
+		return
+	}
+	for _, e := range verifyErrors {
+		if errors.Is(err, e) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gins.Response{Errno: gins.NewErr(gins.ParamError, err)})
+			return
+		}
+	}
+	if _, ok := err.(*gins.Errno); ok {
+		gins.SendResponse(c, err, nil)
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gins.Response{Errno: gins.NewErr(gins.InternalError, err)})
+}
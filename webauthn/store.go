@@ -0,0 +1,232 @@
+package webauthn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/AbnerEarl/goutils/redisc"
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrCredentialNotFound = errors.New("the credential does not exist")
+	ErrCredentialExists   = errors.New("the credential is already registered")
+	ErrSessionNotFound    = errors.New("the webauthn session does not exist or has expired")
+)
+
+// Credential 用户注册的凭证（通行密钥）
+type Credential struct {
+	Id              []byte        `json:"id"`
+	UserId          string        `json:"user_id"`
+	PublicKey       []byte        `json:"public_key"`
+	Algorithm       COSEAlgorithm `json:"algorithm"`
+	SignCount       uint32        `json:"sign_count"`
+	AAGUID          []byte        `json:"aaguid"`
+	Transports      []string      `json:"transports"`
+	AttestationType string        `json:"attestation_type"`
+	UserVerified    bool          `json:"user_verified"`
+	BackupEligible  bool          `json:"backup_eligible"`
+	BackupState     bool          `json:"backup_state"`
+	// CloneWarning 检测到签名计数器回退，凭证可能被克隆
+	CloneWarning bool       `json:"clone_warning"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// CredentialStore 保存用户的凭证
+type CredentialStore interface {
+	// Create 凭证 id 已存在时返回 ErrCredentialExists
+	Create(c *Credential) error
+	// Get 不存在时返回 ErrCredentialNotFound
+	Get(id []byte) (*Credential, error)
+	ListByUser(userId string) ([]*Credential, error)
+	// Update 登录后保存计数器、备份状态、克隆标记和使用时间
+	Update(c *Credential) error
+	Delete(id []byte) error
+}
+
+// SessionData 两次请求之间需要保存的仪式状态
+type SessionData struct {
+	// Type 为 webauthn.create 或 webauthn.get
+	Type             string    `json:"type"`
+	Challenge        string    `json:"challenge"`
+	UserId           string    `json:"user_id"`
+	AllowCredentials [][]byte  `json:"allow_credentials"`
+	UserVerification string    `json:"user_verification"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// SessionStore 保存仪式状态，每个状态只能取出一次，防止挑战被重复使用
+type SessionStore interface {
+	Save(id string, s *SessionData, ttl time.Duration) error
+	// Take 取出并删除，不存在或已过期时返回 ErrSessionNotFound
+	Take(id string) (*SessionData, error)
+}
+
+// MemoryCredentialStore 单机或测试使用的 CredentialStore
+type MemoryCredentialStore struct {
+	lock  sync.Mutex
+	creds []*Credential
+}
+
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{}
+}
+
+func (s *MemoryCredentialStore) Create(c *Credential) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.find(c.Id) >= 0 {
+		return ErrCredentialExists
+	}
+	cp := *c
+	s.creds = append(s.creds, &cp)
+	return nil
+}
+
+func (s *MemoryCredentialStore) Get(id []byte) (*Credential, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.find(id)
+	if i < 0 {
+		return nil, ErrCredentialNotFound
+	}
+	cp := *s.creds[i]
+	return &cp, nil
+}
+
+func (s *MemoryCredentialStore) ListByUser(userId string) ([]*Credential, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var list []*Credential
+	for _, c := range s.creds {
+		if c.UserId == userId {
+			cp := *c
+			list = append(list, &cp)
+		}
+	}
+	return list, nil
+}
+
+func (s *MemoryCredentialStore) Update(c *Credential) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.find(c.Id)
+	if i < 0 {
+		return ErrCredentialNotFound
+	}
+	cp := *c
+	s.creds[i] = &cp
+	return nil
+}
+
+func (s *MemoryCredentialStore) Delete(id []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if i := s.find(id); i >= 0 {
+		s.creds = append(s.creds[:i], s.creds[i+1:]...)
+	}
+	return nil
+}
+
+func (s *MemoryCredentialStore) find(id []byte) int {
+	for i, c := range s.creds {
+		if bytes.Equal(c.Id, id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// MemorySessionStore 单机使用的 SessionStore
+type MemorySessionStore struct {
+	lock     sync.Mutex
+	sessions map[string]*SessionData
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*SessionData)}
+}
+
+func (s *MemorySessionStore) Save(id string, data *SessionData, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for k, v := range s.sessions {
+		if now.After(v.ExpiresAt) {
+			delete(s.sessions, k)
+		}
+	}
+	cp := *data
+	if ttl > 0 {
+		cp.ExpiresAt = now.Add(ttl)
+	}
+	s.sessions[id] = &cp
+	return nil
+}
+
+func (s *MemorySessionStore) Take(id string) (*SessionData, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	delete(s.sessions, id)
+	if time.Now().After(data.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	return data, nil
+}
+
+// RedisSessionStore 多实例共享的 SessionStore
+type RedisSessionStore struct {
+	cli    redis.Cmdable
+	prefix string
+}
+
+// NewRedisSessionStore cli 可以是 *redisc.RedisCli、*redisc.RedisClusterCli 或 *redisc.UniversalClient
+func NewRedisSessionStore(cli redis.Cmdable, prefix string) *RedisSessionStore {
+	//use example:
+	//sessions := webauthn.NewRedisSessionStore(rdb, "webauthn:session:")
+	if prefix == "" {
+		prefix = "webauthn:session:"
+	}
+	return &RedisSessionStore{cli: cli, prefix: prefix}
+}
+
+func (s *RedisSessionStore) Save(id string, data *SessionData, ttl time.Duration) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	return s.cli.Set(ctx, s.prefix+id, value, ttl).Err()
+}
+
+func (s *RedisSessionStore) Take(id string) (*SessionData, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisc.CtxExpireTime)
+	defer cancel()
+	var get *redis.StringCmd
+	_, err := s.cli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, s.prefix+id)
+		pipe.Del(ctx, s.prefix+id)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &SessionData{}
+	if err = json.Unmarshal([]byte(get.Val()), data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
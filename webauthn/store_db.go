@@ -0,0 +1,151 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/AbnerEarl/goutils/dbs"
+)
+
+type WebauthnCredentialModel struct {
+	dbs.BaseModel
+	// CredentialHash 凭证 id 最长 1023 字节，使用 sha256 建唯一索引
+	CredentialHash  string     `json:"-" gorm:"column:credential_hash;type:char(64);uniqueIndex;comment:'凭证ID哈希'"`
+	CredentialId    string     `json:"credential_id" gorm:"column:credential_id;type:varchar(1400);comment:'凭证ID（base64url）'"`
+	UserId          string     `json:"user_id" gorm:"column:user_id;type:varchar(128);index;comment:'用户ID'"`
+	PublicKey       []byte     `json:"-" gorm:"column:public_key;comment:'COSE 公钥'"`
+	Algorithm       int64      `json:"algorithm" gorm:"column:algorithm;comment:'COSE 算法'"`
+	SignCount       uint32     `json:"sign_count" gorm:"column:sign_count;comment:'签名计数器'"`
+	AAGUID          string     `json:"aaguid" gorm:"column:aaguid;type:varchar(32);comment:'认证器型号'"`
+	Transports      string     `json:"transports" gorm:"column:transports;type:varchar(128);comment:'传输方式，逗号分隔'"`
+	AttestationType string     `json:"attestation_type" gorm:"column:attestation_type;type:varchar(16);comment:'证明类型'"`
+	UserVerified    bool       `json:"user_verified" gorm:"column:user_verified;comment:'注册时是否验证用户'"`
+	BackupEligible  bool       `json:"backup_eligible" gorm:"column:backup_eligible;comment:'是否可以同步备份'"`
+	BackupState     bool       `json:"backup_state" gorm:"column:backup_state;comment:'是否已同步备份'"`
+	CloneWarning    bool       `json:"clone_warning" gorm:"column:clone_warning;comment:'是否检测到克隆'"`
+	LastUsedAt      *time.Time `json:"last_used_at" gorm:"column:last_used_at;null;comment:'最后使用时间'"`
+}
+
+func (m *WebauthnCredentialModel) TableName() string {
+	return "webauthn_credential"
+}
+
+// DBCredentialStore 基于 dbs 的 CredentialStore
+type DBCredentialStore struct {
+	db *dbs.DB
+}
+
+func NewDBCredentialStore(db *dbs.DB) *DBCredentialStore {
+	//use example:
+	//db.Migration([]interface{}{&webauthn.WebauthnCredentialModel{}})
+	//store := webauthn.NewDBCredentialStore(db)
+	return &DBCredentialStore{db: db}
+}
+
+func (s *DBCredentialStore) Create(c *Credential) error {
+	var count int64
+	hash := credentialHash(c.Id)
+	if err := s.db.DB.Model(&WebauthnCredentialModel{}).Where("credential_hash = ?", hash).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCredentialExists
+	}
+	model := &WebauthnCredentialModel{CredentialHash: hash}
+	model.CreatedAt = c.CreatedAt
+	fillCredentialModel(model, c)
+	return s.db.DB.Create(model).Error
+}
+
+func (s *DBCredentialStore) Get(id []byte) (*Credential, error) {
+	model, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return model.credential(), nil
+}
+
+func (s *DBCredentialStore) ListByUser(userId string) ([]*Credential, error) {
+	var models []*WebauthnCredentialModel
+	if err := s.db.DB.Where("user_id = ?", userId).Order("id").Find(&models).Error; err != nil {
+		return nil, err
+	}
+	list := make([]*Credential, len(models))
+	for i, m := range models {
+		list[i] = m.credential()
+	}
+	return list, nil
+}
+
+func (s *DBCredentialStore) Update(c *Credential) error {
+	model, err := s.load(c.Id)
+	if err != nil {
+		return err
+	}
+	fillCredentialModel(model, c)
+	return s.db.DB.Save(model).Error
+}
+
+func (s *DBCredentialStore) Delete(id []byte) error {
+	return s.db.DB.Where("credential_hash = ?", credentialHash(id)).Delete(&WebauthnCredentialModel{}).Error
+}
+
+func (s *DBCredentialStore) load(id []byte) (*WebauthnCredentialModel, error) {
+	var models []*WebauthnCredentialModel
+	if err := s.db.DB.Where("credential_hash = ?", credentialHash(id)).Limit(1).Find(&models).Error; err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, ErrCredentialNotFound
+	}
+	return models[0], nil
+}
+
+func (m *WebauthnCredentialModel) credential() *Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(m.CredentialId)
+	aaguid, _ := hex.DecodeString(m.AAGUID)
+	var transports []string
+	if m.Transports != "" {
+		transports = strings.Split(m.Transports, ",")
+	}
+	return &Credential{
+		Id:              id,
+		UserId:          m.UserId,
+		PublicKey:       m.PublicKey,
+		Algorithm:       COSEAlgorithm(m.Algorithm),
+		SignCount:       m.SignCount,
+		AAGUID:          aaguid,
+		Transports:      transports,
+		AttestationType: m.AttestationType,
+		UserVerified:    m.UserVerified,
+		BackupEligible:  m.BackupEligible,
+		BackupState:     m.BackupState,
+		CloneWarning:    m.CloneWarning,
+		CreatedAt:       m.CreatedAt,
+		LastUsedAt:      m.LastUsedAt,
+	}
+}
+
+func fillCredentialModel(m *WebauthnCredentialModel, c *Credential) {
+	m.CredentialId = base64.RawURLEncoding.EncodeToString(c.Id)
+	m.UserId = c.UserId
+	m.PublicKey = c.PublicKey
+	m.Algorithm = int64(c.Algorithm)
+	m.SignCount = c.SignCount
+	m.AAGUID = hex.EncodeToString(c.AAGUID)
+	m.Transports = strings.Join(c.Transports, ",")
+	m.AttestationType = c.AttestationType
+	m.UserVerified = c.UserVerified
+	m.BackupEligible = c.BackupEligible
+	m.BackupState = c.BackupState
+	m.CloneWarning = c.CloneWarning
+	m.LastUsedAt = c.LastUsedAt
+}
+
+func credentialHash(id []byte) string {
+	sum := sha256.Sum256(id)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// VirtualAuthenticator 软件认证器，用于离线测试和生成 Fixture，不要在生产环境使用
+type VirtualAuthenticator struct {
+	AAGUID       []byte
+	CredentialId []byte
+	// Key 凭证私钥，支持 *ecdsa.PrivateKey、ed25519.PrivateKey 和 *rsa.PrivateKey
+	Key        crypto.Signer
+	UserHandle []byte
+	SignCount  uint32
+	// Flags 默认 UP | UV
+	Flags byte
+	// Attestation 为 none 或 packed，packed 在没有设置 AttestationKey 时使用自证明
+	Attestation     string
+	AttestationKey  crypto.Signer
+	AttestationCert []byte
+}
+
+// NewVirtualAuthenticator 生成 P-256 凭证密钥，默认 none 证明
+func NewVirtualAuthenticator() (*VirtualAuthenticator, error) {
+	//use example:
+	//auth, _ := webauthn.NewVirtualAuthenticator()
+	//options, sessionId, _ := rp.BeginRegistration(user)
+	//resp, _ := auth.Register(options, "https://example.com")
+	//cred, err := rp.FinishRegistration(user.Id, sessionId, resp)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &VirtualAuthenticator{
+		AAGUID:       make([]byte, 16),
+		CredentialId: id,
+		Key:          key,
+		Flags:        FlagUserPresent | FlagUserVerified,
+		Attestation:  "none",
+	}, nil
+}
+
+// Register 模拟 navigator.credentials.create
+func (a *VirtualAuthenticator) Register(opts *CreationOptions, origin string) (*AttestationResponse, error) {
+	userHandle, err := decodeBase64URL(opts.User.Id)
+	if err != nil {
+		return nil, err
+	}
+	a.UserHandle = userHandle
+	clientDataJSON, err := json.Marshal(clientData{Type: ceremonyCreate, Challenge: opts.Challenge, Origin: origin})
+	if err != nil {
+		return nil, err
+	}
+	pub, err := MarshalPublicKey(a.Key.Public())
+	if err != nil {
+		return nil, err
+	}
+	authData := a.authData(opts.RP.Id, FlagAttestedData)
+	authData = append(authData, a.AAGUID...)
+	authData = append(authData, byte(len(a.CredentialId)>>8), byte(len(a.CredentialId)))
+	authData = append(authData, a.CredentialId...)
+	authData = append(authData, pub...)
+
+	stmt := []cborPair{}
+	if a.Attestation == "packed" {
+		hash := sha256.Sum256(clientDataJSON)
+		signer := a.Key
+		if a.AttestationKey != nil {
+			signer = a.AttestationKey
+		}
+		sig, err := sign(signer, append(append([]byte{}, authData...), hash[:]...))
+		if err != nil {
+			return nil, err
+		}
+		alg, err := signerAlgorithm(signer)
+		if err != nil {
+			return nil, err
+		}
+		stmt = append(stmt, cborPair{"alg", int64(alg)}, cborPair{"sig", sig})
+		if a.AttestationKey != nil {
+			stmt = append(stmt, cborPair{"x5c", []interface{}{a.AttestationCert}})
+		}
+	}
+	format := a.Attestation
+	if format == "" {
+		format = "none"
+	}
+	attObj, err := cborEncode([]cborPair{
+		{"fmt", format},
+		{"attStmt", stmt},
+		{"authData", authData},
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &AttestationResponse{Type: "public-key"}
+	resp.Id = base64.RawURLEncoding.EncodeToString(a.CredentialId)
+	resp.RawId = resp.Id
+	resp.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientDataJSON)
+	resp.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(attObj)
+	resp.Response.Transports = []string{"internal"}
+	return resp, nil
+}
+
+// Login 模拟 navigator.credentials.get，每次调用签名计数器加一（SignCount 为 0 时保持 0，模拟不支持计数器的认证器）
+func (a *VirtualAuthenticator) Login(opts *RequestOptions, origin string) (*AssertionResponse, error) {
+	if a.SignCount > 0 {
+		a.SignCount++
+	}
+	clientDataJSON, err := json.Marshal(clientData{Type: ceremonyGet, Challenge: opts.Challenge, Origin: origin})
+	if err != nil {
+		return nil, err
+	}
+	authData := a.authData(opts.RPId, 0)
+	hash := sha256.Sum256(clientDataJSON)
+	sig, err := sign(a.Key, append(append([]byte{}, authData...), hash[:]...))
+	if err != nil {
+		return nil, err
+	}
+	resp := &AssertionResponse{Type: "public-key"}
+	resp.Id = base64.RawURLEncoding.EncodeToString(a.CredentialId)
+	resp.RawId = resp.Id
+	resp.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientDataJSON)
+	resp.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData)
+	resp.Response.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	resp.Response.UserHandle = base64.RawURLEncoding.EncodeToString(a.UserHandle)
+	return resp, nil
+}
+
+func (a *VirtualAuthenticator) authData(rpId string, flags byte) []byte {
+	hash := sha256.Sum256([]byte(rpId))
+	data := make([]byte, 37)
+	copy(data, hash[:])
+	data[32] = a.Flags | flags
+	binary.BigEndian.PutUint32(data[33:], a.SignCount)
+	return data
+}
+
+func sign(signer crypto.Signer, data []byte) ([]byte, error) {
+	switch key := signer.Public().(type) {
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, data, crypto.Hash(0))
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P384() {
+			sum := sha512.Sum384(data)
+			return signer.Sign(rand.Reader, sum[:], crypto.SHA384)
+		}
+	}
+	sum := sha256.Sum256(data)
+	return signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+func signerAlgorithm(signer crypto.Signer) (COSEAlgorithm, error) {
+	data, err := MarshalPublicKey(signer.Public())
+	if err != nil {
+		return 0, err
+	}
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return 0, errors.New("unsupported signer")
+	}
+	return key.Algorithm, nil
+}
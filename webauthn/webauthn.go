@@ -0,0 +1,508 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrClientData           = errors.New("invalid client data")
+	ErrChallenge            = errors.New("the challenge does not match")
+	ErrOrigin               = errors.New("the origin is not allowed")
+	ErrRPIDHash             = errors.New("the rp id hash does not match")
+	ErrUserPresence         = errors.New("the user presence flag is not set")
+	ErrUserVerification     = errors.New("the user verification is required")
+	ErrCredentialNotAllowed = errors.New("the credential is not allowed")
+	ErrUserId               = errors.New("the user id must be 1 to 64 bytes")
+	ErrUserHandle           = errors.New("the user handle does not match")
+	ErrSessionUser          = errors.New("the webauthn session belongs to another user")
+	ErrCloneDetected        = errors.New("the signature counter went backwards, the credential may be cloned")
+)
+
+// UserVerification、ResidentKey 的取值
+const (
+	Required    = "required"
+	Preferred   = "preferred"
+	Discouraged = "discouraged"
+)
+
+const (
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+)
+
+// User 注册时需要的用户信息，Id 作为 user handle 保存在认证器中，最长 64 字节，不要使用邮箱等个人信息
+type User struct {
+	Id          string
+	Name        string
+	DisplayName string
+}
+
+// RelyingParty WebAuthn 依赖方，负责注册和登录两个仪式
+type RelyingParty struct {
+	// Id 为 RP ID，通常是站点的注册域名，如 example.com
+	Id   string
+	Name string
+	// Origins 允许的来源，如 https://example.com
+	Origins     []string
+	Credentials CredentialStore
+	// Sessions 保存两次请求之间的挑战，默认单机内存存储，多实例部署时使用 RedisSessionStore
+	Sessions SessionStore
+	// Timeout 仪式的有效期，默认 5 分钟
+	Timeout time.Duration
+	// UserVerification 默认 preferred，设置为 required 时要求认证器验证用户（指纹、PIN 等）
+	UserVerification string
+	// ResidentKey 默认 preferred，通行密钥登录（不输入用户名）需要可发现凭证
+	ResidentKey string
+	// Attestation 注册时请求的证明，默认 none，只校验 none 和 packed 格式
+	Attestation string
+	// Algorithms 支持的签名算法，按优先级排序
+	Algorithms []COSEAlgorithm
+	// Challenge 生成挑战，默认 32 字节随机数，离线回放测试数据时使用 FixedChallenge
+	Challenge func() ([]byte, error)
+}
+
+func NewRelyingParty(id, name string, origins []string, credentials CredentialStore) *RelyingParty {
+	//use example:
+	//rp := webauthn.NewRelyingParty("example.com", "ACME Co", []string{"https://example.com"}, webauthn.NewDBCredentialStore(db))
+	//options, sessionId, err := rp.BeginRegistration(webauthn.User{Id: userId, Name: "john@example.com"})
+	//cred, err := rp.FinishRegistration(userId, sessionId, attestationResponse)
+	//options, sessionId, err = rp.BeginLogin("")
+	//cred, err = rp.FinishLogin(sessionId, assertionResponse)
+	return &RelyingParty{
+		Id:               id,
+		Name:             name,
+		Origins:          origins,
+		Credentials:      credentials,
+		Sessions:         NewMemorySessionStore(),
+		Timeout:          5 * time.Minute,
+		UserVerification: Preferred,
+		ResidentKey:      Preferred,
+		Attestation:      "none",
+		Algorithms:       []COSEAlgorithm{AlgES256, AlgEdDSA, AlgRS256},
+		Challenge:        randomChallenge,
+	}
+}
+
+// FixedChallenge 返回固定的挑战，challenge 为 base64url 编码，用于回放录制的测试数据
+func FixedChallenge(challenge string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return decodeBase64URL(challenge)
+	}
+}
+
+// CredentialDescriptor 凭证描述，Id 为 base64url 编码
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	Id         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CredentialParameter 支持的凭证类型和算法
+type CredentialParameter struct {
+	Type string        `json:"type"`
+	Alg  COSEAlgorithm `json:"alg"`
+}
+
+// CreationOptions 即 PublicKeyCredentialCreationOptions，二进制字段使用 base64url 编码，
+// 前端可以直接传给 PublicKeyCredential.parseCreationOptionsFromJSON
+type CreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		Id          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions 即 PublicKeyCredentialRequestOptions
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPId             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse 注册时前端提交的 PublicKeyCredential（toJSON 格式）
+type AttestationResponse struct {
+	Id       string `json:"id"`
+	RawId    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse 登录时前端提交的 PublicKeyCredential（toJSON 格式）
+type AssertionResponse struct {
+	Id       string `json:"id"`
+	RawId    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// clientData 即 CollectedClientData
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// BeginRegistration 生成注册参数，已注册的凭证放入 excludeCredentials 防止同一个认证器重复注册
+func (rp *RelyingParty) BeginRegistration(user User) (*CreationOptions, string, error) {
+	if user.Id == "" || len(user.Id) > 64 {
+		return nil, "", ErrUserId
+	}
+	creds, err := rp.Credentials.ListByUser(user.Id)
+	if err != nil {
+		return nil, "", err
+	}
+	challenge, err := rp.Challenge()
+	if err != nil {
+		return nil, "", err
+	}
+	opts := &CreationOptions{
+		Challenge:   base64.RawURLEncoding.EncodeToString(challenge),
+		Timeout:     rp.Timeout.Milliseconds(),
+		Attestation: rp.Attestation,
+	}
+	opts.RP.Id = rp.Id
+	opts.RP.Name = rp.Name
+	opts.User.Id = base64.RawURLEncoding.EncodeToString([]byte(user.Id))
+	opts.User.Name = user.Name
+	opts.User.DisplayName = user.DisplayName
+	if opts.User.DisplayName == "" {
+		opts.User.DisplayName = user.Name
+	}
+	for _, alg := range rp.Algorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	opts.ExcludeCredentials = descriptors(creds)
+	opts.AuthenticatorSelection.ResidentKey = rp.ResidentKey
+	opts.AuthenticatorSelection.RequireResidentKey = rp.ResidentKey == Required
+	opts.AuthenticatorSelection.UserVerification = rp.UserVerification
+	sessionId, err := rp.saveSession(&SessionData{
+		Type:             ceremonyCreate,
+		Challenge:        opts.Challenge,
+		UserId:           user.Id,
+		UserVerification: rp.UserVerification,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return opts, sessionId, nil
+}
+
+// FinishRegistration 校验认证器的响应并保存凭证，userId 为当前登录的用户
+func (rp *RelyingParty) FinishRegistration(userId, sessionId string, resp *AttestationResponse) (*Credential, error) {
+	session, err := rp.Sessions.Take(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session.Type != ceremonyCreate {
+		return nil, ErrSessionNotFound
+	}
+	if session.UserId != userId {
+		return nil, ErrSessionUser
+	}
+	clientDataJSON, err := rp.verifyClientData(resp.Response.ClientDataJSON, ceremonyCreate, session.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeBase64URL(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCbor, err)
+	}
+	att, err := ParseAttestationObject(raw)
+	if err != nil {
+		return nil, err
+	}
+	authData := att.AuthData
+	if err = rp.verifyAuthData(authData, session.UserVerification); err != nil {
+		return nil, err
+	}
+	if rawId, err := decodeBase64URL(resp.RawId); err == nil && len(rawId) > 0 && !bytes.Equal(rawId, authData.CredentialId) {
+		return nil, fmt.Errorf("%w: rawId does not match the attested credential", ErrAuthenticatorData)
+	}
+	key, err := ParsePublicKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if !rp.allowAlgorithm(key.Algorithm) {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, key.Algorithm)
+	}
+	hash := sha256.Sum256(clientDataJSON)
+	attType, _, err := att.Verify(hash[:])
+	if err != nil {
+		return nil, err
+	}
+	cred := &Credential{
+		Id:              authData.CredentialId,
+		UserId:          userId,
+		PublicKey:       authData.PublicKey,
+		Algorithm:       key.Algorithm,
+		SignCount:       authData.SignCount,
+		AAGUID:          authData.AAGUID,
+		Transports:      resp.Response.Transports,
+		AttestationType: attType,
+		UserVerified:    authData.UserVerified(),
+		BackupEligible:  authData.BackupEligible(),
+		BackupState:     authData.BackupState(),
+		CreatedAt:       time.Now(),
+	}
+	if err = rp.Credentials.Create(cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// BeginLogin 生成登录参数，userId 为空时使用可发现凭证（通行密钥）登录，由认证器选择账号
+func (rp *RelyingParty) BeginLogin(userId string) (*RequestOptions, string, error) {
+	session := &SessionData{Type: ceremonyGet, UserId: userId, UserVerification: rp.UserVerification}
+	var creds []*Credential
+	if userId != "" {
+		var err error
+		if creds, err = rp.Credentials.ListByUser(userId); err != nil {
+			return nil, "", err
+		}
+		if len(creds) == 0 {
+			return nil, "", ErrCredentialNotFound
+		}
+		for _, c := range creds {
+			session.AllowCredentials = append(session.AllowCredentials, c.Id)
+		}
+	}
+	challenge, err := rp.Challenge()
+	if err != nil {
+		return nil, "", err
+	}
+	opts := &RequestOptions{
+		Challenge:        base64.RawURLEncoding.EncodeToString(challenge),
+		Timeout:          rp.Timeout.Milliseconds(),
+		RPId:             rp.Id,
+		AllowCredentials: descriptors(creds),
+		UserVerification: rp.UserVerification,
+	}
+	session.Challenge = opts.Challenge
+	sessionId, err := rp.saveSession(session)
+	if err != nil {
+		return nil, "", err
+	}
+	return opts, sessionId, nil
+}
+
+// FinishLogin 校验签名和签名计数器，成功后返回登录的凭证，凭证的 UserId 即登录的用户。
+// 计数器没有增长时认为凭证可能被克隆，凭证会被标记并拒绝登录，需要用户删除后重新注册
+func (rp *RelyingParty) FinishLogin(sessionId string, resp *AssertionResponse) (*Credential, error) {
+	session, err := rp.Sessions.Take(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	if session.Type != ceremonyGet {
+		return nil, ErrSessionNotFound
+	}
+	rawId, err := decodeBase64URL(resp.RawId)
+	if err != nil || len(rawId) == 0 {
+		return nil, ErrCredentialNotAllowed
+	}
+	if len(session.AllowCredentials) > 0 && !containsId(session.AllowCredentials, rawId) {
+		return nil, ErrCredentialNotAllowed
+	}
+	cred, err := rp.Credentials.Get(rawId)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserId != "" && cred.UserId != session.UserId {
+		return nil, ErrCredentialNotAllowed
+	}
+	userHandle, err := decodeBase64URL(resp.Response.UserHandle)
+	if err != nil {
+		return nil, ErrUserHandle
+	}
+	if len(userHandle) > 0 && string(userHandle) != cred.UserId {
+		return nil, ErrUserHandle
+	}
+	if len(userHandle) == 0 && session.UserId == "" {
+		return nil, ErrUserHandle
+	}
+	clientDataJSON, err := rp.verifyClientData(resp.Response.ClientDataJSON, ceremonyGet, session.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeBase64URL(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrAuthenticatorData
+	}
+	authData, err := ParseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = rp.verifyAuthData(authData, session.UserVerification); err != nil {
+		return nil, err
+	}
+	sig, err := decodeBase64URL(resp.Response.Signature)
+	if err != nil {
+		return nil, ErrSignature
+	}
+	key, err := ParsePublicKey(cred.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(clientDataJSON)
+	if err = key.Verify(append(append([]byte{}, raw...), hash[:]...), sig); err != nil {
+		return nil, err
+	}
+	if cred.CloneWarning {
+		return nil, ErrCloneDetected
+	}
+	// 计数器都为 0 表示认证器不支持计数器（如同步的通行密钥）
+	if (authData.SignCount != 0 || cred.SignCount != 0) && authData.SignCount <= cred.SignCount {
+		cred.CloneWarning = true
+		if err = rp.Credentials.Update(cred); err != nil {
+			return nil, err
+		}
+		return nil, ErrCloneDetected
+	}
+	now := time.Now()
+	cred.SignCount = authData.SignCount
+	cred.BackupState = authData.BackupState()
+	cred.LastUsedAt = &now
+	if err = rp.Credentials.Update(cred); err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (rp *RelyingParty) saveSession(session *SessionData) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	session.ExpiresAt = time.Now().Add(rp.Timeout)
+	if err := rp.Sessions.Save(id, session, rp.Timeout); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// verifyClientData 校验 type、challenge 和 origin，返回 clientDataJSON 原始数据
+func (rp *RelyingParty) verifyClientData(encoded, ceremony, challenge string) ([]byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrClientData, err)
+	}
+	var data clientData
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrClientData, err)
+	}
+	if data.Type != ceremony {
+		return nil, fmt.Errorf("%w: unexpected type %q", ErrClientData, data.Type)
+	}
+	got, err := decodeBase64URL(data.Challenge)
+	want, _ := decodeBase64URL(challenge)
+	if err != nil || len(got) == 0 || !bytes.Equal(got, want) {
+		return nil, ErrChallenge
+	}
+	if data.CrossOrigin || !rp.allowOrigin(data.Origin) {
+		return nil, fmt.Errorf("%w: %s", ErrOrigin, data.Origin)
+	}
+	return raw, nil
+}
+
+func (rp *RelyingParty) verifyAuthData(authData *AuthenticatorData, userVerification string) error {
+	hash := sha256.Sum256([]byte(rp.Id))
+	if !bytes.Equal(authData.RPIDHash, hash[:]) {
+		return ErrRPIDHash
+	}
+	if !authData.UserPresent() {
+		return ErrUserPresence
+	}
+	if userVerification == Required && !authData.UserVerified() {
+		return ErrUserVerification
+	}
+	return nil
+}
+
+func (rp *RelyingParty) allowOrigin(origin string) bool {
+	for _, o := range rp.Origins {
+		if strings.TrimSuffix(o, "/") == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (rp *RelyingParty) allowAlgorithm(alg COSEAlgorithm) bool {
+	for _, a := range rp.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func descriptors(creds []*Credential) []CredentialDescriptor {
+	list := make([]CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		list = append(list, CredentialDescriptor{
+			Type:       "public-key",
+			Id:         base64.RawURLEncoding.EncodeToString(c.Id),
+			Transports: c.Transports,
+		})
+	}
+	return list
+}
+
+func containsId(ids [][]byte, id []byte) bool {
+	for _, v := range ids {
+		if bytes.Equal(v, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomChallenge() ([]byte, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// decodeBase64URL 兼容带填充和标准 base64 编码的数据
+func decodeBase64URL(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}